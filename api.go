@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+var (
+	httpAddr     string        // -http of serve
+	grpcAddr     string        // -grpc of serve
+	queryTimeout time.Duration // -query-timeout of serve
+)
+
+// apiFlags declares the flags of the query API and the gRPC service of serve.
+func apiFlags(fs *flag.FlagSet) {
+	fs.StringVar(&httpAddr, "http", "", "TCP address to also serve the query API on, e.g. localhost:7070: /callers, /callees, /reachable, /paths and /function answer in JSON, and /export serves the graph")
+	fs.StringVar(&grpcAddr, "grpc", "", "TCP address to also serve the CallGraph gRPC service of callgraphpb/callgraph.proto on, e.g. localhost:7071")
+	fs.DurationVar(&queryTimeout, "query-timeout", 30*time.Second, "Time a query of the API or the gRPC service may take before it fails, or reports the pairs left as truncated (0: no limit)")
+}
+
+// reachAnswer is the answer to /reachable.
+type reachAnswer struct {
+	Reachable bool    `json:"reachable"`
+	Path      []Frame `json:"path"`
+}
+
+// apiError is an error of a query, answered with its status.
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string { return e.msg }
+
+func badQuery(format string, args ...any) error {
+	return &apiError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// apiHandler serves the query API over the graph of d.
+func (d *daemon) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", d.serveStatus)
+	mux.HandleFunc("GET /callers", d.answer(d.neighborsQuery(true)))
+	mux.HandleFunc("GET /callees", d.answer(d.neighborsQuery(false)))
+	mux.HandleFunc("GET /reachable", d.answer(d.reachableQuery))
+	mux.HandleFunc("GET /paths", d.answer(d.pathsQuery))
+	mux.HandleFunc("GET /function", d.answer(d.functionQuery))
+	mux.HandleFunc("GET /export", d.serveExport)
+	return mux
+}
+
+// run runs query over the current graph, within -query-timeout. The queries
+// are run one at a time, as they share the call sites of the graph, and don't
+// look for changed files themselves: the graph is rebuilt once pollTree
+// finds some.
+func (d *daemon) run(ctx context.Context, query func(ctx context.Context) error) error {
+	if queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queryTimeout)
+		defer cancel()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.refresh(); err != nil {
+		return err
+	}
+	return query(ctx)
+}
+
+// answer serves query as JSON, run by d.run.
+func (d *daemon) answer(query func(ctx context.Context, r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var v any
+		err := d.run(r.Context(), func(ctx context.Context) (err error) {
+			v, err = query(ctx, r)
+			return err
+		})
+
+		var e *apiError
+		switch {
+		case errors.As(err, &e):
+			http.Error(w, e.msg, e.status)
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			http.Error(w, fmt.Sprintf("query timed out after %v", queryTimeout), http.StatusGatewayTimeout)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeQueryJSON(w, v); err != nil {
+			log.Println("Error sending answer:", err)
+		}
+	}
+}
+
+// funcsParam returns the functions of the graph whose full name matches the
+// regular expression of the query parameter name.
+func (d *daemon) funcsParam(r *http.Request, name string) ([]*Func, error) {
+	return d.matching(name, r.URL.Query().Get(name))
+}
+
+// matching returns the functions of the graph whose full name matches expr,
+// the regular expression of the parameter name.
+func (d *daemon) matching(name, expr string) ([]*Func, error) {
+	if expr == "" {
+		return nil, badQuery("missing parameter %s", name)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, badQuery("parameter %s: %v", name, err)
+	}
+	fns := matchFuncs(d.graph, re)
+	if len(fns) == 0 {
+		return nil, &apiError{http.StatusNotFound, fmt.Sprintf("no function matches %q", expr)}
+	}
+	return fns, nil
+}
+
+// neighborsQuery answers /callers, or else /callees, about the functions
+// matching func, as query callers -format=json does.
+func (d *daemon) neighborsQuery(callers bool) func(context.Context, *http.Request) (any, error) {
+	return func(_ context.Context, r *http.Request) (any, error) {
+		fns, err := d.funcsParam(r, "func")
+		if err != nil {
+			return nil, err
+		}
+		return neighbors(d.graph, fns, callers), nil
+	}
+}
+
+// reachableQuery answers /reachable: whether a function matching from calls
+// one matching to, with the path of the fewest calls between them.
+func (d *daemon) reachableQuery(ctx context.Context, r *http.Request) (any, error) {
+	from, err := d.funcsParam(r, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := d.funcsParam(r, "to")
+	if err != nil {
+		return nil, err
+	}
+	path := shortestPath(ctx, from, to, d.adjacency())
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return reachAnswer{Reachable: path != nil, Path: nonNil(pathFrames(path))}, nil
+}
+
+// pathsQuery answers /paths: the paths from the functions matching from to
+// those matching to, by source as analyze -format=json reports them, the
+// first path of every pair or, with shortest, all or k, the paths analyze
+// reports with -shortest, -all-paths up to max, or -k-paths. With scope, a
+// package pattern as for -scope, only the paths going through it.
+func (d *daemon) pathsQuery(ctx context.Context, r *http.Request) (any, error) {
+	from, err := d.funcsParam(r, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := d.funcsParam(r, "to")
+	if err != nil {
+		return nil, err
+	}
+	params := r.URL.Query()
+	intParam := func(name string, def int) (int, error) {
+		s := params.Get(name)
+		if s == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, badQuery("parameter %s: not a count: %q", name, s)
+		}
+		return n, nil
+	}
+	k, err := intParam("k", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intParam("max", 100)
+	if err != nil {
+		return nil, err
+	}
+	shortest, all := params.Get("shortest") == "true", params.Get("all") == "true"
+	algorithm := callgraphanalysis.FirstPath
+	switch {
+	case btoi(shortest)+btoi(all)+btoi(k > 0) > 1:
+		return nil, badQuery("only one of shortest, all and k can be used")
+	case all:
+		algorithm = callgraphanalysis.AllPaths
+	case k > 0:
+		algorithm, limit = callgraphanalysis.KShortestPaths, k
+	case shortest:
+		algorithm = callgraphanalysis.ShortestPath
+	}
+	return d.search(ctx, funcSet(from), funcSet(to), algorithm, limit, params.Get("scope"))
+}
+
+// search reports the paths from sources to sinks found by algorithm, up to
+// limit by pair with AllPaths and KShortestPaths, as analyze reports them,
+// going through the packages of scope unless it's empty.
+func (d *daemon) search(ctx context.Context, sources, sinks map[*Func]bool, algorithm callgraphanalysis.Algorithm, limit int, scope string) ([]SourceResult, error) {
+	a := *analyzer
+	a.Progress = nil
+	a.Algorithm, a.PathLimit = algorithm, limit
+	q := callgraphanalysis.Query{Graph: d.adjacency(), Sources: sources, Sinks: sinks, CallSites: callSites}
+	var res *callgraphanalysis.Result
+	var err error
+	if scope != "" {
+		if d.scope == nil {
+			d.scope = callgraphanalysis.NewScopeIndex(d.adjacency())
+		}
+		res, err = a.AnalyzeScoped(ctx, q, d.scope, scopePattern(scope))
+	} else {
+		res, err = a.Analyze(ctx, q)
+	}
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	// A search out of time reports the pairs left as truncated
+	return sourceResults(res), nil
+}
+
+// adjacency returns the graph searched by the queries, without the calls to
+// String and Error methods through interfaces, as analyze searches it.
+func (d *daemon) adjacency() map[*Func]map[*Func]bool {
+	if d.adj == nil {
+		d.adj = d.graph.Adjacency()
+		callgraphanalysis.RemovePairs(d.adj, d.graph.StringerPairs())
+	}
+	return d.adj
+}
+
+// exportFormats are the formats of /export, with their content type and file
+// extension.
+var exportFormats = map[string][2]string{
+	"dot":     {"text/vnd.graphviz", "dot"},
+	"graphml": {"application/graphml+xml", "graphml"},
+	"json":    {"application/json", "json"},
+}
+
+// serveExport serves /export: the current graph, or with pkg its part in the
+// packages matching pkg, as dot, graphml or the node-link json of export
+// features, without the features.
+func (d *daemon) serveExport(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	name := params.Get("format")
+	if name == "" {
+		name = "json"
+	}
+	f, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("format must be dot, graphml or json, got %q", name), http.StatusBadRequest)
+		return
+	}
+	g, err := d.current()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if pkg := params.Get("pkg"); pkg != "" {
+		g = packageGraph(g, pkg)
+	}
+	w.Header().Set("Content-Type", f[0])
+	w.Header().Set("Content-Disposition", `attachment; filename="callgraph.`+f[1]+`"`)
+	switch name {
+	case "dot":
+		err = writeDOT(w, g)
+	case "graphml":
+		err = writeGraphML(w, g, nil, nil)
+	case "json":
+		err = writeNodeLink(w, g, nil)
+	}
+	if err != nil {
+		log.Println("Error sending graph:", err)
+	}
+}
+
+// packageGraph returns the functions of g in the packages matching the
+// comma-separated patterns, taken relative to the module as by -scope, and
+// the calls between them.
+func packageGraph(g *Graph, patterns string) *Graph {
+	var pkgs pkgPatterns
+	for _, pattern := range strings.Split(patterns, ",") {
+		pkgs.Set(scopePattern(strings.TrimSpace(pattern)))
+	}
+	sub := &Graph{}
+	keep := make(map[*Func]bool)
+	for _, fn := range g.Funcs {
+		if pkgs.matches(fn.Pkg) {
+			keep[fn] = true
+			sub.Funcs = append(sub.Funcs, fn)
+		}
+	}
+	for _, e := range g.Edges {
+		if keep[e.Caller] && keep[e.Callee] {
+			sub.Edges = append(sub.Edges, e)
+		}
+	}
+	return sub
+}
+
+// funcSet returns fns as a set.
+func funcSet(fns []*Func) map[*Func]bool {
+	set := make(map[*Func]bool, len(fns))
+	for _, fn := range fns {
+		set[fn] = true
+	}
+	return set
+}
+
+// nonNil returns frames, or an empty list for nil, so it is written as [].
+func nonNil(frames []Frame) []Frame {
+	if frames == nil {
+		return []Frame{}
+	}
+	return frames
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// Blame is the last commit changing the body of the function of a frame.
+type Blame struct {
+	Commit string    `json:"commit"`
+	Author string    `json:"author"`
+	Date   time.Time `json:"date"`
+}
+
+// blamer annotates path frames for -blame, asking git once per function.
+type blamer struct {
+	cache map[string]*Blame
+}
+
+// newBlamer returns a blamer of the analyzed directory, which must be in a
+// git work tree.
+func newBlamer() (*blamer, error) {
+	if _, err := exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree").Output(); err != nil {
+		return nil, gitError(err)
+	}
+	return &blamer{cache: make(map[string]*Blame)}, nil
+}
+
+// annotate sets the Blame of the frames of path. Functions git has no
+// history for, like uncommitted ones, are left without.
+func (b *blamer) annotate(path []Frame) {
+	for i := range path {
+		f := &path[i]
+		key := f.File + "\x00" + f.Name
+		blame, ok := b.cache[key]
+		if !ok {
+			if commits, err := functionCommits(*f, 1); err == nil && len(commits) > 0 {
+				c := commits[0]
+				blame = &Blame{Commit: c.Hash, Author: c.Author, Date: c.Date}
+			}
+			b.cache[key] = blame
+		}
+		f.Blame = blame
+	}
+}
+
+// annotateResults sets the Blame of the path frames of every finding.
+func (b *blamer) annotateResults(results []SourceResult) {
+	for _, res := range results {
+		for _, finding := range res.Findings {
+			b.annotate(finding.Path)
+		}
+	}
+}
+
+// blameSuffix returns the text appended to a frame annotated by -blame.
+func blameSuffix(f Frame) string {
+	if f.Blame == nil {
+		return ""
+	}
+	return fmt.Sprintf(msg("lastChanged"), f.Blame.Author, f.Blame.Date.Format(time.DateOnly))
+}
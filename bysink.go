@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// SinkResult holds the sources reaching a sink, for -by-sink, closest first.
+// MinHops is the number of calls from the closest one, zero when Unreachable,
+// that is, when no source reaches the sink.
+type SinkResult struct {
+	Sink        Frame            `json:"sink"`
+	Sources     []ReachingSource `json:"sources"`
+	MinHops     int              `json:"min_hops"`
+	Unreachable bool             `json:"unreachable"`
+}
+
+// ReachingSource is a source reaching a sink in Hops calls at least.
+type ReachingSource struct {
+	Frame
+	Hops int `json:"hops"`
+}
+
+// setSources sets the sources of r, and its distance and reachability from
+// them.
+func (r *SinkResult) setSources(sources []ReachingSource) {
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].Hops < sources[j].Hops })
+	r.Sources, r.MinHops, r.Unreachable = sources, 0, len(sources) == 0
+	if len(sources) > 0 {
+		r.MinHops = sources[0].Hops
+	}
+}
+
+// reachingSources finds the sources reaching each sink, in sink declaration
+// order, with one breadth-first search of the reverse of g per sink, which
+// also gives the distance of each source. Under -max-depth, only the sources
+// reaching a sink in that many calls count.
+func reachingSources(sourceFuncs, sinkFuncs map[*Func]bool, g map[*Func]map[*Func]bool) []SinkResult {
+	callers := callgraphanalysis.Reverse(g)
+
+	var results []SinkResult
+	sorted := sortFuncs(sinkFuncs)
+	reporter().report("Searching callers", "sinks", 0, len(sorted))
+	for i, sinkFunc := range sorted {
+		reached := make(map[*Func]int)
+		visited := map[*Func]bool{sinkFunc: true}
+		level := []*Func{sinkFunc}
+		for depth := 0; len(level) > 0; depth++ {
+			var next []*Func
+			for _, fn := range level {
+				if sourceFuncs[fn] {
+					reached[fn] = depth
+				}
+				if maxDepth > 0 && depth >= maxDepth {
+					continue
+				}
+				for caller := range callers[fn] {
+					if !visited[caller] {
+						visited[caller] = true
+						next = append(next, caller)
+					}
+				}
+			}
+			level = next
+		}
+		sources := make([]ReachingSource, 0, len(reached))
+		for _, fn := range sortFuncs(reachedSet(reached)) {
+			sources = append(sources, ReachingSource{Frame: newFrame(fn), Hops: reached[fn]})
+		}
+		res := SinkResult{Sink: newFrame(sinkFunc)}
+		res.setSources(sources)
+		results = append(results, res)
+		reporter().report("Searching callers", "sinks", i+1, len(sorted))
+	}
+	return results
+}
+
+// reachedSet returns the functions of the distances of reached.
+func reachedSet(reached map[*Func]int) map[*Func]bool {
+	set := make(map[*Func]bool, len(reached))
+	for fn := range reached {
+		set[fn] = true
+	}
+	return set
+}
+
+// bySource turns the sources of each sink into findings without paths, one
+// per source, so that the history, metrics and policy see -by-sink runs as
+// any other.
+func bySource(sinkResults []SinkResult, sourceFuncs map[*Func]bool) []SourceResult {
+	var results []SourceResult
+	index := make(map[string]int)
+	for _, fn := range sortFuncs(sourceFuncs) {
+		index[fn.Function] = len(results)
+		results = append(results, SourceResult{Source: newFrame(fn)})
+	}
+	for _, res := range sinkResults {
+		for _, source := range res.Sources {
+			i := index[source.Function]
+			results[i].Findings = append(results[i].Findings, Finding{Sink: res.Sink})
+		}
+	}
+	return results
+}
+
+// printBySink writes the sources reaching each sink in the text format, with
+// no heading under -quiet.
+func printBySink(w io.Writer, results []SinkResult) {
+	if !quiet {
+		fmt.Fprintln(w, msg("analyzingBySink"))
+	}
+	for _, res := range results {
+		fmt.Fprintf(w, msg("sink"), res.Sink.Name, res.Sink.File, res.Sink.Line)
+		if len(res.Sources) == 0 {
+			fmt.Fprintln(w, msg("noSources"))
+			continue
+		}
+		fmt.Fprintf(w, msg("reachedFrom"), len(res.Sources), res.MinHops)
+		for _, source := range res.Sources {
+			fmt.Fprintf(w, "    - %s (%s:%d), %s\n", source.Name, source.File, source.Line, fmt.Sprintf(msg("hops"), source.Hops))
+		}
+	}
+}
+
+// writeBySinkMarkdown writes the impact of the changed code for pull request
+// comments: a table with the sources reaching each sink, the closest first,
+// and the sinks no source reaches.
+func writeBySinkMarkdown(w io.Writer, results []SinkResult) error {
+	bw := bufio.NewWriter(w)
+	reached := 0
+	for _, res := range results {
+		if !res.Unreachable {
+			reached++
+		}
+	}
+	fmt.Fprintf(bw, "### %s\n\n", msg("mdTitle"))
+	fmt.Fprintf(bw, msg("mdImpact")+"\n", reached, len(results))
+	if len(results) == 0 {
+		return bw.Flush()
+	}
+	fmt.Fprintf(bw, "\n| %s | %s | %s |\n|---|---|---|\n", msg("mdSink"), msg("mdMinHops"), msg("mdSources"))
+	for _, res := range results {
+		if res.Unreachable {
+			fmt.Fprintf(bw, "| %s | %s | |\n", mdFrame(res.Sink), msg("mdUnreachable"))
+			continue
+		}
+		var names []string
+		for _, source := range res.Sources {
+			names = append(names, fmt.Sprintf("`%s` (%d)", mdEscape(source.Name), source.Hops))
+		}
+		fmt.Fprintf(bw, "| %s | %s | %s |\n", mdFrame(res.Sink), fmt.Sprintf(msg("hops"), res.MinHops), strings.Join(names, ", "))
+	}
+	return bw.Flush()
+}
+
+// writeBySinkJSON writes the sources reaching each sink as an indented JSON
+// array.
+func writeBySinkJSON(w io.Writer, results []SinkResult) error {
+	if results == nil {
+		results = []SinkResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
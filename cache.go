@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+var (
+	cacheDir    string // -cache
+	incremental bool   // -incremental
+	searchKey   string // cache key of the graph searched by analyze, if cached
+)
+
+const (
+	// cacheOff is the -cache value disabling the cache.
+	cacheOff = "off"
+	// cacheMaxAge is how long the entries of the default cache directory
+	// are kept unused.
+	cacheMaxAge = 5 * 24 * time.Hour
+)
+
+// defaultCacheDir returns the directory -cache defaults to: callgraph-analysis
+// in the cache directory of the user, off if there is none.
+func defaultCacheDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return cacheOff
+	}
+	return filepath.Join(base, "callgraph-analysis")
+}
+
+// caching reports whether -cache is set to a directory.
+func caching() bool {
+	return cacheDir != "" && cacheDir != cacheOff
+}
+
+// graphCache returns the cache of -cache, nil without it.
+func graphCache() callgraphanalysis.Cache {
+	if !caching() {
+		return nil
+	}
+	return callgraphanalysis.DirCache{Dir: cacheDir}
+}
+
+// trimCache removes the entries of the default cache directory left unused
+// for cacheMaxAge, at most once a day, noted by the time of its trim.txt.
+// Directories given with -cache are left to their owner.
+func trimCache() {
+	if cacheDir != defaultCacheDir() {
+		return
+	}
+	mark := filepath.Join(cacheDir, "trim.txt")
+	if info, err := os.Stat(mark); err == nil && time.Since(info.ModTime()) < 24*time.Hour {
+		return
+	}
+	if err := (callgraphanalysis.DirCache{Dir: cacheDir}).Trim(cacheMaxAge); err != nil {
+		log.Println("Warning: trimming the cache:", err)
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err == nil {
+		os.WriteFile(mark, []byte(time.Now().Format(time.RFC3339)+"\n"), 0o644)
+	}
+}
+
+// graphCacheKey returns the cache key of the graph of the target: the hash
+// of the module, the flags the graph is built with and the build of the tool,
+// empty without -cache or if the module can't be hashed.
+func graphCacheKey() string {
+	if !caching() {
+		return ""
+	}
+	trimCache()
+	hash, err := callgraphanalysis.ModuleHash(dir)
+	if err != nil {
+		log.Println("Warning: not caching the graph:", err)
+		return ""
+	}
+	env, err := goEnv()
+	if err != nil {
+		log.Println("Warning: not caching the graph:", err)
+		return ""
+	}
+	return callgraphanalysis.CacheKey(hash, append([]string{"graph", toolVersion(), env}, graphConfig()...)...)
+}
+
+// graphConfig returns the flags the graph of the target is built with.
+func graphConfig() []string {
+	return []string{strconv.Itoa(graphFileVersion),
+		module, orgPrefix, strings.Join(loadPatterns, ","), buildTags, goos, goarch, cgo, strconv.FormatBool(withTests),
+		regexps(excludeFiles), regexps(excludeFuncs), includePkgs.String(), excludePkgs.String(),
+		includeDeps.String(), includeStd.String()}
+}
+
+// toolVersion identifies the build of the tool, since another one may build
+// another graph from the same code: the version of its module and of Go it
+// was built with, or the hash of its executable for a development build.
+var toolVersion = sync.OnceValue(func() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version + " " + info.GoVersion
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+})
+
+// goEnv returns the settings of the go command the packages are loaded with
+// that select their files: the Go version, the platform, cgo, the flags, the
+// experiments, which -goos, -goarch and -cgo only override, and the go.work
+// selecting the modules loaded with the target.
+func goEnv() (string, error) {
+	cmd := exec.CommandContext(runCtx, "go", "env", "GOVERSION", "GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS", "GOEXPERIMENT", "GOWORK")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), analyzer.Env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go env: %v", err)
+	}
+	return string(out), nil
+}
+
+// regexps joins the expressions of res.
+func regexps(res []*regexp.Regexp) string {
+	var exprs []string
+	for _, re := range res {
+		exprs = append(exprs, re.String())
+	}
+	return strings.Join(exprs, "\n")
+}
+
+// cachedGraph returns the graph stored under key in the -cache directory,
+// nil if there is none or no key.
+func cachedGraph(key string) *Graph {
+	if key == "" {
+		return nil
+	}
+	data, ok, err := graphCache().Get(runCtx, key)
+	if err != nil {
+		log.Println("Warning: reading the graph from the cache:", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	g, err := decodeGraph(bytes.NewReader(data))
+	if err != nil {
+		log.Println("Warning: rebuilding the cached graph:", err)
+		return nil
+	}
+	log.Printf("Using the cached graph of %d functions and %d edges", len(g.Funcs), len(g.Edges))
+	return g
+}
+
+// cacheGraph stores g under key in the -cache directory, unless there is no
+// key.
+func cacheGraph(key string, g *Graph) {
+	if key == "" {
+		return
+	}
+	var buf bytes.Buffer
+	if err := encodeGraph(&buf, g); err != nil {
+		log.Println("Warning: encoding the graph for the cache:", err)
+		return
+	}
+	if err := graphCache().Put(runCtx, key, buf.Bytes()); err != nil {
+		log.Println("Warning: storing the graph in the cache:", err)
+	}
+}
+
+// cachedEntrypoints returns the kinds of the entrypoints by full function
+// name stored with the graph of key in the -cache directory, nil if there
+// are none or no key.
+func cachedEntrypoints(key string) map[string]string {
+	if key == "" {
+		return nil
+	}
+	data, ok, err := graphCache().Get(runCtx, callgraphanalysis.CacheKey(key, "entrypoints"))
+	if err != nil {
+		log.Println("Warning: reading the entrypoints from the cache:", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	var kinds map[string]string
+	if err := json.Unmarshal(data, &kinds); err != nil {
+		log.Println("Warning: detecting the cached entrypoints again:", err)
+		return nil
+	}
+	return kinds
+}
+
+// cacheEntrypoints stores the kinds of the entrypoints by full function name
+// with the graph of key in the -cache directory, unless there is no key.
+func cacheEntrypoints(key string, kinds map[string]string) {
+	if key == "" {
+		return
+	}
+	data, err := json.Marshal(kinds)
+	if err != nil {
+		log.Println("Warning: encoding the entrypoints for the cache:", err)
+		return
+	}
+	if err := graphCache().Put(runCtx, callgraphanalysis.CacheKey(key, "entrypoints"), data); err != nil {
+		log.Println("Warning: storing the entrypoints in the cache:", err)
+	}
+}
+
+// targetGraph returns the graph of the target from the -cache directory, or
+// built and stored there.
+func targetGraph() *Graph {
+	g, err := tryTargetGraph()
+	if err != nil {
+		failed("Error building the graph:", err)
+	}
+	return g
+}
+
+// tryTargetGraph is targetGraph returning the error building the graph, for
+// the daemon to keep serving the last one while a file is being edited.
+func tryTargetGraph() (*Graph, error) {
+	key := graphCacheKey()
+	if g := cachedGraph(key); g != nil {
+		return g, nil
+	}
+	g, err := tryNewGraph()
+	if err != nil {
+		return nil, err
+	}
+	cacheGraph(key, g)
+	return g, nil
+}
+
+// newGraph builds the graph of the target, with -incremental from the state
+// of its last build in the -cache directory.
+func newGraph() *Graph {
+	g, err := tryNewGraph()
+	if err != nil {
+		failed("Error building the graph:", err)
+	}
+	return g
+}
+
+// tryNewGraph is newGraph returning the error building the graph.
+func tryNewGraph() (*Graph, error) {
+	if !incremental || !caching() {
+		if incremental {
+			log.Println("Warning: -incremental needs -cache, building the whole graph")
+		}
+		prog, err := analyzer.Load(runCtx)
+		if err != nil {
+			return nil, fmt.Errorf("loading packages: %w", err)
+		}
+		g, err := analyzer.BuildGraph(runCtx, prog)
+		if err != nil {
+			return nil, fmt.Errorf("visiting edges: %w", err)
+		}
+		return g, nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	key := callgraphanalysis.CacheKey(abs, append([]string{"incremental", toolVersion()}, graphConfig()...)...)
+	g, err := analyzer.IncrementalGraph(runCtx, graphCache(), key)
+	if err != nil {
+		return nil, fmt.Errorf("loading packages: %w", err)
+	}
+	return g, nil
+}
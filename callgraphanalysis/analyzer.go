@@ -0,0 +1,355 @@
+// Package callgraphanalysis finds the paths from the entrypoints of a Go
+// module to the functions a change touches, over its CHA call graph.
+//
+// An Analyzer loads the packages of the module into an SSA program, from
+// which BuildGraph keeps the call graph of the module: its functions, the
+// calls between them and the calls into the other modules of the
+// organization, as selected by the filters of the Analyzer. The adjacency of
+// the graph is then pruned with Restrict and the other filters, and searched
+// with FindPaths, after ruling out the pairs a ReachIndex proves unreachable.
+package callgraphanalysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Analyzer holds the configuration of an analysis: the code to load, the
+// functions kept in its graph and how paths are searched. It is created by
+// New with options, or as a struct literal. Load records the modules it
+// finds, so an Analyzer analyzes one program at a time, while different
+// Analyzers can run at the same time.
+type Analyzer struct {
+	// Dir is the directory to analyze: a module, a module of a Go
+	// workspace, or a directory whose subdirectories hold the modules.
+	Dir string
+	// Module is the path of the analyzed module.
+	Module string
+	// OrgPrefix is the import path prefix of the modules of the
+	// organization: calls into them are recorded as external calls, and
+	// their vendored copies are kept in the graph.
+	OrgPrefix string
+	// Patterns are the go list patterns of the packages to load, relative
+	// to Dir, ./... if empty.
+	Patterns []string
+	// Tags are the comma-separated build tags to load the packages with.
+	Tags string
+	// Tests loads the _test.go files too.
+	Tests bool
+	// Env is added to the environment of the go command, e.g. GOOS=linux.
+	Env []string
+	// Logf, if not nil, reports how the packages are loaded.
+	Logf func(format string, args ...any)
+	// Progress, if not nil, is called as Load, BuildGraph and Analyze go
+	// through their phases, from one goroutine at a time.
+	Progress func(Progress)
+
+	// IncludeDep, if not nil, selects the packages outside the module kept
+	// in the graph, to follow the callbacks registered with them.
+	IncludeDep func(path string) bool
+	// Filters select the functions kept in the graph among those of the
+	// module, of the vendored modules of the organization and of the
+	// IncludeDep packages: the ones every filter keeps. EdgeFilters select
+	// the calls kept between them likewise.
+	Filters     []FilterFunc
+	EdgeFilters []EdgeFilterFunc
+	// EdgeHooks are called with every call kept in the graph, to compute
+	// metrics over it without walking the SSA program again.
+	EdgeHooks []EdgeHook
+	// BuildDeps builds the SSA form of every package loaded, for Detectors
+	// inspecting the code of the dependencies. Otherwise Load only builds the
+	// packages whose functions the graph may keep, and those declaring
+	// generic code they may instantiate: the others are created from their
+	// types alone, as their functions are not kept and their calls never
+	// lead back to the module through a kept function.
+	BuildDeps bool
+	// LowMemory builds the graph without the CHA call graph of the whole
+	// program, whose nodes and edges for every function of the standard
+	// library and the dependencies outweigh the graph kept, at the cost of
+	// resolving the calls on one goroutine.
+	LowMemory bool
+
+	// Detectors are run by Detect, every registered detector if nil.
+	Detectors []Detector
+
+	// Algorithm is how FindPaths searches, and PathLimit the number of paths
+	// of KShortestPaths or the maximum of AllPaths (0: no limit).
+	Algorithm Algorithm
+	PathLimit int
+	// MaxDepth bounds the number of calls of the paths searched (0: no
+	// limit).
+	MaxDepth int
+
+	workspace []string // modules analyzed together, when there are several
+	vendored  []string // modules of the organization vendored by the module
+}
+
+// logf reports through Logf, if set.
+func (a *Analyzer) logf(format string, args ...any) {
+	if a.Logf != nil {
+		a.Logf(format, args...)
+	}
+}
+
+// Load loads the packages matching Patterns and builds their SSA form.
+// Without a go.mod in Dir, the modules under it are loaded together, as are
+// the modules of the workspace of Dir. It stops with the error of ctx once
+// ctx is done.
+func (a *Analyzer) Load(ctx context.Context) (*ssa.Program, error) {
+	cfg, patterns, cleanup, err := a.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return loadProgram(ctx, cfg, patterns, a.built(), a.progress)
+}
+
+// built returns whether Load builds the SSA form of the package at a path,
+// nil to build every package.
+func (a *Analyzer) built() func(path string) bool {
+	if a.BuildDeps {
+		return nil
+	}
+	return func(path string) bool {
+		return a.InModule(path) || a.vendoredPkg(path) || a.IncludeDep != nil && a.IncludeDep(path)
+	}
+}
+
+// loadConfig returns how Load loads the packages, and the patterns it loads.
+// cleanup removes the temporary workspace the modules are loaded through, if
+// any, once they are loaded.
+func (a *Analyzer) loadConfig(ctx context.Context) (cfg *packages.Config, patterns []string, cleanup func(), err error) {
+	cleanup = func() {}
+	cfg = &packages.Config{
+		Context: ctx,
+		Mode:    packages.LoadAllSyntax,
+		Dir:     a.Dir,
+		Tests:   a.Tests,
+	}
+	if a.Tags != "" {
+		cfg.BuildFlags = []string{"-tags=" + a.Tags}
+	}
+	env := slices.Clone(a.Env)
+	patterns = []string{"./..."}
+	a.workspace, a.vendored = nil, nil
+
+	// Without a go.mod in dir, load every module found under it together
+	modules, err := findModules(a.Dir)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("looking for Go modules: %v", err)
+	}
+	if len(modules) > 0 {
+		work, err := writeWorkspace(modules)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("creating a workspace for the Go modules: %v", err)
+		}
+		cleanup = func() { os.RemoveAll(filepath.Dir(work)) }
+		env = append(env, "GOWORK="+work, "GOFLAGS="+workspaceFlags())
+		patterns = nil
+		var found []string
+		for _, m := range modules {
+			patterns = append(patterns, m.path+"/...")
+			found = append(found, fmt.Sprintf("%s (%s)", m.path, a.relPath(m.dir)))
+		}
+		a.logf("No go.mod in %s, analyzing the modules under it: %s", a.Dir, strings.Join(found, ", "))
+	} else {
+		// Within a workspace, load every module it uses
+		var work string
+		if work, modules, err = workspaceModules(a.Dir); err != nil {
+			return nil, nil, nil, fmt.Errorf("reading the Go workspace: %v", err)
+		}
+		if len(modules) > 1 {
+			env = append(env, "GOFLAGS="+workspaceFlags())
+			patterns = nil
+			var found []string
+			for _, m := range modules {
+				patterns = append(patterns, m.path+"/...")
+				found = append(found, m.path)
+			}
+			a.logf("Analyzing the modules of the workspace %s: %s", work, strings.Join(found, ", "))
+		} else {
+			modules = nil
+			// The build uses the vendored copies of the dependencies, which
+			// GOFLAGS=-mod=mod would replace with those of the module cache
+			if vendored, ok := vendoredModules(a.Dir); ok {
+				cfg.BuildFlags = append(cfg.BuildFlags, "-mod=vendor")
+				for _, m := range vendored {
+					if a.OrgPrefix != "" && strings.HasPrefix(m, a.OrgPrefix) {
+						a.vendored = append(a.vendored, m)
+					}
+				}
+				if len(a.vendored) > 0 {
+					a.logf("Keeping the vendored modules of the organization in the graph: %s", strings.Join(a.vendored, ", "))
+				}
+			}
+		}
+	}
+	for _, m := range modules {
+		a.workspace = append(a.workspace, m.path)
+	}
+	if len(a.Patterns) > 0 {
+		patterns = a.Patterns
+	}
+	if len(env) > 0 {
+		cfg.Env = append(os.Environ(), env...)
+	}
+	return cfg, patterns, cleanup, nil
+}
+
+// loadProgram loads the packages matching patterns with cfg and builds their
+// SSA form, for the packages built reports, if not nil, and those declaring
+// generic code. It reports its phases through report.
+func loadProgram(ctx context.Context, cfg *packages.Config, patterns []string, built func(path string) bool, report func(phase Phase, done, total int)) (*ssa.Program, error) {
+	report(PhaseLoad, 0, 0)
+	initial, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var errs []error
+	loaded := 0
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		loaded++
+		for _, err := range p.Errors {
+			errs = append(errs, err)
+		}
+	})
+	report(PhaseLoad, loaded, loaded)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	// The test binaries have a generated main calling every test, which is
+	// not code of the module
+	if cfg.Tests {
+		initial = slices.DeleteFunc(initial, func(p *packages.Package) bool {
+			return strings.HasSuffix(p.PkgPath, ".test")
+		})
+	}
+
+	// Create and build SSA-form program representation.
+	mode := ssa.InstantiateGenerics // instantiate generics by default for soundness
+	prog := newProgram(initial, mode, built)
+	if err := build(ctx, prog, report); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// newProgram creates the SSA packages of initial and their dependencies, like
+// ssautil.AllPackages, from their syntax if built is nil or reports their
+// path, or if they declare generic code, whose instances the packages built
+// may need. The others are created from their types, with no code to build.
+func newProgram(initial []*packages.Package, mode ssa.BuilderMode, built func(path string) bool) *ssa.Program {
+	var fset *token.FileSet
+	if len(initial) > 0 {
+		fset = initial[0].Fset
+	}
+	prog := ssa.NewProgram(fset, mode)
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if p.Types == nil || p.IllTyped {
+			return
+		}
+		if built == nil || built(p.PkgPath) || generic(p.Types) {
+			prog.CreatePackage(p.Types, p.Syntax, p.TypesInfo, true)
+		} else {
+			prog.CreatePackage(p.Types, nil, nil, true)
+		}
+	})
+	return prog
+}
+
+// generic reports whether pkg declares a generic function or type.
+func generic(pkg *types.Package) bool {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			if obj.Type().(*types.Signature).TypeParams().Len() > 0 {
+				return true
+			}
+		case *types.TypeName:
+			if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// build builds the SSA form of the packages of prog in parallel, like
+// Program.Build, leaving the packages not started yet once ctx is done. It
+// reports the packages built through report.
+func build(ctx context.Context, prog *ssa.Program, report func(phase Phase, done, total int)) error {
+	all := prog.AllPackages()
+	built := counter(report, PhaseBuild, len(all))
+	pkgs := make(chan *ssa.Package)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pkgs {
+				p.Build()
+				built()
+			}
+		}()
+	}
+	for _, p := range all {
+		if ctx.Err() != nil {
+			break
+		}
+		pkgs <- p
+	}
+	close(pkgs)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// parallel calls f with every index below n from GOMAXPROCS goroutines, and
+// returns once every call returned.
+func parallel(n int, f func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// relPath returns name relative to Dir, or name unchanged when it lies
+// outside of it.
+func (a *Analyzer) relPath(name string) string {
+	base, err := filepath.Abs(a.Dir)
+	if err != nil {
+		return name
+	}
+	rel, err := filepath.Rel(base, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
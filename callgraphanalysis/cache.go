@@ -0,0 +1,355 @@
+package callgraphanalysis
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache stores what an analysis computed, like serialized graphs and reach
+// indexes, to reuse it in later runs. Keys are made by CacheKey, so that a
+// change to the module or to the configuration misses the entries made
+// before it. Implementations backed by shared storage let several runners
+// reuse each other's entries; they must be safe for concurrent use.
+type Cache interface {
+	// Get returns the data stored under key, and false if there is none.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Put stores data under key, replacing what was there.
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// NopCache is a Cache storing nothing.
+type NopCache struct{}
+
+// Get always misses.
+func (NopCache) Get(context.Context, string) ([]byte, bool, error) { return nil, false, nil }
+
+// Put drops data.
+func (NopCache) Put(context.Context, string, []byte) error { return nil }
+
+// DirCache is a Cache storing every entry as a file of Dir, created if
+// needed.
+type DirCache struct {
+	Dir string
+}
+
+// Get reads the file of key, marking it used for Trim.
+func (c DirCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	name := filepath.Join(c.Dir, key)
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// Only entries left unused for an hour are touched, to spare the writes
+	if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > time.Hour {
+		now := time.Now()
+		os.Chtimes(name, now, now)
+	}
+	return data, true, nil
+}
+
+// Trim removes the entries not used for maxAge, as the go command trims its
+// build cache, so that a cache keyed by content doesn't grow with every
+// change of the module. Files other than entries are left alone.
+func (c DirCache) Trim(maxAge time.Duration) error {
+	entries, err := os.ReadDir(c.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !cacheEntry(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > maxAge {
+			if err := os.Remove(filepath.Join(c.Dir, e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cacheEntry reports whether name is the file of an entry, named after a
+// CacheKey, or a temporary file a Put left behind.
+func cacheEntry(name string) bool {
+	key, _, _ := strings.Cut(name, ".")
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// Put writes the file of key, through a temporary file renamed over it so
+// that concurrent runs never read a partial entry.
+func (c DirCache) Put(ctx context.Context, key string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(c.Dir, key)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// CacheKey returns the key of an entry computed for a module, by the hash of
+// its content as returned by ModuleHash, with a configuration: what the
+// entry is, and every setting it depends on.
+func CacheKey(moduleHash string, config ...string) string {
+	h := sha256.New()
+	io.WriteString(h, moduleHash)
+	for _, c := range config {
+		fmt.Fprintf(h, "\x00%s", c)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ModuleHash hashes what the call graph of the module in dir is built from:
+// its go.mod, go.sum and go.work files, and its Go files, vendored ones
+// included. Like the go command, it skips the directories named testdata or
+// starting with . or _. Within a Go workspace, the go.work file and the
+// modules it uses are hashed too, when they are outside dir, since Load
+// loads them together. Dependencies are covered by go.sum, except those
+// replaced by a directory outside dir.
+func ModuleHash(dir string) (string, error) {
+	h := sha256.New()
+	if err := hashTree(h, dir, ""); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	work, modules, err := workspaceModules(dir)
+	if err != nil {
+		return "", fmt.Errorf("reading the Go workspace: %v", err)
+	}
+	if work != "" && !within(abs, work) {
+		if err := hashFile(h, work, work); err != nil {
+			return "", err
+		}
+	}
+	for _, m := range modules {
+		modDir, err := filepath.Abs(m.dir)
+		if err != nil {
+			return "", err
+		}
+		// Named by absolute path, as they are not under dir
+		if !within(abs, modDir) {
+			if err := hashTree(h, modDir, modDir); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashTree writes to h the files of the directory tree at dir that
+// ModuleHash hashes, each named by its path relative to dir under prefix.
+func hashTree(h io.Writer, dir, prefix string) error {
+	var names []string
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		base := d.Name()
+		if d.IsDir() {
+			if name != dir && (base == "testdata" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case strings.HasSuffix(base, ".go"), base == "go.mod", base == "go.sum", base == "go.work", base == "go.work.sum":
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := hashFile(h, name, path.Join(filepath.ToSlash(prefix), filepath.ToSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashFile writes to h the file name, labeled, with its length.
+func hashFile(h io.Writer, name, label string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "%s\x00%d\x00", label, len(data))
+	h.Write(data)
+	return nil
+}
+
+// within reports whether name is dir or a path under it, both absolute.
+func within(dir, name string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// reachIndexFile is the serialized form of a ReachIndex: functions are
+// referenced by full name, the component of Funcs[i] being Comp[i].
+type reachIndexFile struct {
+	Funcs   []string
+	Comp    []int
+	Targets []string
+	Reach   [][]uint64
+}
+
+// encodeReachIndex serializes idx as gob.
+func encodeReachIndex(idx *ReachIndex) ([]byte, error) {
+	var data reachIndexFile
+	funcs := make(map[*Func]bool, len(idx.comp))
+	for fn := range idx.comp {
+		funcs[fn] = true
+	}
+	for _, fn := range SortFuncs(funcs) {
+		data.Funcs = append(data.Funcs, fn.Function)
+		data.Comp = append(data.Comp, idx.comp[fn])
+	}
+	data.Targets = make([]string, len(idx.targets))
+	for fn, t := range idx.targets {
+		data.Targets[t] = fn.Function
+	}
+	for _, b := range idx.reach {
+		data.Reach = append(data.Reach, b)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeReachIndex reads an index written by encodeReachIndex, resolving
+// its functions by full name among those of g and targets.
+func decodeReachIndex(b []byte, g map[*Func]map[*Func]bool, targets map[*Func]bool) (*ReachIndex, error) {
+	var data reachIndexFile
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil {
+		return nil, err
+	}
+	if len(data.Comp) != len(data.Funcs) {
+		return nil, errors.New("corrupt reach index")
+	}
+	byName := make(map[string]*Func)
+	for caller, callees := range g {
+		byName[caller.Function] = caller
+		for callee := range callees {
+			byName[callee.Function] = callee
+		}
+	}
+	for fn := range targets {
+		byName[fn.Function] = fn
+	}
+
+	idx := &ReachIndex{comp: make(map[*Func]int, len(data.Funcs)), targets: make(map[*Func]int, len(data.Targets))}
+	for i, name := range data.Funcs {
+		fn := byName[name]
+		if fn == nil || data.Comp[i] < 0 || data.Comp[i] >= len(data.Reach) {
+			return nil, fmt.Errorf("reach index of another graph: %s", name)
+		}
+		idx.comp[fn] = data.Comp[i]
+	}
+	for t, name := range data.Targets {
+		fn := byName[name]
+		if fn == nil {
+			return nil, fmt.Errorf("reach index of another graph: %s", name)
+		}
+		idx.targets[fn] = t
+	}
+	words := len(newBitset(len(data.Targets)))
+	for _, b := range data.Reach {
+		if len(b) != words {
+			return nil, errors.New("corrupt reach index")
+		}
+		idx.reach = append(idx.reach, b)
+	}
+	return idx, nil
+}
+
+// reachIndex returns the index of the sinks of q over the condensation c of
+// its graph, from q.Cache when it has one under q.Key, and otherwise built
+// and stored there. Failures of the cache are logged and cost the time to
+// build the index.
+func (a *Analyzer) reachIndex(ctx context.Context, q Query, c *Condensation) *ReachIndex {
+	if q.Cache == nil || q.Key == "" {
+		return NewReachIndex(c, q.Sinks)
+	}
+	var sinks []string
+	for _, fn := range SortFuncs(q.Sinks) {
+		sinks = append(sinks, fn.Function)
+	}
+	key := CacheKey(q.Key, "reach", strings.Join(sinks, "\n"))
+	data, ok, err := q.Cache.Get(ctx, key)
+	if err == nil && ok {
+		idx, err := decodeReachIndex(data, q.Graph, q.Sinks)
+		if err == nil {
+			return idx
+		}
+		a.logf("Rebuilding the reach index: %v", err)
+	} else if err != nil {
+		a.logf("Reading the reach index from the cache: %v", err)
+	}
+
+	idx := NewReachIndex(c, q.Sinks)
+	if data, err := encodeReachIndex(idx); err != nil {
+		a.logf("Encoding the reach index: %v", err)
+	} else if err := q.Cache.Put(ctx, key, data); err != nil {
+		a.logf("Storing the reach index in the cache: %v", err)
+	}
+	return idx
+}
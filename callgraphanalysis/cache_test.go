@@ -0,0 +1,62 @@
+package callgraphanalysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files, by path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestModuleHashWorkspace checks that the hash of a module of a Go workspace
+// changes with the go.work and the other modules it uses, which Load loads
+// with it.
+func TestModuleHashWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.work":  "go 1.21\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.21\n",
+		"a/a.go":   "package a\n\nfunc A() {}\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.21\n",
+		"b/b.go":   "package b\n\nfunc B() {}\n",
+		"c/go.mod": "module example.com/c\n\ngo 1.21\n",
+		"c/c.go":   "package c\n\nfunc C() {}\n",
+	})
+	dir := filepath.Join(root, "a")
+	hash := func() string {
+		t.Helper()
+		h, err := ModuleHash(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	before := hash()
+	writeFiles(t, root, map[string]string{"c/c.go": "package c\n\nfunc C() { C() }\n"})
+	if hash() != before {
+		t.Error("the hash changed with a module the workspace doesn't use")
+	}
+	writeFiles(t, root, map[string]string{"b/b.go": "package b\n\nfunc B() { B() }\n"})
+	after := hash()
+	if after == before {
+		t.Error("the hash didn't change with a module of the workspace")
+	}
+	writeFiles(t, root, map[string]string{"go.work": "go 1.21\n\nuse (\n\t./a\n\t./b\n\t./c\n)\n"})
+	if hash() == after {
+		t.Error("the hash didn't change with the go.work")
+	}
+}
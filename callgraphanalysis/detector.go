@@ -0,0 +1,85 @@
+package callgraphanalysis
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Detection is what a Detector finds in a program: the functions to take as
+// sources, like the handlers of an in-house event bus, and as sinks.
+type Detection struct {
+	Sources []*ssa.Function
+	Sinks   []*ssa.Function
+}
+
+// Detector finds sources and sinks in a program loaded by Load, for the
+// frameworks the command doesn't know about. Detectors are registered with
+// Register, usually from the init function of their package.
+type Detector interface {
+	// Name identifies the detector in Detectors and in the errors of Detect.
+	Name() string
+	// Detect inspects prog, as loaded and built by Load.
+	Detect(prog *ssa.Program) (Detection, error)
+}
+
+var (
+	detectorsMu sync.Mutex
+	detectors   = make(map[string]Detector)
+)
+
+// Register makes d available to every Analyzer. It panics if a detector of
+// the same name is already registered.
+func Register(d Detector) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	if _, dup := detectors[d.Name()]; dup {
+		panic("callgraphanalysis: Register called twice for detector " + d.Name())
+	}
+	detectors[d.Name()] = d
+}
+
+// Detectors returns the registered detectors, sorted by name.
+func Detectors() []Detector {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	list := make([]Detector, 0, len(detectors))
+	for _, d := range detectors {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// LookupDetector returns the registered detector named name, or nil.
+func LookupDetector(name string) Detector {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	return detectors[name]
+}
+
+// Detect runs the Detectors of a, or every registered detector if nil, on
+// prog and returns what each found, by name, leaving out the functions that
+// are not in the graph.
+func (a *Analyzer) Detect(prog *ssa.Program) (map[string]Detection, error) {
+	run := a.Detectors
+	if run == nil {
+		run = Detectors()
+	}
+	drop := func(fn *ssa.Function) bool { return fn == nil || a.node(prog, fn) == nil }
+	found := make(map[string]Detection, len(run))
+	for _, d := range run {
+		det, err := d.Detect(prog)
+		if err != nil {
+			return nil, fmt.Errorf("detector %s: %v", d.Name(), err)
+		}
+		det.Sources = slices.DeleteFunc(det.Sources, drop)
+		det.Sinks = slices.DeleteFunc(det.Sinks, drop)
+		a.logf("Detector %s found %d sources and %d sinks", d.Name(), len(det.Sources), len(det.Sinks))
+		found[d.Name()] = det
+	}
+	return found, nil
+}
@@ -0,0 +1,451 @@
+package callgraphanalysis
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// dynCall is a call through an interface or a function value, which CHA
+// resolves against every method and function of the program: the methods
+// Method of the types implementing the interface of method set Iface, or the
+// functions of signature Sig. Types are written by typeWriter, so that calls
+// and targets described from different programs can be matched.
+type dynCall struct {
+	Method string
+	Iface  []string
+	Sig    string
+}
+
+// key identifies c among the calls.
+func (c dynCall) key() string {
+	if c.Method == "" {
+		return c.Sig
+	}
+	return c.Method + "\x00" + strings.Join(c.Iface, "\x00")
+}
+
+// dynSite is a dynamic call of a kept function at File:Line, made directly
+// or by a synthetic wrapper the function calls there.
+type dynSite struct {
+	Caller   *Func
+	File     string
+	Line     int
+	Stringer bool
+	Call     dynCall
+}
+
+// dynTarget is what CHA resolves dynamic calls to: the method Method of a
+// type of method set Recv, or a function of signature Sig. Funcs are the
+// kept functions it stands for once synthetic wrappers are bridged, as
+// DeleteSyntheticNodes does, and Calls the dynamic calls of those wrappers.
+// Uses are the packages it depends on other than those of Funcs.
+type dynTarget struct {
+	Method string
+	Recv   []string
+	Sig    string
+	Uses   []string
+	Funcs  []*Func
+	Calls  []dynCall
+
+	wrapper bool // a synthetic function, made where it is used
+}
+
+// describeDispatch describes the dynamic calls of the functions of funcs, as
+// returned by keptFuncs, and the targets CHA may resolve them to among the
+// functions of cg, the call graph of prog before its synthetic functions are
+// deleted.
+func describeDispatch(prog *ssa.Program, cg *callgraph.Graph, funcs map[*ssa.Function]*Func) ([]dynSite, []dynTarget) {
+	var sets typeutil.Map // method sets by type
+	methodSet := func(T types.Type) []string {
+		if s, ok := sets.At(T).([]string); ok {
+			return s
+		}
+		ms := prog.MethodSets.MethodSet(T)
+		s := make([]string, 0, ms.Len())
+		for i := 0; i < ms.Len(); i++ {
+			s = append(s, methodString(ms.At(i).Obj().(*types.Func)))
+		}
+		sort.Strings(s)
+		sets.Set(T, s)
+		return s
+	}
+	callOf := func(c *ssa.CallCommon) dynCall {
+		if !c.IsInvoke() {
+			return dynCall{Sig: sigString(c.Signature())}
+		}
+		iface := c.Value.Type().Underlying().(*types.Interface)
+		call := dynCall{Method: c.Method.Id()}
+		for i := 0; i < iface.NumMethods(); i++ {
+			call.Iface = append(call.Iface, methodString(iface.Method(i)))
+		}
+		sort.Strings(call.Iface)
+		return call
+	}
+
+	// bridge returns the kept functions fn stands for through the static
+	// calls of the synthetic wrappers, and the dynamic calls of the wrappers
+	bridge := func(fn *ssa.Function) ([]*Func, []dynCall) {
+		if f := funcs[fn]; f != nil {
+			return []*Func{f}, nil
+		}
+		if !synthetic(fn) {
+			return nil, nil
+		}
+		var kept []*Func
+		var calls []dynCall
+		seen := map[*ssa.Function]bool{fn: true}
+		for stack := []*ssa.Function{fn}; len(stack) > 0; {
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, b := range g.Blocks {
+				for _, instr := range b.Instrs {
+					site, ok := instr.(ssa.CallInstruction)
+					if !ok {
+						continue
+					}
+					c := site.Common()
+					callee := c.StaticCallee()
+					switch {
+					case callee == nil:
+						if _, ok := c.Value.(*ssa.Builtin); !ok {
+							calls = append(calls, callOf(c))
+						}
+					case seen[callee]:
+					case funcs[callee] != nil:
+						seen[callee] = true
+						kept = append(kept, funcs[callee])
+					case synthetic(callee):
+						seen[callee] = true
+						stack = append(stack, callee)
+					}
+				}
+			}
+		}
+		return kept, calls
+	}
+
+	var sites []dynSite
+	var targets []dynTarget
+	for fn := range cg.Nodes {
+		if fn == nil {
+			continue
+		}
+		if caller := funcs[fn]; caller != nil {
+			for _, b := range fn.Blocks {
+				for _, instr := range b.Instrs {
+					site, ok := instr.(ssa.CallInstruction)
+					if !ok {
+						continue
+					}
+					c := site.Common()
+					var calls []dynCall
+					stringer := false
+					if callee := c.StaticCallee(); callee == nil {
+						if _, ok := c.Value.(*ssa.Builtin); ok {
+							continue
+						}
+						calls, stringer = []dynCall{callOf(c)}, stringerCall(site)
+					} else if funcs[callee] == nil {
+						_, calls = bridge(callee)
+					}
+					pos := prog.Fset.PositionFor(site.Pos(), false)
+					for _, call := range calls {
+						sites = append(sites, dynSite{Caller: caller, File: pos.Filename, Line: pos.Line, Stringer: stringer, Call: call})
+					}
+				}
+			}
+		}
+
+		// The functions CHA resolves dynamic calls to, as in chautil
+		recv := fn.Signature.Recv()
+		obj, _ := fn.Object().(*types.Func)
+		if recv != nil && obj == nil || recv == nil && fn.Name() == "init" && fn.Synthetic == "package initializer" {
+			continue
+		}
+		t := dynTarget{wrapper: funcs[fn] == nil}
+		if t.Funcs, t.Calls = bridge(fn); len(t.Funcs) == 0 && len(t.Calls) == 0 {
+			continue
+		}
+		if recv != nil {
+			t.Method, t.Recv, t.Uses = obj.Id(), methodSet(recv.Type()), typePkgs(recv.Type())
+		} else {
+			t.Sig, t.Uses = sigString(fn.Signature), funcPkgs(fn)
+		}
+		targets = append(targets, t)
+	}
+	return sites, targets
+}
+
+// funcPkgs returns the packages fn depends on: its own, and those of the
+// type arguments of the instance of a generic function it is or belongs to.
+func funcPkgs(fn *ssa.Function) []string {
+	pkgs := make(map[string]bool)
+	for p := fn; p != nil; p = p.Parent() {
+		if p.Pkg != nil {
+			pkgs[p.Pkg.Pkg.Path()] = true
+		} else if obj := p.Object(); obj != nil && obj.Pkg() != nil {
+			pkgs[obj.Pkg().Path()] = true
+		}
+		w := typeWriter{pkgs: pkgs}
+		for _, t := range p.TypeArgs() {
+			w.typ(t)
+		}
+	}
+	return sortedKeys(pkgs)
+}
+
+// typePkgs returns the packages of the named types t is made of.
+func typePkgs(t types.Type) []string {
+	w := typeWriter{pkgs: make(map[string]bool)}
+	w.typ(t)
+	return sortedKeys(w.pkgs)
+}
+
+// sortedKeys returns the keys of set in order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// methodString writes m as an entry of a method set: its Id and signature.
+func methodString(m *types.Func) string {
+	return m.Id() + " " + sigString(m.Type().(*types.Signature))
+}
+
+// sigString writes sig without its receiver.
+func sigString(sig *types.Signature) string {
+	var w typeWriter
+	w.sig(sig)
+	return w.String()
+}
+
+// typeWriter writes types the same way in every program they are identical
+// in, as types.Identical compares them: without parameter names, with the
+// package paths of named types and unexported fields. Types declared inside
+// functions are named after their package only, and so are type parameters,
+// which those of the same name in other functions of the package are taken
+// for. It notes the packages of the named types in pkgs, if not nil.
+type typeWriter struct {
+	strings.Builder
+	pkgs map[string]bool
+}
+
+func (w *typeWriter) typ(t types.Type) {
+	switch t := t.(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != nil {
+			if w.pkgs != nil {
+				w.pkgs[obj.Pkg().Path()] = true
+			}
+			w.WriteString(obj.Pkg().Path() + ".")
+		}
+		w.WriteString(obj.Name())
+		if args := t.TypeArgs(); args.Len() > 0 {
+			w.WriteString("[")
+			for i := 0; i < args.Len(); i++ {
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				w.typ(args.At(i))
+			}
+			w.WriteString("]")
+		}
+	case *types.Alias:
+		w.typ(types.Unalias(t))
+	case *types.TypeParam:
+		if obj := t.Obj(); obj.Pkg() != nil {
+			w.WriteString(obj.Pkg().Path() + ".")
+		}
+		fmt.Fprintf(w, "%s$%d", t.Obj().Name(), t.Index())
+	case *types.Basic:
+		// byte and rune as uint8 and int32, which they are identical to
+		w.WriteString(types.Typ[t.Kind()].Name())
+	case *types.Pointer:
+		w.WriteString("*")
+		w.typ(t.Elem())
+	case *types.Slice:
+		w.WriteString("[]")
+		w.typ(t.Elem())
+	case *types.Array:
+		fmt.Fprintf(w, "[%d]", t.Len())
+		w.typ(t.Elem())
+	case *types.Map:
+		w.WriteString("map[")
+		w.typ(t.Key())
+		w.WriteString("]")
+		w.typ(t.Elem())
+	case *types.Chan:
+		switch t.Dir() {
+		case types.SendOnly:
+			w.WriteString("chan<- ")
+		case types.RecvOnly:
+			w.WriteString("<-chan ")
+		default:
+			w.WriteString("chan ")
+		}
+		w.typ(t.Elem())
+	case *types.Signature:
+		w.sig(t)
+	case *types.Struct:
+		w.WriteString("struct{")
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if f.Embedded() {
+				w.WriteString("embedded ")
+			}
+			w.WriteString(f.Id() + " ")
+			w.typ(f.Type())
+			if tag := t.Tag(i); tag != "" {
+				fmt.Fprintf(w, " %q", tag)
+			}
+			w.WriteString("; ")
+		}
+		w.WriteString("}")
+	case *types.Interface:
+		w.WriteString("interface{")
+		for i := 0; i < t.NumMethods(); i++ {
+			w.WriteString(t.Method(i).Id() + " ")
+			w.sig(t.Method(i).Type().(*types.Signature))
+			w.WriteString("; ")
+		}
+		if !t.IsMethodSet() {
+			// The type set of a constraint, as types prints it
+			for i := 0; i < t.NumEmbeddeds(); i++ {
+				w.WriteString(t.EmbeddedType(i).String() + "; ")
+			}
+		}
+		w.WriteString("}")
+	case *types.Tuple:
+		w.WriteString("(")
+		for i := 0; i < t.Len(); i++ {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			w.typ(t.At(i).Type())
+		}
+		w.WriteString(")")
+	default:
+		w.WriteString(t.String())
+	}
+}
+
+// sig writes a signature without its receiver and parameter names.
+func (w *typeWriter) sig(sig *types.Signature) {
+	w.WriteString("func")
+	if tparams := sig.TypeParams(); tparams.Len() > 0 {
+		w.WriteString("[")
+		for i := 0; i < tparams.Len(); i++ {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			w.typ(tparams.At(i).Constraint())
+		}
+		w.WriteString("]")
+	}
+	params := sig.Params()
+	w.WriteString("(")
+	for i := 0; i < params.Len(); i++ {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		if sig.Variadic() && i == params.Len()-1 {
+			w.WriteString("...")
+			w.typ(params.At(i).Type().(*types.Slice).Elem())
+			continue
+		}
+		w.typ(params.At(i).Type())
+	}
+	w.WriteString(")")
+	if sig.Results().Len() > 0 {
+		w.WriteString(" ")
+		w.typ(sig.Results())
+	}
+}
+
+// subset reports whether every entry of the sorted set a is in the sorted set
+// b.
+func subset(a, b []string) bool {
+	j := 0
+	for _, s := range a {
+		for j < len(b) && b[j] < s {
+			j++
+		}
+		if j == len(b) || b[j] != s {
+			return false
+		}
+		j++
+	}
+	return true
+}
+
+// dispatcher resolves dynamic calls against targets like CHA does, to patch
+// the calls between functions described from different programs.
+type dispatcher struct {
+	byMethod map[string][]*dynTarget
+	bySig    map[string][]*dynTarget
+	resolved map[string][]*Func
+}
+
+// newDispatcher indexes targets.
+func newDispatcher(targets []dynTarget) *dispatcher {
+	d := &dispatcher{byMethod: make(map[string][]*dynTarget), bySig: make(map[string][]*dynTarget), resolved: make(map[string][]*Func)}
+	for i := range targets {
+		t := &targets[i]
+		if t.Method != "" {
+			d.byMethod[t.Method] = append(d.byMethod[t.Method], t)
+		} else {
+			d.bySig[t.Sig] = append(d.bySig[t.Sig], t)
+		}
+	}
+	return d
+}
+
+// resolve returns the kept functions c may call, through the wrappers it may
+// call too.
+func (d *dispatcher) resolve(c dynCall) []*Func {
+	key := c.key()
+	if fns, ok := d.resolved[key]; ok {
+		return fns
+	}
+	seen := make(map[*Func]bool)
+	var fns []*Func
+	visited := map[string]bool{key: true}
+	var visit func(c dynCall)
+	visit = func(c dynCall) {
+		candidates := d.bySig[c.Sig]
+		if c.Method != "" {
+			candidates = d.byMethod[c.Method]
+		}
+		for _, t := range candidates {
+			if c.Method != "" && !subset(c.Iface, t.Recv) {
+				continue
+			}
+			for _, fn := range t.Funcs {
+				if !seen[fn] {
+					seen[fn] = true
+					fns = append(fns, fn)
+				}
+			}
+			for _, inner := range t.Calls {
+				if k := inner.key(); !visited[k] {
+					visited[k] = true
+					visit(inner)
+				}
+			}
+		}
+	}
+	visit(c)
+	d.resolved[key] = fns
+	return fns
+}
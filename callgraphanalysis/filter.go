@@ -0,0 +1,152 @@
+package callgraphanalysis
+
+import "regexp"
+
+// Avoiding returns the functions of graph that paths avoiding a function
+// whose full name matches avoid may go through: those whose full name doesn't match avoid, and the sources and
+// sinks, which are the ends of the paths.
+func Avoiding(graph *Graph, avoid *regexp.Regexp, sourceFuncs, sinkFuncs map[*Func]bool) map[*Func]bool {
+	keep := make(map[*Func]bool, len(graph.Funcs))
+	for _, fn := range graph.Funcs {
+		if sourceFuncs[fn] || sinkFuncs[fn] || !avoid.MatchString(fn.Function) {
+			keep[fn] = true
+		}
+	}
+	return keep
+}
+
+// ThroughVia returns the graph of the paths going through a function whose
+// full name matches via, with the sources and sinks to search it between.
+// Every function is in it twice: itself, for before the path went through
+// via, and a copy, for after. Copies hold the same data as the function, so
+// the paths found read as paths of g.
+func ThroughVia(g map[*Func]map[*Func]bool, via *regexp.Regexp, sourceFuncs, sinkFuncs map[*Func]bool) (vg map[*Func]map[*Func]bool, sources, sinks map[*Func]bool) {
+	return through(g, func(fn *Func) bool { return via.MatchString(fn.Function) }, sourceFuncs, sinkFuncs)
+}
+
+// through is ThroughVia for the functions on which match is true.
+func through(g map[*Func]map[*Func]bool, match func(fn *Func) bool, sourceFuncs, sinkFuncs map[*Func]bool) (vg map[*Func]map[*Func]bool, sources, sinks map[*Func]bool) {
+	after := make(map[*Func]*Func)
+	copyOf := func(fn *Func) *Func {
+		c, ok := after[fn]
+		if !ok {
+			c = new(Func)
+			*c = *fn
+			after[fn] = c
+		}
+		return c
+	}
+	vg = make(map[*Func]map[*Func]bool)
+	add := func(caller, callee *Func) {
+		if vg[caller] == nil {
+			vg[caller] = make(map[*Func]bool)
+		}
+		vg[caller][callee] = true
+	}
+	for caller, callees := range g {
+		for callee := range callees {
+			if match(callee) {
+				add(caller, copyOf(callee))
+			} else {
+				add(caller, callee)
+			}
+			add(copyOf(caller), copyOf(callee))
+		}
+	}
+
+	sources = make(map[*Func]bool, len(sourceFuncs))
+	for fn := range sourceFuncs {
+		if match(fn) {
+			sources[copyOf(fn)] = true
+		} else {
+			sources[fn] = true
+		}
+	}
+	sinks = make(map[*Func]bool, len(sinkFuncs))
+	for fn := range sinkFuncs {
+		sinks[copyOf(fn)] = true
+	}
+	return vg, sources, sinks
+}
+
+// DropPairs returns g without the calls between the functions of pairs, by
+// full name.
+func DropPairs(g map[*Func]map[*Func]bool, pairs map[[2]string]bool) map[*Func]map[*Func]bool {
+	sub := make(map[*Func]map[*Func]bool, len(g))
+	for caller, callees := range g {
+		for callee := range callees {
+			if pairs[[2]string{caller.Function, callee.Function}] {
+				continue
+			}
+			if sub[caller] == nil {
+				sub[caller] = make(map[*Func]bool)
+			}
+			sub[caller][callee] = true
+		}
+	}
+	return sub
+}
+
+// RemovePairs removes from g the calls between the functions of pairs, by
+// full name, like DropPairs but without a copy of g, and returns the calls
+// removed.
+func RemovePairs(g map[*Func]map[*Func]bool, pairs map[[2]string]bool) map[*Func]map[*Func]bool {
+	removed := make(map[*Func]map[*Func]bool)
+	for caller, callees := range g {
+		for callee := range callees {
+			if !pairs[[2]string{caller.Function, callee.Function}] {
+				continue
+			}
+			delete(callees, callee)
+			if removed[caller] == nil {
+				removed[caller] = make(map[*Func]bool)
+			}
+			removed[caller][callee] = true
+		}
+	}
+	return removed
+}
+
+// SuppressedPairs counts the source and sink pairs connected in full but not
+// in g.
+func SuppressedPairs(full, g map[*Func]map[*Func]bool, sourceFuncs, sinkFuncs map[*Func]bool) int {
+	return suppressedPairs(sourceFuncs, sinkFuncs, []map[*Func]map[*Func]bool{full}, []map[*Func]map[*Func]bool{g})
+}
+
+// SuppressedByRemoval counts the source and sink pairs connected in g only
+// with the calls removed from it by RemovePairs.
+func SuppressedByRemoval(g, removed map[*Func]map[*Func]bool, sourceFuncs, sinkFuncs map[*Func]bool) int {
+	return suppressedPairs(sourceFuncs, sinkFuncs, []map[*Func]map[*Func]bool{g, removed}, []map[*Func]map[*Func]bool{g})
+}
+
+// suppressedPairs counts the source and sink pairs connected by the union of
+// the graphs of before but not by that of after.
+func suppressedPairs(sourceFuncs, sinkFuncs map[*Func]bool, before, after []map[*Func]map[*Func]bool) int {
+	reached := func(adjs []map[*Func]map[*Func]bool, src *Func) map[*Func]bool {
+		seen := map[*Func]bool{src: true}
+		stack := []*Func{src}
+		for len(stack) > 0 {
+			fn := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, adj := range adjs {
+				for next := range adj[fn] {
+					if !seen[next] {
+						seen[next] = true
+						stack = append(stack, next)
+					}
+				}
+			}
+		}
+		return seen
+	}
+	n := 0
+	for src := range sourceFuncs {
+		connected, still := reached(before, src), reached(after, src)
+		for sink := range sinkFuncs {
+			if connected[sink] && !still[sink] {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,171 @@
+// Package gonumgraph exposes a call graph through the interfaces of
+// gonum.org/v1/gonum/graph, to run the algorithms of gonum over it, e.g.
+// centrality from network, communities from community or flows from flow.
+package gonumgraph
+
+import (
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/iterator"
+)
+
+// Node is a function of the graph. Its ID is its index in declaration
+// order.
+type Node struct {
+	*callgraphanalysis.Func
+	id int64
+}
+
+// ID returns the ID of the node in the graph.
+func (n *Node) ID() int64 { return n.id }
+
+// Edge is a call between two functions, with every call site of the
+// caller to the callee. Its weight is the number of call sites.
+type Edge struct {
+	F, T  *Node
+	Calls []callgraphanalysis.Edge
+}
+
+// From returns the caller.
+func (e *Edge) From() graph.Node { return e.F }
+
+// To returns the callee.
+func (e *Edge) To() graph.Node { return e.T }
+
+// ReversedEdge returns the edge from the callee to the caller.
+func (e *Edge) ReversedEdge() graph.Edge { return &Edge{F: e.T, T: e.F, Calls: e.Calls} }
+
+// Weight returns the number of call sites of the edge.
+func (e *Edge) Weight() float64 { return float64(len(e.Calls)) }
+
+// Graph is a call graph as a gonum graph.WeightedDirected. It is immutable:
+// changes to the callgraphanalysis.Graph it was made from are not seen.
+type Graph struct {
+	nodes []*Node
+	byFn  map[*callgraphanalysis.Func]*Node
+	from  map[int64]map[int64]*Edge
+	to    map[int64]map[int64]*Edge
+}
+
+var _ graph.WeightedDirected = (*Graph)(nil)
+
+// New returns the gonum graph of g, with every function of g as a node and
+// the calls between them as edges. A graph pruned by the filters of the
+// Analyzer is exposed as pruned.
+func New(g *callgraphanalysis.Graph) *Graph {
+	gg := &Graph{
+		byFn: make(map[*callgraphanalysis.Func]*Node, len(g.Funcs)),
+		from: make(map[int64]map[int64]*Edge),
+		to:   make(map[int64]map[int64]*Edge),
+	}
+	for _, fn := range g.Funcs {
+		gg.node(fn)
+	}
+	for _, e := range g.Edges {
+		f, t := gg.node(e.Caller), gg.node(e.Callee)
+		edge := gg.from[f.id][t.id]
+		if edge == nil {
+			edge = &Edge{F: f, T: t}
+			if gg.from[f.id] == nil {
+				gg.from[f.id] = make(map[int64]*Edge)
+			}
+			if gg.to[t.id] == nil {
+				gg.to[t.id] = make(map[int64]*Edge)
+			}
+			gg.from[f.id][t.id], gg.to[t.id][f.id] = edge, edge
+		}
+		edge.Calls = append(edge.Calls, e)
+	}
+	return gg
+}
+
+// node returns the node of fn, added if new.
+func (g *Graph) node(fn *callgraphanalysis.Func) *Node {
+	if n, ok := g.byFn[fn]; ok {
+		return n
+	}
+	n := &Node{Func: fn, id: int64(len(g.nodes))}
+	g.nodes = append(g.nodes, n)
+	g.byFn[fn] = n
+	return n
+}
+
+// NodeOf returns the node of fn, nil if fn is not in the graph.
+func (g *Graph) NodeOf(fn *callgraphanalysis.Func) *Node {
+	return g.byFn[fn]
+}
+
+// Node returns the node with id, nil if there is none.
+func (g *Graph) Node(id int64) graph.Node {
+	if id < 0 || id >= int64(len(g.nodes)) {
+		return nil
+	}
+	return g.nodes[id]
+}
+
+// Nodes returns every node, by ID.
+func (g *Graph) Nodes() graph.Nodes {
+	nodes := make([]graph.Node, len(g.nodes))
+	for i, n := range g.nodes {
+		nodes[i] = n
+	}
+	return iterator.NewOrderedNodes(nodes)
+}
+
+// From returns the functions called by the node with id, by ID.
+func (g *Graph) From(id int64) graph.Nodes {
+	return g.adjacent(g.from[id])
+}
+
+// To returns the functions calling the node with id, by ID.
+func (g *Graph) To(id int64) graph.Nodes {
+	return g.adjacent(g.to[id])
+}
+
+// adjacent returns the nodes of the IDs of edges, by ID.
+func (g *Graph) adjacent(edges map[int64]*Edge) graph.Nodes {
+	if len(edges) == 0 {
+		return graph.Empty
+	}
+	nodes := make(map[int64]graph.Node, len(edges))
+	for id := range edges {
+		nodes[id] = g.nodes[id]
+	}
+	return iterator.NewLazyOrderedNodes(nodes)
+}
+
+// HasEdgeBetween reports whether either function calls the other.
+func (g *Graph) HasEdgeBetween(xid, yid int64) bool {
+	return g.HasEdgeFromTo(xid, yid) || g.HasEdgeFromTo(yid, xid)
+}
+
+// HasEdgeFromTo reports whether the function with uid calls the one with
+// vid.
+func (g *Graph) HasEdgeFromTo(uid, vid int64) bool {
+	return g.from[uid][vid] != nil
+}
+
+// Edge returns the call from the function with uid to the one with vid, nil
+// if there is none.
+func (g *Graph) Edge(uid, vid int64) graph.Edge {
+	return g.WeightedEdge(uid, vid)
+}
+
+// WeightedEdge returns the call from the function with uid to the one with
+// vid, nil if there is none.
+func (g *Graph) WeightedEdge(uid, vid int64) graph.WeightedEdge {
+	if e := g.from[uid][vid]; e != nil {
+		return e
+	}
+	return nil
+}
+
+// Weight returns the number of call sites from the function with xid to
+// the one with yid, and whether there is a call. The weight of a function
+// to itself is 0 unless it is recursive.
+func (g *Graph) Weight(xid, yid int64) (float64, bool) {
+	if e := g.from[xid][yid]; e != nil {
+		return e.Weight(), true
+	}
+	return 0, xid == yid
+}
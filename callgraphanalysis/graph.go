@@ -0,0 +1,523 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Func is a node of the call graph: a function of the module.
+type Func struct {
+	Name     string // short name, e.g. SaveV2 or persist$1
+	Function string // full name, e.g. educabot.com/ted/internal/usecases.SaveV2
+	Pkg      string // import path of the declaring package
+	File     string // absolute path of the declaring file, "" for synthesized functions
+	Line     int
+	Origin   *Position // where a //line directive maps the declaration to, nil if none
+}
+
+// Position is a line of a source file. For generated files, it is the
+// position in the template or definition they were generated from.
+type Position struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// Edge is a call from Caller to Callee, or the creation of the anonymous
+// function Callee inside Caller, at File:Line. Stringer marks the calls to a
+// String or Error method through an interface, which CHA connects to every
+// implementation.
+type Edge struct {
+	Caller, Callee *Func
+	File           string
+	Line           int
+	Stringer       bool
+}
+
+// ExternalCall is a call from Caller to Callee, the full name of a function
+// of another module of the organization, at File:Line.
+type ExternalCall struct {
+	Caller *Func
+	Callee string
+	File   string
+	Line   int
+}
+
+// Graph is the call graph of the module after pruning, detached from the
+// SSA program it was built from, with the calls it makes into the other
+// modules of the organization.
+type Graph struct {
+	Funcs    []*Func
+	Edges    []Edge
+	External []ExternalCall
+
+	adjOnce          sync.Once
+	callees, callers map[*Func]map[*Func]bool // built by Callees and Callers
+}
+
+// BuildGraph computes the CHA call graph of prog, as loaded by Load, and
+// keeps the functions of the module, and of the IncludeDep packages, that the
+// Filters keep, with the calls between them the EdgeFilters keep, which it
+// passes to the EdgeHooks. With LowMemory, it resolves the calls of the kept
+// functions only, so that the CHA call graph of the whole program is never
+// held in memory. It stops with the error of ctx once ctx is done.
+func (a *Analyzer) BuildGraph(ctx context.Context, prog *ssa.Program) (*Graph, error) {
+	a.progress(PhaseGraph, 0, 0)
+	g, err := a.buildGraph(ctx, prog)
+	if err != nil {
+		return nil, err
+	}
+	a.progress(PhaseGraph, len(g.Funcs), len(g.Funcs))
+	return g, nil
+}
+
+// buildGraph is BuildGraph, without reporting its progress.
+func (a *Analyzer) buildGraph(ctx context.Context, prog *ssa.Program) (*Graph, error) {
+	if a.LowMemory {
+		return a.streamGraph(ctx, prog)
+	}
+	cg, err := callGraph(ctx, prog)
+	if err != nil {
+		return nil, err
+	}
+	return a.graphOf(ctx, prog, cg)
+}
+
+// callGraph generates the CHA call graph of prog, without the synthetic
+// functions.
+func callGraph(ctx context.Context, prog *ssa.Program) (*callgraph.Graph, error) {
+	cg := cha.CallGraph(prog)
+	cg.DeleteSyntheticNodes()
+	return cg, ctx.Err()
+}
+
+// graphOf keeps the graph of a from cg, the call graph of prog, which it
+// leaves unchanged.
+func (a *Analyzer) graphOf(ctx context.Context, prog *ssa.Program, cg *callgraph.Graph) (*Graph, error) {
+	funcs, graph, err := a.keptFuncs(ctx, prog, nodeFuncs(cg))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.keptCalls(ctx, prog, cg, funcs, graph); err != nil {
+		return nil, err
+	}
+	graph.sort()
+	return graph, nil
+}
+
+// synthetic reports whether DeleteSyntheticNodes deletes fn from a call
+// graph: a wrapper, thunk or bound method closure synthesized by SSA.
+func synthetic(fn *ssa.Function) bool {
+	return fn.Syntax() == nil && (fn.Pkg == nil || fn.Pkg.Func("init") != fn)
+}
+
+// nodeFuncs returns the functions of the nodes of cg.
+func nodeFuncs(cg *callgraph.Graph) []*ssa.Function {
+	fns := make([]*ssa.Function, 0, len(cg.Nodes))
+	for fn := range cg.Nodes {
+		if fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+	return fns
+}
+
+// keptFuncs returns the functions of fns that a keeps, by SSA function, and
+// the graph holding them. Synthetic functions are skipped, as callGraph
+// deletes them.
+func (a *Analyzer) keptFuncs(ctx context.Context, prog *ssa.Program, fns []*ssa.Function) (map[*ssa.Function]*Func, *Graph, error) {
+	// Describe the functions kept, in parallel as the filters and positions
+	// of every function of the program add up
+	fns = slices.DeleteFunc(fns, synthetic)
+	described := make([]*Func, len(fns))
+	parallel(len(fns), func(i int) { described[i] = a.node(prog, fns[i]) })
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	graph := &Graph{}
+	funcs := make(map[*ssa.Function]*Func)
+	byName := make(map[string]*Func)
+	for i, fn := range fns {
+		// With Tests, a package under test is also loaded with its test
+		// files, which declares its functions a second time
+		if f, ok := byName[fn.String()]; ok {
+			if f != nil {
+				funcs[fn] = f
+			}
+			continue
+		}
+		f := described[i]
+		byName[fn.String()] = f
+		if f == nil {
+			continue
+		}
+		funcs[fn] = f
+		graph.Funcs = append(graph.Funcs, f)
+	}
+	return funcs, graph, nil
+}
+
+// keptCalls adds to graph the calls of cg between the functions of funcs, as
+// returned by keptFuncs, and the calls they make into the other modules of
+// the organization.
+func (a *Analyzer) keptCalls(ctx context.Context, prog *ssa.Program, cg *callgraph.Graph, funcs map[*ssa.Function]*Func, graph *Graph) error {
+	// Note the calls into other modules of the organization before pruning
+	// their functions
+	for fn, node := range cg.Nodes {
+		if funcs[fn] == nil || !a.InModule(fn.String()) {
+			continue
+		}
+		for _, out := range node.Out {
+			callee := out.Callee.Func
+			if callee == nil || callee.Pkg == nil || a.InModule(callee.String()) || a.OrgPrefix == "" || !strings.HasPrefix(callee.Pkg.Pkg.Path(), a.OrgPrefix) {
+				continue
+			}
+			pos := prog.Fset.PositionFor(out.Pos(), false)
+			graph.External = append(graph.External, ExternalCall{Caller: funcs[fn], Callee: callee.String(), File: pos.Filename, Line: pos.Line})
+		}
+	}
+
+	// Keep every edge with the position it originates from
+	seen := make(map[Edge]bool)
+	err := callgraph.GraphVisitEdges(cg, func(edge *callgraph.Edge) error {
+		caller := funcs[edge.Caller.Func]
+		callee := funcs[edge.Callee.Func]
+
+		// check that both caller and callee are in the graph
+		if caller == nil || callee == nil {
+			return ctx.Err()
+		}
+		pos := prog.Fset.PositionFor(edge.Pos(), false)
+		e := Edge{Caller: caller, Callee: callee, File: pos.Filename, Line: pos.Line, Stringer: stringerCall(edge.Site)}
+		a.addEdge(graph, seen, e, edge.Site)
+		return ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
+	return a.anonEdges(ctx, funcs, graph, seen)
+}
+
+// anonEdges adds to graph the edges from the functions of funcs to the
+// anonymous functions they declare, unless in seen.
+func (a *Analyzer) anonEdges(ctx context.Context, funcs map[*ssa.Function]*Func, graph *Graph, seen map[Edge]bool) error {
+	// Index the anonymous functions of the module, nested ones included, by
+	// the name of the named function declaring them, the part of their name
+	// before the first $
+	anons := make(map[string][]*Func)
+	for fn, f := range funcs {
+		name := fn.String()
+		if parent, _, ok := strings.Cut(name, "$"); ok && a.InModule(name) {
+			anons[parent] = append(anons[parent], f)
+		}
+	}
+	for fn, f := range funcs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		funcName := fn.String()
+		// Check if this is a named function of the module that might have
+		// anonymous functions
+		if !a.InModule(funcName) || strings.Contains(funcName, "$") {
+			continue
+		}
+		for _, anon := range anons[funcName] {
+			// Add edge from the named function to its anonymous function
+			e := Edge{Caller: f, Callee: anon, File: anon.File, Line: anon.Line}
+			a.addEdge(graph, seen, e, nil)
+		}
+	}
+	return nil
+}
+
+// node describes fn as a function of the graph, or returns nil when it is not
+// kept: it is not a function of the module, of a vendored module of the
+// organization or of an IncludeDep package, or a filter drops it.
+func (a *Analyzer) node(prog *ssa.Program, fn *ssa.Function) *Func {
+	own := a.InModule(fn.String())
+	// Instances of generic functions belong to the package of their origin
+	pkg := fn.Pkg
+	if fn.Origin() != nil {
+		pkg = fn.Origin().Pkg
+	}
+	var path string
+	if pkg != nil {
+		path = pkg.Pkg.Path()
+	}
+	switch {
+	case own, a.vendoredPkg(path):
+	case pkg == nil, a.IncludeDep == nil || !a.IncludeDep(path):
+		return nil
+	}
+
+	// Files are matched against the sources and sinks as they are on disk,
+	// so //line directives only set the origin
+	pos := prog.Fset.PositionFor(fn.Pos(), false)
+	name, full := funcName(fn)
+	f := &Func{Name: name, Function: full, Pkg: path, File: pos.Filename, Line: pos.Line}
+	if orig := prog.Fset.Position(fn.Pos()); orig.Filename != pos.Filename || orig.Line != pos.Line {
+		f.Origin = &Position{File: orig.Filename, Line: orig.Line}
+	}
+	if !a.keep(f) {
+		return nil
+	}
+	return f
+}
+
+// funcName returns the name of fn and its full name, fn.Name() and
+// fn.String() but for the type arguments of the instances of generic
+// functions, written without their aliases. ssa names an instance after the
+// type arguments of the package creating it first, so that the same instance
+// is slices.SortFunc[[]os.DirEntry os.DirEntry] or
+// slices.SortFunc[[]io/fs.DirEntry io/fs.DirEntry] depending on the order the
+// packages are built in.
+func funcName(fn *ssa.Function) (name, full string) {
+	if parent := fn.Parent(); parent != nil {
+		name, full = funcName(parent)
+		suffix := fn.Name()[strings.LastIndex(fn.Name(), "$"):]
+		return name + suffix, full + suffix
+	}
+	targs := fn.TypeArgs()
+	if !slices.ContainsFunc(targs, hasAlias) {
+		return fn.Name(), fn.String()
+	}
+	args := make([]types.Type, len(targs))
+	for i, t := range targs {
+		args[i] = unalias(t)
+	}
+	name = fn.Origin().Name() + fmt.Sprint(args)
+	if recv := fn.Signature.Recv(); recv != nil {
+		return name, fmt.Sprintf("(%s).%s", types.TypeString(unalias(recv.Type()), nil), name)
+	}
+	return name, fn.Origin().Pkg.Pkg.Path() + "." + name
+}
+
+// hasAlias reports whether t is written with an alias, as far as unalias
+// removes them.
+func hasAlias(t types.Type) bool {
+	return unalias(t) != t
+}
+
+// unalias returns t without the aliases it is written with, in the types it
+// is made of but for those of function, struct and interface types.
+func unalias(t types.Type) types.Type {
+	switch u := t.(type) {
+	case *types.Alias:
+		return unalias(types.Unalias(u))
+	case *types.Pointer:
+		if elem := unalias(u.Elem()); elem != u.Elem() {
+			return types.NewPointer(elem)
+		}
+	case *types.Slice:
+		if elem := unalias(u.Elem()); elem != u.Elem() {
+			return types.NewSlice(elem)
+		}
+	case *types.Array:
+		if elem := unalias(u.Elem()); elem != u.Elem() {
+			return types.NewArray(elem, u.Len())
+		}
+	case *types.Chan:
+		if elem := unalias(u.Elem()); elem != u.Elem() {
+			return types.NewChan(u.Dir(), elem)
+		}
+	case *types.Map:
+		key, elem := unalias(u.Key()), unalias(u.Elem())
+		if key != u.Key() || elem != u.Elem() {
+			return types.NewMap(key, elem)
+		}
+	case *types.Named:
+		targs := u.TypeArgs()
+		if targs == nil {
+			return t
+		}
+		args := make([]types.Type, targs.Len())
+		changed := false
+		for i := range args {
+			args[i] = unalias(targs.At(i))
+			changed = changed || args[i] != targs.At(i)
+		}
+		if changed {
+			if inst, err := types.Instantiate(nil, u.Origin(), args, false); err == nil {
+				return inst
+			}
+		}
+	}
+	return t
+}
+
+// stringerCall reports whether site calls a String or Error method, as in
+// fmt.Stringer and error, through an interface.
+func stringerCall(site ssa.CallInstruction) bool {
+	if site == nil || !site.Common().IsInvoke() {
+		return false
+	}
+	m := site.Common().Method
+	sig := m.Type().(*types.Signature)
+	return (m.Name() == "String" || m.Name() == "Error") && sig.Params().Len() == 0 &&
+		sig.Results().Len() == 1 && types.Identical(sig.Results().At(0).Type(), types.Typ[types.String])
+}
+
+// sort orders the functions by declaration position and the edges by caller,
+// callee and position, since the call graph is built in map order, dropping
+// the edges found twice.
+func (g *Graph) sort() {
+	sort.Slice(g.Funcs, func(i, j int) bool { return FuncLess(g.Funcs[i], g.Funcs[j]) })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.Caller != b.Caller {
+			return FuncLess(a.Caller, b.Caller)
+		}
+		if a.Callee != b.Callee {
+			return FuncLess(a.Callee, b.Callee)
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	sort.Slice(g.External, func(i, j int) bool {
+		a, b := g.External[i], g.External[j]
+		if a.Caller != b.Caller {
+			return FuncLess(a.Caller, b.Caller)
+		}
+		if a.Callee != b.Callee {
+			return a.Callee < b.Callee
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	g.Edges = slices.Compact(g.Edges)
+	g.External = slices.Compact(g.External)
+}
+
+// FuncLess orders functions by file, line and full name.
+func FuncLess(a, b *Func) bool {
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Function < b.Function
+}
+
+// SortFuncs returns the functions of set ordered by declaration position.
+func SortFuncs(set map[*Func]bool) []*Func {
+	funcs := make([]*Func, 0, len(set))
+	for fn := range set {
+		funcs = append(funcs, fn)
+	}
+	sort.Slice(funcs, func(i, j int) bool { return FuncLess(funcs[i], funcs[j]) })
+	return funcs
+}
+
+// StringerPairs returns the callers and callees, by full name, only
+// connected by Stringer edges.
+func (g *Graph) StringerPairs() map[[2]string]bool {
+	pairs := make(map[[2]string]bool)
+	other := make(map[[2]string]bool)
+	for _, e := range g.Edges {
+		pair := [2]string{e.Caller.Function, e.Callee.Function}
+		if e.Stringer {
+			pairs[pair] = true
+		} else {
+			other[pair] = true
+		}
+	}
+	for pair := range other {
+		delete(pairs, pair)
+	}
+	return pairs
+}
+
+// CallSites returns the position of the first call from each caller to each
+// callee, by full names, in edge order. Calls through String or Error
+// methods are only used when there are no others.
+func (g *Graph) CallSites() map[[2]string]Position {
+	sites := make(map[[2]string]Position)
+	stringer := make(map[[2]string]bool)
+	for _, e := range g.Edges {
+		pair := [2]string{e.Caller.Function, e.Callee.Function}
+		if _, ok := sites[pair]; ok && (e.Stringer || !stringer[pair]) {
+			continue
+		}
+		sites[pair] = Position{File: e.File, Line: e.Line}
+		stringer[pair] = e.Stringer
+	}
+	return sites
+}
+
+// Packages returns the sorted package paths of the functions of g.
+func (g *Graph) Packages() []string {
+	seen := make(map[string]bool)
+	var pkgs []string
+	for _, fn := range g.Funcs {
+		if !seen[fn.Pkg] {
+			seen[fn.Pkg] = true
+			pkgs = append(pkgs, fn.Pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// Adjacency returns the reachability graph as adjacency sets.
+func (g *Graph) Adjacency() map[*Func]map[*Func]bool {
+	adj := make(map[*Func]map[*Func]bool)
+	for _, e := range g.Edges {
+		if adj[e.Caller] == nil {
+			adj[e.Caller] = make(map[*Func]bool)
+		}
+		adj[e.Caller][e.Callee] = true
+	}
+	return adj
+}
+
+// Callees returns the functions each function of g calls, and Callers the
+// functions calling each one. Both are built together on first use and kept
+// with g, so that questions from either end of a call don't walk the edges
+// again; they are shared by every caller, safely from several goroutines,
+// and must not be modified, nor the edges of g after the first call. Prune a
+// copy from Adjacency instead.
+func (g *Graph) Callees() map[*Func]map[*Func]bool {
+	g.adjOnce.Do(g.index)
+	return g.callees
+}
+
+// Callers returns the reverse of Callees, as Callees does.
+func (g *Graph) Callers() map[*Func]map[*Func]bool {
+	g.adjOnce.Do(g.index)
+	return g.callers
+}
+
+// index builds the adjacencies of Callees and Callers.
+func (g *Graph) index() {
+	g.callees = g.Adjacency()
+	g.callers = Reverse(g.callees)
+}
+
+// Reverse returns the reverse of the adjacency g: the callers of each
+// function.
+func Reverse(g map[*Func]map[*Func]bool) map[*Func]map[*Func]bool {
+	rev := make(map[*Func]map[*Func]bool)
+	for caller, callees := range g {
+		for callee := range callees {
+			if rev[callee] == nil {
+				rev[callee] = make(map[*Func]bool)
+			}
+			rev[callee][caller] = true
+		}
+	}
+	return rev
+}
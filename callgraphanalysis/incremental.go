@@ -0,0 +1,642 @@
+package callgraphanalysis
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// incrementalVersion is the version of the state IncrementalGraph stores,
+// bumped when its format changes.
+const incrementalVersion = 2
+
+// IncrementalGraph returns the graph BuildGraph keeps from the program Load
+// loads, rebuilding only what changed since the state it stored in cache
+// under key, which identifies the module and the configuration but not their
+// content. It loads the packages whose files changed, with those importing
+// them, patches the cached graph with their functions and calls, and stores
+// the new state. Without a state, or once go.mod, go.sum, the vendored
+// modules or the Go version changed, it builds the whole graph.
+//
+// CHA resolves the calls through interfaces and function values against the
+// whole program, so the state describes the dynamic calls and the methods and
+// functions they may reach, which are matched again across the packages
+// loaded and the others. Types declared inside functions are told apart by
+// name only, so their methods may get a few more calls than in a full build,
+// and instances of generic functions no package needs any more are kept.
+// Modules analyzed together and Tests always build the whole graph.
+//
+// The EdgeHooks see every edge of the graph, with a nil site for those
+// patched from the state. It stops with the error of ctx once ctx is done.
+func (a *Analyzer) IncrementalGraph(ctx context.Context, cache Cache, key string) (*Graph, error) {
+	cfg, patterns, cleanup, err := a.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if len(a.workspace) > 0 || a.Tests {
+		a.logf("Building the whole graph: only a single module without tests is rebuilt incrementally")
+		prog, err := loadProgram(ctx, cfg, patterns, a.built(), a.progress)
+		if err != nil {
+			return nil, err
+		}
+		return a.BuildGraph(ctx, prog)
+	}
+
+	hashes, importers, err := listPackages(cfg, patterns)
+	if err != nil {
+		return nil, err
+	}
+	deps, err := a.depsHash(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	old := a.readState(ctx, cache, key, deps)
+
+	// Reload the packages changed, added or removed, and those importing
+	// them, whose calls, wrappers and instances they may change
+	var stale map[string]bool
+	if old != nil {
+		stale = make(map[string]bool)
+		var queue []string
+		for path, h := range hashes {
+			if old.packages[path] != h {
+				stale[path] = true
+				queue = append(queue, path)
+			}
+		}
+		for path := range old.packages {
+			if _, ok := hashes[path]; !ok {
+				stale[path] = true
+			}
+		}
+		if len(stale) == 0 {
+			a.logf("No package changed since the cached graph of %d functions and %d edges", len(old.graph.Funcs), len(old.graph.Edges))
+			a.hookEdges(old.graph, nil)
+			return old.graph, nil
+		}
+		changed := len(stale)
+		for len(queue) > 0 {
+			path := queue[0]
+			queue = queue[1:]
+			for _, imp := range importers[path] {
+				if !stale[imp] {
+					stale[imp] = true
+					queue = append(queue, imp)
+				}
+			}
+		}
+		patterns = nil
+		for path := range stale {
+			if _, ok := hashes[path]; ok {
+				patterns = append(patterns, path)
+			}
+		}
+		sort.Strings(patterns)
+		a.logf("Rebuilding %d of %d packages: %d changed since the cached graph, and those importing them", len(patterns), len(hashes), changed)
+	}
+
+	var st *incrementalState
+	if len(patterns) == 0 {
+		// Only removed packages, which nothing imports
+		st = old.patch(a, &incrementalState{graph: &Graph{}, dynamic: make(map[Edge]bool), uses: make(map[*Func][]string)}, stale)
+	} else {
+		prog, err := loadProgram(ctx, cfg, patterns, a.built(), a.progress)
+		if err != nil {
+			return nil, err
+		}
+		if st, err = a.buildState(ctx, prog); err != nil {
+			return nil, err
+		}
+		if old != nil {
+			st = old.patch(a, st, stale)
+		}
+	}
+	st.deps, st.packages = deps, hashes
+	a.progress(PhaseGraph, len(st.graph.Funcs), len(st.graph.Funcs))
+	a.hookEdges(st.graph, st.calls)
+	if data, err := st.encode(); err != nil {
+		a.logf("Encoding the incremental state: %v", err)
+	} else if err := cache.Put(ctx, key, data); err != nil {
+		a.logf("Storing the incremental state in the cache: %v", err)
+	}
+	return st.graph, nil
+}
+
+// hookEdges passes the edges of g to the EdgeHooks, with their call from
+// calls.
+func (a *Analyzer) hookEdges(g *Graph, calls map[Edge]ssa.CallInstruction) {
+	if len(a.EdgeHooks) == 0 {
+		return
+	}
+	for _, e := range g.Edges {
+		for _, hook := range a.EdgeHooks {
+			hook(e, calls[e])
+		}
+	}
+}
+
+// listPackages lists the packages matching patterns with the configuration
+// of cfg, returning the hash of the files of each, and the packages importing
+// each, by import path.
+func listPackages(cfg *packages.Config, patterns []string) (map[string]string, map[string][]string, error) {
+	lcfg := &packages.Config{
+		Context:    cfg.Context,
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedImports,
+		Dir:        cfg.Dir,
+		Env:        cfg.Env,
+		BuildFlags: cfg.BuildFlags,
+	}
+	pkgs, err := packages.Load(lcfg, patterns...)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make(map[string]string, len(pkgs))
+	importers := make(map[string][]string)
+	for _, p := range pkgs {
+		h := sha256.New()
+		files := append(append([]string(nil), p.GoFiles...), p.OtherFiles...)
+		sort.Strings(files)
+		for _, name := range files {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00", filepath.Base(name), len(data))
+			h.Write(data)
+		}
+		hashes[p.PkgPath] = hex.EncodeToString(h.Sum(nil))
+		for path := range p.Imports {
+			importers[path] = append(importers[path], p.PkgPath)
+		}
+	}
+	return hashes, importers, nil
+}
+
+// depsHash hashes what the packages depend on besides their files: the
+// go.mod and go.sum files of the module, its vendored modules and the
+// version of Go.
+func (a *Analyzer) depsHash(ctx context.Context, cfg *packages.Config) (string, error) {
+	h := sha256.New()
+	for _, name := range []string{"go.mod", "go.sum", filepath.Join("vendor", "modules.txt")} {
+		data, err := os.ReadFile(filepath.Join(a.Dir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+	}
+	cmd := exec.CommandContext(ctx, "go", "env", "GOVERSION")
+	cmd.Dir, cmd.Env = a.Dir, cfg.Env
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go env GOVERSION: %v", err)
+	}
+	h.Write(out)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// incrementalState is the graph of the module with what IncrementalGraph
+// needs to patch it: the hashes it was built from, which edges are dynamic
+// calls and the description of those calls and their targets.
+type incrementalState struct {
+	deps     string            // hash of the dependencies, by depsHash
+	packages map[string]string // hash of the files of every package
+	graph    *Graph
+	dynamic  map[Edge]bool      // edges of dynamic calls
+	uses     map[*Func][]string // packages the functions depend on, when not only their own
+	sites    []dynSite
+	targets  []dynTarget
+
+	calls map[Edge]ssa.CallInstruction // calls of the edges built from SSA, for the EdgeHooks
+}
+
+// buildState builds the graph of prog and describes its dynamic calls.
+func (a *Analyzer) buildState(ctx context.Context, prog *ssa.Program) (*incrementalState, error) {
+	st := &incrementalState{
+		dynamic: make(map[Edge]bool),
+		uses:    make(map[*Func][]string),
+		calls:   make(map[Edge]ssa.CallInstruction),
+	}
+	// The hooks of a are called once the graph is patched
+	b := *a
+	b.EdgeHooks = []EdgeHook{func(e Edge, site ssa.CallInstruction) {
+		st.calls[e] = site
+		if site != nil && site.Common().StaticCallee() == nil {
+			st.dynamic[e] = true
+		}
+	}}
+
+	a.progress(PhaseGraph, 0, 0)
+	cg := cha.CallGraph(prog)
+	funcs, graph, err := b.keptFuncs(ctx, prog, nodeFuncs(cg))
+	if err != nil {
+		return nil, err
+	}
+	st.sites, st.targets = describeDispatch(prog, cg, funcs)
+	for fn, f := range funcs {
+		if uses := funcPkgs(fn); len(uses) > 1 {
+			st.uses[f] = uses
+		}
+	}
+	cg.DeleteSyntheticNodes()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := b.keptCalls(ctx, prog, cg, funcs, graph); err != nil {
+		return nil, err
+	}
+	graph.sort()
+	st.graph = graph
+	return st, nil
+}
+
+// patch returns the state of the whole module from st, the state of the
+// stale packages and those they import, and old, the previous state of the
+// module: the functions of old outside the stale packages, and their static
+// calls, are added to those of st, and the dynamic calls of every function
+// are resolved again against every target.
+func (old *incrementalState) patch(a *Analyzer, st *incrementalState, stale map[string]bool) *incrementalState {
+	old.absorb(st, func(fn *Func) bool {
+		if stale[fn.Pkg] {
+			return false
+		}
+		for _, p := range old.uses[fn] {
+			if stale[p] {
+				return false
+			}
+		}
+		return true
+	}, stale)
+	a.resolveDynamic(st)
+	return st
+}
+
+// absorb adds to st the functions of old it lacks among those valid reports,
+// their static calls and external calls, and the sites and targets of their
+// dynamic calls, but for the targets depending on a stale package. Functions
+// are matched by full name.
+func (old *incrementalState) absorb(st *incrementalState, valid func(fn *Func) bool, stale map[string]bool) {
+	funcs := make(map[string]*Func, len(old.graph.Funcs))
+	for _, fn := range st.graph.Funcs {
+		funcs[fn.Function] = fn
+	}
+	current := func(fn *Func) *Func {
+		if !valid(fn) {
+			return nil
+		}
+		return funcs[fn.Function]
+	}
+	for _, fn := range old.graph.Funcs {
+		if valid(fn) && funcs[fn.Function] == nil {
+			funcs[fn.Function] = fn
+			st.graph.Funcs = append(st.graph.Funcs, fn)
+			if uses := old.uses[fn]; uses != nil {
+				st.uses[fn] = uses
+			}
+		}
+	}
+
+	seen := make(map[Edge]bool, len(st.graph.Edges))
+	for _, e := range st.graph.Edges {
+		seen[e] = true
+	}
+	for _, e := range old.graph.Edges {
+		caller, callee := current(e.Caller), current(e.Callee)
+		if caller == nil || callee == nil || old.dynamic[e] {
+			continue
+		}
+		e.Caller, e.Callee = caller, callee
+		if !seen[e] {
+			seen[e] = true
+			st.graph.Edges = append(st.graph.Edges, e)
+		}
+	}
+	for _, c := range old.graph.External {
+		if c.Caller = current(c.Caller); c.Caller != nil {
+			st.graph.External = append(st.graph.External, c)
+		}
+	}
+
+	// The sites and targets of the functions kept, once
+	siteKeys := make(map[string]bool)
+	for _, s := range st.sites {
+		siteKeys[s.key()] = true
+	}
+	for _, s := range old.sites {
+		if s.Caller = current(s.Caller); s.Caller != nil && !siteKeys[s.key()] {
+			siteKeys[s.key()] = true
+			st.sites = append(st.sites, s)
+		}
+	}
+	targetKeys := make(map[string]bool)
+	for _, t := range st.targets {
+		targetKeys[t.key()] = true
+	}
+targets:
+	for _, t := range old.targets {
+		for _, p := range t.Uses {
+			if stale[p] {
+				continue targets
+			}
+		}
+		fns := make([]*Func, 0, len(t.Funcs))
+		for _, fn := range t.Funcs {
+			if fn = current(fn); fn == nil {
+				continue targets
+			}
+			fns = append(fns, fn)
+		}
+		t.Funcs = fns
+		if !targetKeys[t.key()] {
+			targetKeys[t.key()] = true
+			st.targets = append(st.targets, t)
+		}
+	}
+}
+
+// resolveDynamic adds to the graph of st the calls of its dynamic sites to
+// the functions of its targets, as CHA resolves them, and sorts it.
+func (a *Analyzer) resolveDynamic(st *incrementalState) {
+	seen := make(map[Edge]bool, len(st.graph.Edges))
+	for _, e := range st.graph.Edges {
+		seen[e] = true
+	}
+	d := newDispatcher(st.targets)
+	for _, s := range st.sites {
+		for _, callee := range d.resolve(s.Call) {
+			e := Edge{Caller: s.Caller, Callee: callee, File: s.File, Line: s.Line, Stringer: s.Stringer}
+			if !seen[e] && a.keepEdge(e) {
+				seen[e] = true
+				st.graph.Edges = append(st.graph.Edges, e)
+				st.dynamic[e] = true
+			}
+		}
+	}
+	st.graph.sort()
+}
+
+// key identifies s among the sites.
+func (s dynSite) key() string {
+	return fmt.Sprintf("%s\x00%s\x00%d\x00%s", s.Caller.Function, s.File, s.Line, s.Call.key())
+}
+
+// key identifies t among the targets.
+func (t dynTarget) key() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%s\x00%s", t.Method, t.Sig, strings.Join(t.Recv, "\x00"))
+	for _, fn := range t.Funcs {
+		b.WriteString("\x00" + fn.Function)
+	}
+	for _, c := range t.Calls {
+		b.WriteString("\x00" + c.key())
+	}
+	return b.String()
+}
+
+// incrementalFile is the serialized form of an incrementalState: functions
+// are referenced by index in Funcs, and method sets by index in Sets.
+type incrementalFile struct {
+	Version  int
+	Deps     string
+	Packages map[string]string
+	Funcs    []Func
+	Uses     map[int][]string
+	Edges    []incrementalEdge
+	External []incrementalExternal
+	Sets     [][]string
+	Sites    []incrementalSite
+	Targets  []incrementalTarget
+}
+
+type incrementalEdge struct {
+	Caller, Callee    int
+	File              string
+	Line              int
+	Stringer, Dynamic bool
+}
+
+type incrementalExternal struct {
+	Caller int
+	Callee string
+	File   string
+	Line   int
+}
+
+type incrementalCall struct {
+	Method string
+	Iface  int
+	Sig    string
+}
+
+type incrementalSite struct {
+	Caller   int
+	File     string
+	Line     int
+	Stringer bool
+	Call     incrementalCall
+}
+
+type incrementalTarget struct {
+	Method string
+	Recv   int
+	Sig    string
+	Uses   []string
+	Funcs  []int
+	Calls  []incrementalCall
+}
+
+// encode serializes st as gob.
+func (st *incrementalState) encode() ([]byte, error) {
+	f := incrementalFile{Version: incrementalVersion, Deps: st.deps, Packages: st.packages, Uses: make(map[int][]string)}
+	index := make(map[*Func]int, len(st.graph.Funcs))
+	for i, fn := range st.graph.Funcs {
+		index[fn] = i
+		f.Funcs = append(f.Funcs, *fn)
+		if uses := st.uses[fn]; uses != nil {
+			f.Uses[i] = uses
+		}
+	}
+	sets := make(map[string]int)
+	set := func(s []string) int {
+		key := strings.Join(s, "\x00")
+		i, ok := sets[key]
+		if !ok {
+			i = len(f.Sets)
+			sets[key] = i
+			f.Sets = append(f.Sets, s)
+		}
+		return i
+	}
+	call := func(c dynCall) incrementalCall {
+		ic := incrementalCall{Method: c.Method, Sig: c.Sig, Iface: -1}
+		if c.Method != "" {
+			ic.Iface = set(c.Iface)
+		}
+		return ic
+	}
+	for _, e := range st.graph.Edges {
+		f.Edges = append(f.Edges, incrementalEdge{Caller: index[e.Caller], Callee: index[e.Callee], File: e.File, Line: e.Line, Stringer: e.Stringer, Dynamic: st.dynamic[e]})
+	}
+	for _, c := range st.graph.External {
+		f.External = append(f.External, incrementalExternal{Caller: index[c.Caller], Callee: c.Callee, File: c.File, Line: c.Line})
+	}
+	for _, s := range st.sites {
+		f.Sites = append(f.Sites, incrementalSite{Caller: index[s.Caller], File: s.File, Line: s.Line, Stringer: s.Stringer, Call: call(s.Call)})
+	}
+	for _, t := range st.targets {
+		it := incrementalTarget{Method: t.Method, Sig: t.Sig, Uses: t.Uses, Recv: -1}
+		if t.Method != "" {
+			it.Recv = set(t.Recv)
+		}
+		for _, fn := range t.Funcs {
+			it.Funcs = append(it.Funcs, index[fn])
+		}
+		for _, c := range t.Calls {
+			it.Calls = append(it.Calls, call(c))
+		}
+		f.Targets = append(f.Targets, it)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeState reads a state written by encode.
+func decodeState(r io.Reader) (*incrementalState, error) {
+	var f incrementalFile
+	if err := gob.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.Version != incrementalVersion {
+		return nil, fmt.Errorf("state of version %d, not %d", f.Version, incrementalVersion)
+	}
+	corrupt := errors.New("corrupt incremental state")
+	st := &incrementalState{
+		deps:     f.Deps,
+		packages: f.Packages,
+		graph:    &Graph{Funcs: make([]*Func, len(f.Funcs))},
+		dynamic:  make(map[Edge]bool),
+		uses:     make(map[*Func][]string),
+	}
+	for i := range f.Funcs {
+		st.graph.Funcs[i] = &f.Funcs[i]
+	}
+	fn := func(i int) *Func {
+		if i < 0 || i >= len(st.graph.Funcs) {
+			return nil
+		}
+		return st.graph.Funcs[i]
+	}
+	set := func(i int) ([]string, bool) {
+		if i < 0 || i >= len(f.Sets) {
+			return nil, false
+		}
+		return f.Sets[i], true
+	}
+	call := func(ic incrementalCall) (dynCall, bool) {
+		c := dynCall{Method: ic.Method, Sig: ic.Sig}
+		if c.Method == "" {
+			return c, true
+		}
+		var ok bool
+		c.Iface, ok = set(ic.Iface)
+		return c, ok
+	}
+	for i, uses := range f.Uses {
+		if fn(i) == nil {
+			return nil, corrupt
+		}
+		st.uses[fn(i)] = uses
+	}
+	for _, ie := range f.Edges {
+		e := Edge{Caller: fn(ie.Caller), Callee: fn(ie.Callee), File: ie.File, Line: ie.Line, Stringer: ie.Stringer}
+		if e.Caller == nil || e.Callee == nil {
+			return nil, corrupt
+		}
+		st.graph.Edges = append(st.graph.Edges, e)
+		if ie.Dynamic {
+			st.dynamic[e] = true
+		}
+	}
+	for _, ic := range f.External {
+		c := ExternalCall{Caller: fn(ic.Caller), Callee: ic.Callee, File: ic.File, Line: ic.Line}
+		if c.Caller == nil {
+			return nil, corrupt
+		}
+		st.graph.External = append(st.graph.External, c)
+	}
+	for _, is := range f.Sites {
+		c, ok := call(is.Call)
+		s := dynSite{Caller: fn(is.Caller), File: is.File, Line: is.Line, Stringer: is.Stringer, Call: c}
+		if !ok || s.Caller == nil {
+			return nil, corrupt
+		}
+		st.sites = append(st.sites, s)
+	}
+	for _, it := range f.Targets {
+		t := dynTarget{Method: it.Method, Sig: it.Sig, Uses: it.Uses}
+		if t.Method != "" {
+			var ok bool
+			if t.Recv, ok = set(it.Recv); !ok {
+				return nil, corrupt
+			}
+		}
+		for _, i := range it.Funcs {
+			if fn(i) == nil {
+				return nil, corrupt
+			}
+			t.Funcs = append(t.Funcs, fn(i))
+		}
+		for _, ic := range it.Calls {
+			c, ok := call(ic)
+			if !ok {
+				return nil, corrupt
+			}
+			t.Calls = append(t.Calls, c)
+		}
+		st.targets = append(st.targets, t)
+	}
+	return st, nil
+}
+
+// readState returns the state stored in cache under key, nil if there is
+// none, it can't be read or it was built with other dependencies than deps.
+func (a *Analyzer) readState(ctx context.Context, cache Cache, key, deps string) *incrementalState {
+	data, ok, err := cache.Get(ctx, key)
+	if err != nil {
+		a.logf("Reading the incremental state from the cache: %v", err)
+		return nil
+	}
+	if !ok {
+		a.logf("No incremental state in the cache, building the whole graph")
+		return nil
+	}
+	st, err := decodeState(bytes.NewReader(data))
+	if err != nil {
+		a.logf("Building the whole graph: %v", err)
+		return nil
+	}
+	if st.deps != deps {
+		a.logf("Building the whole graph: the dependencies or the Go version changed since the cached graph")
+		return nil
+	}
+	return st
+}
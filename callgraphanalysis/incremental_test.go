@@ -0,0 +1,137 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// edges returns the calls of g, sorted, by caller, callee and position.
+func edges(g *Graph) []string {
+	var out []string
+	for _, e := range g.Edges {
+		out = append(out, fmt.Sprintf("%s -> %s at %s:%d", e.Caller.Function, e.Callee.Function, e.File, e.Line))
+	}
+	slices.Sort(out)
+	return out
+}
+
+// TestIncrementalGraphMatchesFullBuild checks that the graph patched from the
+// cached state has the calls of the graph built from scratch, through
+// interfaces and function values too, as the module is edited.
+func TestIncrementalGraphMatchesFullBuild(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/shapes\n\ngo 1.21\n",
+		"shape/shape.go": `package shape
+
+type Shape interface{ Area() float64 }
+
+func Total(shapes []Shape) (total float64) {
+	for _, s := range shapes {
+		total += s.Area()
+	}
+	return total
+}
+
+var Jobs []func()
+
+func RunJobs() {
+	for _, job := range Jobs {
+		job()
+	}
+}
+`,
+		"square/square.go": `package square
+
+type Square struct{ Side float64 }
+
+func (s Square) Area() float64 { return s.Side * s.Side }
+`,
+		"store/store.go": `package store
+
+func Put(v float64) {}
+`,
+	})
+	ctx := context.Background()
+	cache := DirCache{Dir: t.TempDir()}
+
+	for _, step := range []struct {
+		name  string
+		files map[string]string
+	}{
+		{"first build", nil},
+		{"method edited", map[string]string{
+			"square/square.go": `package square
+
+import "example.com/shapes/store"
+
+type Square struct{ Side float64 }
+
+func (s Square) Area() float64 {
+	store.Put(s.Side)
+	return s.Side * s.Side
+}
+`,
+		}},
+		{"implementation added", map[string]string{
+			"circle/circle.go": `package circle
+
+import "example.com/shapes/store"
+
+type Circle struct{ R float64 }
+
+func (c *Circle) Area() float64 {
+	store.Put(c.R)
+	return 3 * c.R * c.R
+}
+`,
+		}},
+		{"function value added", map[string]string{
+			"jobs/jobs.go": `package jobs
+
+import (
+	"example.com/shapes/shape"
+	"example.com/shapes/store"
+)
+
+func flush() { store.Put(0) }
+
+func init() { shape.Jobs = append(shape.Jobs, flush) }
+`,
+		}},
+		{"implementation removed", map[string]string{"square/square.go": ""}},
+	} {
+		for name, content := range step.files {
+			if content == "" {
+				if err := os.Remove(filepath.Join(dir, name)); err != nil {
+					t.Fatal(err)
+				}
+				delete(step.files, name)
+			}
+		}
+		writeFiles(t, dir, step.files)
+
+		a := &Analyzer{Dir: dir, Module: "example.com/shapes"}
+		inc, err := a.IncrementalGraph(ctx, cache, "shapes")
+		if err != nil {
+			t.Fatalf("%s: IncrementalGraph: %v", step.name, err)
+		}
+		a = &Analyzer{Dir: dir, Module: "example.com/shapes"}
+		prog, err := a.Load(ctx)
+		if err != nil {
+			t.Fatalf("%s: Load: %v", step.name, err)
+		}
+		full, err := a.BuildGraph(ctx, prog)
+		if err != nil {
+			t.Fatalf("%s: BuildGraph: %v", step.name, err)
+		}
+		if got, want := edges(inc), edges(full); !slices.Equal(got, want) {
+			t.Errorf("%s: incremental calls\n\t%v\nwant those of the full build\n\t%v", step.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package callgraphanalysis
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// goModule is a Go module found under the analyzed directory or used by its
+// workspace.
+type goModule struct {
+	dir, path, goVersion string
+}
+
+// vendoredModules returns the paths of the modules vendored in dir, listed
+// in vendor/modules.txt, and whether it vendors its dependencies.
+func vendoredModules(dir string) ([]string, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "vendor", "modules.txt"))
+	if err != nil {
+		return nil, false
+	}
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		// A module is introduced by "# path version", or "# path => dir"
+		// for its replacement
+		if f := strings.Fields(line); len(f) >= 3 && f[0] == "#" && !slices.Contains(paths, f[1]) {
+			paths = append(paths, f[1])
+		}
+	}
+	return paths, true
+}
+
+// vendoredPkg reports whether the package path belongs to a module of the
+// organization vendored by the analyzed one.
+func (a *Analyzer) vendoredPkg(path string) bool {
+	for _, m := range a.vendored {
+		if path == m || strings.HasPrefix(path, m+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// InModule reports whether the function or package of full name name belongs
+// to the analyzed module, or to one of the modules of the workspace: its
+// package, or that of its receiver, is the module root or below it, so
+// example.com/svc-client is not in example.com/svc. An instance of a generic
+// function belongs to the module of a type argument too, as it calls the
+// methods and function values of the type, like the comparisons passed to
+// slices.SortFunc.
+func (a *Analyzer) InModule(name string) bool {
+	modules := a.workspace
+	if len(modules) == 0 {
+		modules = []string{a.Module}
+	}
+	for _, ident := range strings.FieldsFunc(name, notInPath) {
+		for _, m := range modules {
+			if ofModule(ident, m) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notInPath reports whether r is not part of an import path or of a
+// qualified identifier, separating the ones of a full function name such as
+// (*example.com/p.T).M or slices.Sort[[]example.com/p.T].
+func notInPath(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("._-~/", r)
+}
+
+// ofModule reports whether ident, a package path or an identifier qualified
+// by one like example.com/m/p.T, is of the module of path m.
+func ofModule(ident, m string) bool {
+	rest, ok := strings.CutPrefix(ident, m)
+	switch {
+	case !ok:
+		return false
+	case rest == "" || rest[0] == '/':
+		return true
+	default:
+		// A name of the root package, not a module path going on after a
+		// dot, like example.com/m.v2/p
+		return rest[0] == '.' && !strings.Contains(rest, "/")
+	}
+}
+
+// ModuleRoot reports whether path is the root package of the analyzed module
+// or of one of the modules of the workspace.
+func (a *Analyzer) ModuleRoot(path string) bool {
+	return path == a.Module || slices.Contains(a.workspace, path)
+}
+
+// ModulePath returns the module path declared in the go.mod of dir, or "" if
+// it has none.
+func ModulePath(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	return modfile.ModulePath(data)
+}
+
+// Module is a Go module found under a directory without go.mod: its
+// absolute directory and its path.
+type Module struct {
+	Dir, Path string
+}
+
+// FindModules returns the Go modules Load analyzes together under dir when
+// dir has no go.mod, none when it has one.
+func FindModules(dir string) ([]Module, error) {
+	found, err := findModules(dir)
+	var modules []Module
+	for _, m := range found {
+		modules = append(modules, Module{Dir: m.dir, Path: m.path})
+	}
+	return modules, err
+}
+
+// findModules returns the Go modules under dir when dir itself has no go.mod,
+// as in repositories mixing languages where the Go code lives in
+// subdirectories. It returns none when dir has a go.mod.
+func findModules(dir string) ([]goModule, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var modules []goModule
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if base := d.Name(); name != dir && (base == "vendor" || base == "testdata" || base == "node_modules" || strings.HasPrefix(base, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "go.mod" {
+			return nil
+		}
+		m, err := readModule(filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		modules = append(modules, m)
+		return nil
+	})
+	return modules, err
+}
+
+// readModule describes the module of the go.mod in dir.
+func readModule(dir string) (goModule, error) {
+	name := filepath.Join(dir, "go.mod")
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return goModule{}, err
+	}
+	f, err := modfile.ParseLax(name, data, nil)
+	if err != nil {
+		return goModule{}, err
+	}
+	if f.Module == nil {
+		return goModule{}, fmt.Errorf("%s: no module directive", name)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return goModule{}, err
+	}
+	m := goModule{dir: abs, path: f.Module.Mod.Path}
+	if f.Go != nil {
+		m.goVersion = f.Go.Version
+	}
+	return m, nil
+}
+
+// workspaceModules returns the modules of the go.work used in dir, if any:
+// the one in dir or a parent directory, or the one of GOWORK.
+func workspaceModules(dir string) (string, []goModule, error) {
+	cmd := exec.Command("go", "env", "GOWORK")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("go env GOWORK: %v", err)
+	}
+	name := strings.TrimSpace(string(out))
+	if name == "" || name == "off" {
+		return "", nil, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", nil, err
+	}
+	work, err := modfile.ParseWork(name, data, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	var modules []goModule
+	for _, use := range work.Use {
+		modDir := use.Path
+		if !filepath.IsAbs(modDir) {
+			modDir = filepath.Join(filepath.Dir(name), modDir)
+		}
+		gomod := filepath.Join(modDir, "go.mod")
+		data, err := os.ReadFile(gomod)
+		if err != nil {
+			return "", nil, err
+		}
+		path := modfile.ModulePath(data)
+		if path == "" {
+			return "", nil, fmt.Errorf("%s: no module directive", gomod)
+		}
+		modules = append(modules, goModule{dir: modDir, path: path})
+	}
+	return name, modules, nil
+}
+
+// writeWorkspace writes a go.work in a temporary directory using modules, so
+// that they are loaded into one program. The caller removes the directory.
+func writeWorkspace(modules []goModule) (string, error) {
+	work := new(modfile.WorkFile)
+	work.Syntax = new(modfile.FileSyntax)
+	goVersion := "1.18" // the first with workspaces
+	for _, m := range modules {
+		if m.goVersion != "" && semver.Compare("v"+m.goVersion, "v"+goVersion) > 0 {
+			goVersion = m.goVersion
+		}
+		if err := work.AddUse(m.dir, m.path); err != nil {
+			return "", err
+		}
+	}
+	if err := work.AddGoStmt(goVersion); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.MkdirTemp("", "callgraph-work")
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Join(tmp, "go.work")
+	if err := os.WriteFile(name, modfile.Format(work.Syntax), 0o644); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return name, nil
+}
+
+// workspaceFlags returns GOFLAGS without -mod, which workspaces only accept
+// as readonly or vendor.
+func workspaceFlags() string {
+	var flags []string
+	for _, f := range strings.Fields(os.Getenv("GOFLAGS")) {
+		if !strings.HasPrefix(f, "-mod=") {
+			flags = append(flags, f)
+		}
+	}
+	return strings.Join(flags, " ")
+}
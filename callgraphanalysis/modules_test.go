@@ -0,0 +1,45 @@
+package callgraphanalysis
+
+import "testing"
+
+func TestInModule(t *testing.T) {
+	a := &Analyzer{Module: "example.com/svc"}
+	for _, tt := range []struct {
+		name string
+		want bool
+	}{
+		{"example.com/svc", true},
+		{"example.com/svc/internal/store", true},
+		{"example.com/svc.Handle", true},
+		{"example.com/svc/internal/store.Put", true},
+		{"example.com/svc/internal/store.Put$1", true},
+		{"(*example.com/svc/internal/store.DB).Put", true},
+		{"(example.com/svc/internal/store.Key).String", true},
+		{"(*example.com/svc/internal/store.Cache[string]).Get", true},
+		{"slices.SortFunc[[]example.com/svc/api.User example.com/svc/api.User]", true},
+		// Sibling modules sharing the prefix
+		{"example.com/svc-client", false},
+		{"example.com/svc-client/api.Call", false},
+		{"(*example.com/svc2/store.DB).Put", false},
+		{"example.com/svc.v2/store.Put", false},
+		{"slices.SortFunc[[]example.com/svc2/api.User example.com/svc2/api.User]", false},
+		{"example.com/other/svc.Handle", false},
+		{"fmt.Println", false},
+	} {
+		if got := a.InModule(tt.name); got != tt.want {
+			t.Errorf("InModule(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Every module of a workspace
+	a.workspace = []string{"example.com/svc", "example.com/lib"}
+	for name, want := range map[string]bool{
+		"example.com/lib/cache.Get":     true,
+		"example.com/svc/api.Handle":    true,
+		"example.com/library/cache.Get": false,
+	} {
+		if got := a.InModule(name); got != want {
+			t.Errorf("in a workspace, InModule(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sync"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// MultiAnalyzer analyzes several modules in one process. Load loads every
+// module on its own, from the directory of its Analyzer as Analyzer.Load
+// does, so each builds with the versions its go.mod requires, its vendored
+// dependencies and its own Tags, Tests and Env. The files they have in
+// common, those of the standard library and of the dependencies required at
+// the same version, are parsed once for all of them.
+//
+// A module that fails to load, or whose graph fails to build, doesn't stop
+// the others: Load and BuildGraphs return nil in its place, and a
+// *ModuleError for it among the errors they join.
+type MultiAnalyzer struct {
+	Analyzers []*Analyzer
+	// Logf, if not nil, reports how the modules are loaded.
+	Logf func(format string, args ...any)
+	// Progress, if not nil, is called as Load and BuildGraphs go through
+	// their phases for each module in turn, from one goroutine at a time.
+	Progress func(Progress)
+}
+
+// ModuleError is the error of one module of a MultiAnalyzer.
+type ModuleError struct {
+	// Dir is the Dir of the Analyzer of the module.
+	Dir string
+	Err error
+}
+
+func (e *ModuleError) Error() string { return e.Dir + ": " + e.Err.Error() }
+
+func (e *ModuleError) Unwrap() error { return e.Err }
+
+// Load loads the packages of every Analyzer, matching its Patterns, and
+// builds their SSA form, returning the programs in the order of Analyzers.
+// It stops with the error of ctx once ctx is done.
+func (m *MultiAnalyzer) Load(ctx context.Context) ([]*ssa.Program, error) {
+	if len(m.Analyzers) == 0 {
+		return nil, errors.New("no module to analyze")
+	}
+	files := newParseCache()
+	progs := make([]*ssa.Program, len(m.Analyzers))
+	var errs []error
+	for i, a := range m.Analyzers {
+		if m.Logf != nil {
+			m.Logf("Loading module %d of %d: %s", i+1, len(m.Analyzers), a.Dir)
+		}
+		prog, err := m.load(ctx, a, files)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err != nil {
+			errs = append(errs, &ModuleError{Dir: a.Dir, Err: err})
+			continue
+		}
+		progs[i] = prog
+	}
+	return progs, errors.Join(errs...)
+}
+
+// load loads the packages of a with the syntax of files.
+func (m *MultiAnalyzer) load(ctx context.Context, a *Analyzer, files *parseCache) (*ssa.Program, error) {
+	cfg, patterns, cleanup, err := a.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cfg.Fset, cfg.ParseFile = files.fset, files.parse
+	return loadProgram(ctx, cfg, patterns, a.built(), m.progress)
+}
+
+// parseCache parses the files of the modules into one FileSet, once for
+// each name and content, so that the modules share the syntax of the files
+// they have in common.
+type parseCache struct {
+	fset  *token.FileSet
+	mu    sync.Mutex
+	files map[parsedFile]*ast.File
+}
+
+type parsedFile struct {
+	name string
+	sum  [sha256.Size]byte
+}
+
+func newParseCache() *parseCache {
+	return &parseCache{fset: token.NewFileSet(), files: make(map[parsedFile]*ast.File)}
+}
+
+// parse parses a file as packages.Load does, returning the syntax of an
+// earlier load of the same file if any. Like the syntax packages.Load
+// returns, it must not be modified.
+func (c *parseCache) parse(fset *token.FileSet, name string, src []byte) (*ast.File, error) {
+	key := parsedFile{name: name, sum: sha256.Sum256(src)}
+	c.mu.Lock()
+	f, ok := c.files[key]
+	c.mu.Unlock()
+	if ok {
+		return f, nil
+	}
+	f, err := parser.ParseFile(fset, name, src, parser.AllErrors|parser.ParseComments)
+	if err != nil {
+		return f, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if earlier, ok := c.files[key]; ok {
+		return earlier, nil
+	}
+	c.files[key] = f
+	return f, nil
+}
+
+// progress reports Progress through Progress, if set.
+func (m *MultiAnalyzer) progress(phase Phase, done, total int) {
+	if m.Progress != nil {
+		m.Progress(Progress{Phase: phase, Done: done, Total: total})
+	}
+}
+
+// BuildGraphs computes the graph of every Analyzer from its program in
+// progs, as loaded by Load, as BuildGraph does, in the order of Analyzers,
+// with nil for the programs that are nil. It stops with the error of ctx
+// once ctx is done.
+func (m *MultiAnalyzer) BuildGraphs(ctx context.Context, progs []*ssa.Program) ([]*Graph, error) {
+	if len(progs) != len(m.Analyzers) {
+		return nil, fmt.Errorf("%d programs for %d modules", len(progs), len(m.Analyzers))
+	}
+	graphs := make([]*Graph, len(m.Analyzers))
+	var errs []error
+	for i, a := range m.Analyzers {
+		if progs[i] == nil {
+			continue
+		}
+		m.progress(PhaseGraph, 0, 0)
+		g, err := a.buildGraph(ctx, progs[i])
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err != nil {
+			errs = append(errs, &ModuleError{Dir: a.Dir, Err: err})
+			continue
+		}
+		m.progress(PhaseGraph, len(g.Funcs), len(g.Funcs))
+		graphs[i] = g
+	}
+	return graphs, errors.Join(errs...)
+}
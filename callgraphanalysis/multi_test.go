@@ -0,0 +1,64 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestMultiAnalyzerModules checks that every module loads from its own
+// go.mod, even when they require different copies of a dependency, and that
+// a module that doesn't build fails alone.
+func TestMultiAnalyzerModules(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"dep1/go.mod": "module example.com/dep\n\ngo 1.21\n",
+		"dep1/dep.go": "package dep\n\nfunc Old() {}\n",
+		"dep2/go.mod": "module example.com/dep\n\ngo 1.21\n",
+		"dep2/dep.go": "package dep\n\nfunc New() {}\n",
+
+		"billing/go.mod":  "module example.com/billing\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n\nreplace example.com/dep => ../dep1\n",
+		"billing/main.go": "package main\n\nimport \"example.com/dep\"\n\nfunc main() { dep.Old() }\n",
+		"mail/go.mod":     "module example.com/mail\n\ngo 1.21\n\nrequire example.com/dep v1.1.0\n\nreplace example.com/dep => ../dep2\n",
+		"mail/main.go":    "package main\n\nimport \"example.com/dep\"\n\nfunc main() { dep.New() }\n",
+		"broken/go.mod":   "module example.com/broken\n\ngo 1.21\n",
+		"broken/main.go":  "package main\n\nfunc main() { undefined() }\n",
+	})
+	dep := func(path string) bool { return path == "example.com/dep" }
+	multi := &MultiAnalyzer{}
+	for _, name := range []string{"billing", "broken", "mail"} {
+		multi.Analyzers = append(multi.Analyzers, &Analyzer{Dir: filepath.Join(dir, name), Module: "example.com/" + name, IncludeDep: dep})
+	}
+
+	ctx := context.Background()
+	progs, err := multi.Load(ctx)
+	var merr *ModuleError
+	if !errors.As(err, &merr) || merr.Dir != multi.Analyzers[1].Dir || !strings.Contains(err.Error(), "undefined") {
+		t.Fatalf("Load error %v, want the error of the broken module alone", err)
+	}
+	if progs[0] == nil || progs[1] != nil || progs[2] == nil {
+		t.Fatalf("programs %v, want only the broken module without one", progs)
+	}
+	graphs, err := multi.BuildGraphs(ctx, progs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graphs[1] != nil {
+		t.Errorf("graph of the broken module %v, want nil", graphs[1])
+	}
+	for i, want := range map[int]string{0: "example.com/dep.Old", 2: "example.com/dep.New"} {
+		var called []string
+		for _, e := range graphs[i].Edges {
+			if e.Caller.Name == "main" {
+				called = append(called, e.Callee.Function)
+			}
+		}
+		if !slices.Equal(called, []string{want}) {
+			t.Errorf("main of %s calls %v, want %s", multi.Analyzers[i].Module, called, want)
+		}
+	}
+}
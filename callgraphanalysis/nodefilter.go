@@ -0,0 +1,98 @@
+package callgraphanalysis
+
+import "golang.org/x/tools/go/ssa"
+
+// FilterFunc reports whether the function fn is kept in the graph. Filters
+// see fn as it will be in the graph, before its calls are known. BuildGraph
+// calls them from several goroutines at once.
+type FilterFunc func(fn *Func) bool
+
+// EdgeFilterFunc reports whether the call e, between two functions kept, is
+// kept in the graph.
+type EdgeFilterFunc func(e Edge) bool
+
+// EdgeHook observes a call kept in the graph while BuildGraph builds it: the
+// edge, and the SSA instruction making the call, nil for the edges from a
+// function to the anonymous functions it declares. Hooks see every edge of
+// the graph once, in no particular order.
+type EdgeHook func(e Edge, site ssa.CallInstruction)
+
+// All keeps the functions every filter keeps, and every function without
+// filters.
+func All(filters ...FilterFunc) FilterFunc {
+	return func(fn *Func) bool {
+		for _, f := range filters {
+			if !f(fn) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any keeps the functions some filter keeps.
+func Any(filters ...FilterFunc) FilterFunc {
+	return func(fn *Func) bool {
+		for _, f := range filters {
+			if f(fn) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not keeps the functions f drops.
+func Not(f FilterFunc) FilterFunc {
+	return func(fn *Func) bool { return !f(fn) }
+}
+
+// ByPackage keeps the functions whose package import path matches.
+func ByPackage(match func(path string) bool) FilterFunc {
+	return func(fn *Func) bool { return match(fn.Pkg) }
+}
+
+// ByFile keeps the functions declared in a file, by absolute path, that
+// matches.
+func ByFile(match func(filename string) bool) FilterFunc {
+	return func(fn *Func) bool { return match(fn.File) }
+}
+
+// ByFunction keeps the functions whose full name matches. ByFunction of
+// Analyzer.InModule keeps the functions of the module.
+func ByFunction(match func(function string) bool) FilterFunc {
+	return func(fn *Func) bool { return match(fn.Function) }
+}
+
+// keep reports whether every filter of a keeps fn.
+func (a *Analyzer) keep(fn *Func) bool {
+	for _, f := range a.Filters {
+		if !f(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepEdge reports whether every edge filter of a keeps e.
+func (a *Analyzer) keepEdge(e Edge) bool {
+	for _, f := range a.EdgeFilters {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// addEdge adds e to graph, unless it was already added or the edge filters
+// of a drop it, and passes it to the edge hooks.
+func (a *Analyzer) addEdge(graph *Graph, seen map[Edge]bool, e Edge, site ssa.CallInstruction) {
+	if seen[e] || !a.keepEdge(e) {
+		return
+	}
+	seen[e] = true
+	graph.Edges = append(graph.Edges, e)
+	for _, hook := range a.EdgeHooks {
+		hook(e, site)
+	}
+}
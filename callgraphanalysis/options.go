@@ -0,0 +1,161 @@
+package callgraphanalysis
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// Option configures an Analyzer created by New.
+type Option func(*Analyzer) error
+
+// New returns an Analyzer of the module in dir, configured by opts. The
+// module path is read from the go.mod of dir unless WithModule gives it.
+func New(dir string, opts ...Option) (*Analyzer, error) {
+	a := &Analyzer{Dir: dir}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+	if a.Module == "" {
+		if a.Module = ModulePath(dir); a.Module == "" {
+			return nil, fmt.Errorf("no go.mod in %s, the module path is required", dir)
+		}
+	}
+	return a, nil
+}
+
+// WithModule sets the path of the analyzed module.
+func WithModule(path string) Option {
+	return func(a *Analyzer) error {
+		a.Module = path
+		return nil
+	}
+}
+
+// WithOrgPrefix sets the import path prefix of the modules of the
+// organization.
+func WithOrgPrefix(prefix string) Option {
+	return func(a *Analyzer) error {
+		a.OrgPrefix = prefix
+		return nil
+	}
+}
+
+// WithPatterns sets the go list patterns of the packages to load.
+func WithPatterns(patterns ...string) Option {
+	return func(a *Analyzer) error {
+		a.Patterns = patterns
+		return nil
+	}
+}
+
+// WithBuildTags sets the comma-separated build tags to load the packages
+// with.
+func WithBuildTags(tags string) Option {
+	return func(a *Analyzer) error {
+		a.Tags = tags
+		return nil
+	}
+}
+
+// WithIncludeTests loads the _test.go files too when include is true.
+func WithIncludeTests(include bool) Option {
+	return func(a *Analyzer) error {
+		a.Tests = include
+		return nil
+	}
+}
+
+// WithEnv adds variables, e.g. GOOS=linux, to the environment of the go
+// command.
+func WithEnv(env ...string) Option {
+	return func(a *Analyzer) error {
+		a.Env = append(a.Env, env...)
+		return nil
+	}
+}
+
+// WithLogger reports how the packages are loaded to l.
+func WithLogger(l *log.Logger) Option {
+	return func(a *Analyzer) error {
+		a.Logf = l.Printf
+		return nil
+	}
+}
+
+// WithAlgorithm sets how FindPaths searches, and limit the number of paths
+// of KShortestPaths or the maximum of AllPaths (0: no limit).
+func WithAlgorithm(alg Algorithm, limit int) Option {
+	return func(a *Analyzer) error {
+		a.Algorithm, a.PathLimit = alg, limit
+		return nil
+	}
+}
+
+// WithMaxDepth bounds the number of calls of the paths searched.
+func WithMaxDepth(depth int) Option {
+	return func(a *Analyzer) error {
+		a.MaxDepth = depth
+		return nil
+	}
+}
+
+// WithExcludePatterns drops from the graph the functions whose full name
+// matches one of the regular expressions, e.g. /mocks\. for the functions
+// of the mocks packages.
+func WithExcludePatterns(patterns ...string) Option {
+	return func(a *Analyzer) error {
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("exclude pattern: %v", err)
+			}
+			a.Filters = append(a.Filters, Not(ByFunction(re.MatchString)))
+		}
+		return nil
+	}
+}
+
+// WithFilters adds filters of the functions kept in the graph.
+func WithFilters(filters ...FilterFunc) Option {
+	return func(a *Analyzer) error {
+		a.Filters = append(a.Filters, filters...)
+		return nil
+	}
+}
+
+// WithEdgeHooks adds hooks observing the calls kept in the graph.
+func WithEdgeHooks(hooks ...EdgeHook) Option {
+	return func(a *Analyzer) error {
+		a.EdgeHooks = append(a.EdgeHooks, hooks...)
+		return nil
+	}
+}
+
+// WithLowMemory builds the graph without the CHA call graph of the whole
+// program when low is true.
+func WithLowMemory(low bool) Option {
+	return func(a *Analyzer) error {
+		a.LowMemory = low
+		return nil
+	}
+}
+
+// WithDetectors sets the detectors run by Detect instead of every registered
+// one.
+func WithDetectors(detectors ...Detector) Option {
+	return func(a *Analyzer) error {
+		a.Detectors = append([]Detector{}, detectors...)
+		return nil
+	}
+}
+
+// WithProgress calls f as the analysis goes through its phases.
+func WithProgress(f func(Progress)) Option {
+	return func(a *Analyzer) error {
+		a.Progress = f
+		return nil
+	}
+}
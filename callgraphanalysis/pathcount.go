@@ -0,0 +1,42 @@
+package callgraphanalysis
+
+// PathCountCap bounds the path counts, which grow exponentially with the
+// branching of the graph.
+const PathCountCap = 10000
+
+// PathCounts returns the number of paths from src to every component of c,
+// up to PathCountCap, or nil if src calls nothing. The functions of a
+// recursive cycle count as one, so the counts are exact for call chains
+// without recursion, and an estimate of the simple paths through it.
+func (c *Condensation) PathCounts(src *Func) []int {
+	cs, ok := c.comp[src]
+	if !ok {
+		return nil
+	}
+	// Components are numbered in reverse topological order, so the count of
+	// a component is complete once the higher ones are done.
+	counts := make([]int, len(c.succ))
+	counts[cs] = 1
+	for v := cs; v >= 0; v-- {
+		if counts[v] == 0 {
+			continue
+		}
+		for _, w := range c.succ[v] {
+			counts[w] = min(counts[w]+counts[v], PathCountCap)
+		}
+	}
+	return counts
+}
+
+// PathCount returns the number of paths from src to dest given the counts
+// from src, zero when dest is not reachable.
+func (c *Condensation) PathCount(counts []int, src, dest *Func) int {
+	if src == dest {
+		return 1
+	}
+	cd, ok := c.comp[dest]
+	if !ok || counts == nil {
+		return 0
+	}
+	return counts[cd]
+}
@@ -0,0 +1,332 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Algorithm is how FindPaths searches the paths between two functions.
+type Algorithm int
+
+const (
+	// FirstPath finds one path, depth-first.
+	FirstPath Algorithm = iota
+	// ShortestPath finds the path with the fewest calls, breadth-first.
+	ShortestPath
+	// KShortestPaths finds the PathLimit shortest simple paths.
+	KShortestPaths
+	// AllPaths enumerates the simple paths, up to PathLimit.
+	AllPaths
+)
+
+// Budget bounds the searches from one source, in time and in functions
+// visited, and stops them once its context is done.
+type Budget struct {
+	ctx       context.Context
+	deadline  time.Time // zero for no limit
+	visits    int       // functions left to visit, negative for no limit
+	exhausted bool
+}
+
+// NewBudget starts a budget of timeout and visits functions, 0 for no limit,
+// running out when ctx is done.
+func NewBudget(ctx context.Context, timeout time.Duration, visits int) *Budget {
+	b := &Budget{ctx: ctx, visits: -1}
+	if timeout > 0 {
+		b.deadline = time.Now().Add(timeout)
+	}
+	if visits > 0 {
+		b.visits = visits
+	}
+	return b
+}
+
+// Exhausted reports whether a search ran out of the budget.
+func (b *Budget) Exhausted() bool {
+	return b.exhausted
+}
+
+// spend accounts for visiting one function and reports whether the budget
+// allowed it.
+func (b *Budget) spend() bool {
+	if b.exhausted {
+		return false
+	}
+	if b.visits == 0 || !b.deadline.IsZero() && time.Now().After(b.deadline) || b.ctx.Err() != nil {
+		b.exhausted = true
+		return false
+	}
+	if b.visits > 0 {
+		b.visits--
+	}
+	return true
+}
+
+// FindPaths searches the paths from src to dest in graph with the Algorithm
+// of a, in the order it finds them, giving up when budget runs out.
+func (a *Analyzer) FindPaths(src, dest *Func, graph map[*Func]map[*Func]bool, budget *Budget) [][]*Func {
+	switch a.Algorithm {
+	case AllPaths:
+		return a.findAllPaths(src, dest, graph, a.PathLimit, budget)
+	case KShortestPaths:
+		return a.findKShortestPaths(src, dest, graph, a.PathLimit, budget)
+	case ShortestPath:
+		if path := a.FindShortestPath(src, dest, graph, nil, budget); path != nil {
+			return [][]*Func{path}
+		}
+	default:
+		if path := a.findPath(src, dest, graph, budget); path != nil {
+			return [][]*Func{path}
+		}
+	}
+	return nil
+}
+
+// findPath uses DFS to find a path from src to dest, giving up when budget
+// runs out, as firstPaths does.
+func (a *Analyzer) findPath(src, dest *Func, graph map[*Func]map[*Func]bool, budget *Budget) []*Func {
+	return a.firstPaths(src, []*Func{dest}, graph, budget)[dest]
+}
+
+// firstPaths uses DFS to find a path from src to each of dests, by
+// destination, in one traversal, giving up when budget runs out. The search
+// keeps its own stack of calls, so it handles chains of any depth, and takes
+// the path to each destination off that stack when first reaching it, which
+// is the path a search of that destination alone finds. Each function is
+// searched once, except under MaxDepth, where a function reached again in
+// fewer calls is searched again since it has more calls left.
+func (a *Analyzer) firstPaths(src *Func, dests []*Func, graph map[*Func]map[*Func]bool, budget *Budget) map[*Func][]*Func {
+	paths := make(map[*Func][]*Func, len(dests))
+	if !budget.spend() {
+		return paths
+	}
+	left := make(map[*Func]bool, len(dests))
+	for _, dest := range dests {
+		left[dest] = true
+	}
+	if left[src] {
+		paths[src] = []*Func{src}
+		delete(left, src)
+	}
+
+	type frame struct {
+		fn   *Func
+		next []*Func // callees left to search
+	}
+	visited := map[*Func]int{src: 0} // calls from src each function was reached in
+	calls := []frame{{fn: src, next: SortFuncs(graph[src])}}
+	for len(calls) > 0 && len(left) > 0 {
+		f := &calls[len(calls)-1]
+		depth := len(calls) - 1
+		if len(f.next) == 0 || a.MaxDepth > 0 && depth >= a.MaxDepth {
+			calls = calls[:len(calls)-1]
+			continue
+		}
+		next := f.next[0]
+		f.next = f.next[1:]
+		if d, seen := visited[next]; seen && (a.MaxDepth == 0 || depth+1 >= d) {
+			continue
+		}
+		if !budget.spend() {
+			return paths
+		}
+		if left[next] {
+			path := make([]*Func, 0, len(calls)+1)
+			for _, c := range calls {
+				path = append(path, c.fn)
+			}
+			paths[next] = append(path, next)
+			delete(left, next)
+		}
+		visited[next] = depth + 1
+		calls = append(calls, frame{fn: next, next: SortFuncs(graph[next])})
+	}
+	return paths
+}
+
+// FindShortestPath looks for a path from src to dest with the fewest calls,
+// breadth-first, not following the calls for which skip, if not nil, returns
+// true. Neighbors are visited in declaration order so that, among paths of
+// the same length, the same one is reported on every run.
+func (a *Analyzer) FindShortestPath(src, dest *Func, graph map[*Func]map[*Func]bool, skip func(caller, callee *Func) bool, budget *Budget) []*Func {
+	return a.shortestPaths(src, []*Func{dest}, graph, skip, budget)[dest]
+}
+
+// FindShortestPaths looks for the paths with the fewest calls from src to
+// each of dests in one breadth-first traversal, returning the path
+// FindShortestPath finds for each destination reached, by destination.
+func (a *Analyzer) FindShortestPaths(src *Func, dests []*Func, graph map[*Func]map[*Func]bool, budget *Budget) map[*Func][]*Func {
+	return a.shortestPaths(src, dests, graph, nil, budget)
+}
+
+// shortestPaths searches breadth-first from src until every one of dests is
+// reached, recording the caller each function was first reached from to
+// rebuild the paths to them.
+func (a *Analyzer) shortestPaths(src *Func, dests []*Func, graph map[*Func]map[*Func]bool, skip func(caller, callee *Func) bool, budget *Budget) map[*Func][]*Func {
+	paths := make(map[*Func][]*Func, len(dests))
+	left := make(map[*Func]bool, len(dests))
+	for _, dest := range dests {
+		left[dest] = true
+	}
+	parent := map[*Func]*Func{src: nil}
+	depth := map[*Func]int{src: 0}
+	queue := []*Func{src}
+	for len(queue) > 0 && len(left) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		if !budget.spend() {
+			return paths
+		}
+		if left[fn] {
+			var path []*Func
+			for f := fn; f != nil; f = parent[f] {
+				path = append(path, f)
+			}
+			slices.Reverse(path)
+			paths[fn] = path
+			delete(left, fn)
+		}
+		if a.MaxDepth > 0 && depth[fn] >= a.MaxDepth {
+			continue
+		}
+		for _, next := range SortFuncs(graph[fn]) {
+			if _, seen := parent[next]; !seen && (skip == nil || !skip(fn, next)) {
+				parent[next] = fn
+				depth[next] = depth[fn] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return paths
+}
+
+// findKShortestPaths returns up to k simple paths from src to dest by
+// increasing number of calls, with Yen's algorithm: every path after the
+// first is the shortest deviation from a previous one at one of its
+// functions.
+func (a *Analyzer) findKShortestPaths(src, dest *Func, graph map[*Func]map[*Func]bool, k int, budget *Budget) [][]*Func {
+	first := a.FindShortestPath(src, dest, graph, nil, budget)
+	if first == nil {
+		return nil
+	}
+	paths := [][]*Func{first}
+	var candidates [][]*Func
+	known := func(path []*Func) bool {
+		for _, p := range append(paths, candidates...) {
+			if len(p) == len(path) && samePrefix(p, path, len(path)) {
+				return true
+			}
+		}
+		return false
+	}
+	for len(paths) < k {
+		prev := paths[len(paths)-1]
+		for i := 0; i < len(prev)-1; i++ {
+			// Deviate at prev[i]: keep the path up to it, leave out its
+			// functions and the calls taken there by the paths found so far
+			root := prev[:i+1]
+			onRoot := make(map[*Func]bool, i)
+			for _, fn := range root[:i] {
+				onRoot[fn] = true
+			}
+			taken := make(map[*Func]bool)
+			for _, p := range paths {
+				if len(p) > i+1 && samePrefix(p, root, i+1) {
+					taken[p[i+1]] = true
+				}
+			}
+			skip := func(caller, callee *Func) bool {
+				return onRoot[callee] || caller == prev[i] && taken[callee]
+			}
+			spur := a.FindShortestPath(prev[i], dest, graph, skip, budget)
+			if budget.exhausted {
+				return paths
+			}
+			if spur == nil {
+				continue
+			}
+			// The spur is the shortest, so no deviation at prev[i] fits in
+			// MaxDepth if it doesn't
+			path := append(append([]*Func(nil), root[:i]...), spur...)
+			if a.MaxDepth > 0 && len(path)-1 > a.MaxDepth {
+				continue
+			}
+			if !known(path) {
+				candidates = append(candidates, path)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.SliceStable(candidates, func(a, b int) bool { return len(candidates[a]) < len(candidates[b]) })
+		paths = append(paths, candidates[0])
+		candidates = candidates[1:]
+	}
+	return paths
+}
+
+// samePrefix reports whether a and b start with the same n functions.
+func samePrefix(a, b []*Func, n int) bool {
+	if len(a) < n || len(b) < n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// findAllPaths enumerates the simple paths from src to dest, stopping after
+// max paths (0: no limit) or when budget runs out. Like findPath, it keeps its
+// own stack of calls. Neighbors are visited in declaration order so the paths
+// kept under the cap are stable.
+func (a *Analyzer) findAllPaths(src, dest *Func, graph map[*Func]map[*Func]bool, max int, budget *Budget) [][]*Func {
+	if !budget.spend() {
+		return nil
+	}
+	if src == dest {
+		return [][]*Func{{src}}
+	}
+
+	type frame struct {
+		fn   *Func
+		next []*Func // callees left to search
+	}
+	var paths [][]*Func
+	onStack := map[*Func]bool{src: true}
+	calls := []frame{{fn: src, next: SortFuncs(graph[src])}}
+	for len(calls) > 0 {
+		f := &calls[len(calls)-1]
+		if len(f.next) == 0 || a.MaxDepth > 0 && len(calls) > a.MaxDepth {
+			delete(onStack, f.fn)
+			calls = calls[:len(calls)-1]
+			continue
+		}
+		next := f.next[0]
+		f.next = f.next[1:]
+		if onStack[next] {
+			continue
+		}
+		if !budget.spend() {
+			return paths
+		}
+		if next == dest {
+			path := make([]*Func, 0, len(calls)+1)
+			for _, c := range calls {
+				path = append(path, c.fn)
+			}
+			paths = append(paths, append(path, next))
+			if max > 0 && len(paths) >= max {
+				return paths
+			}
+			continue
+		}
+		onStack[next] = true
+		calls = append(calls, frame{fn: next, next: SortFuncs(graph[next])})
+	}
+	return paths
+}
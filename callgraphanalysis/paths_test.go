@@ -0,0 +1,97 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// chain returns a graph calling from the first of n+1 functions to the last,
+// one after the other.
+func chain(n int) ([]*Func, map[*Func]map[*Func]bool) {
+	fns := make([]*Func, n+1)
+	for i := range fns {
+		name := fmt.Sprintf("f%d", i)
+		fns[i] = &Func{Name: name, Function: "example.com/chain." + name, Pkg: "example.com/chain"}
+	}
+	graph := make(map[*Func]map[*Func]bool, n)
+	for i := 0; i < n; i++ {
+		graph[fns[i]] = map[*Func]bool{fns[i+1]: true}
+	}
+	return fns, graph
+}
+
+// TestFindPathsDeepChain checks that the searches keep their own stack, so
+// a chain of 100,000 calls doesn't overflow the goroutine stack.
+func TestFindPathsDeepChain(t *testing.T) {
+	const depth = 100_000
+	fns, graph := chain(depth)
+	for _, tt := range []struct {
+		name      string
+		algorithm Algorithm
+	}{
+		{"FirstPath", FirstPath},
+		{"ShortestPath", ShortestPath},
+		{"AllPaths", AllPaths},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Analyzer{Algorithm: tt.algorithm}
+			paths := a.FindPaths(fns[0], fns[depth], graph, NewBudget(context.Background(), 0, 0))
+			if len(paths) != 1 {
+				t.Fatalf("FindPaths found %d paths, want 1", len(paths))
+			}
+			path := paths[0]
+			if len(path) != depth+1 {
+				t.Fatalf("path has %d functions, want %d", len(path), depth+1)
+			}
+			for i, fn := range path {
+				if fn != fns[i] {
+					t.Fatalf("function %d of the path is %s, want %s", i, fn.Name, fns[i].Name)
+				}
+			}
+		})
+	}
+}
+
+// TestFindKShortestPaths checks that the k shortest paths of a diamond with
+// a detour come out by length, once each, and stop at the paths there are.
+func TestFindKShortestPaths(t *testing.T) {
+	fns := make(map[string]*Func)
+	for _, name := range []string{"s", "a", "b", "c", "d", "t"} {
+		fns[name] = &Func{Name: name, Function: "example.com/diamond." + name, Pkg: "example.com/diamond"}
+	}
+	graph := make(map[*Func]map[*Func]bool)
+	for _, call := range []string{"sa", "sb", "sc", "at", "bt", "ab", "cd", "dt"} {
+		caller, callee := fns[call[:1]], fns[call[1:]]
+		if graph[caller] == nil {
+			graph[caller] = make(map[*Func]bool)
+		}
+		graph[caller][callee] = true
+	}
+	all := map[string]bool{"sat": true, "sbt": true, "sabt": true, "scdt": true}
+
+	for _, k := range []int{1, 2, 3, 4, 10} {
+		a := &Analyzer{Algorithm: KShortestPaths, PathLimit: k}
+		paths := a.FindPaths(fns["s"], fns["t"], graph, NewBudget(context.Background(), 0, 0))
+		if want := min(k, len(all)); len(paths) != want {
+			t.Errorf("k=%d: found %d paths, want %d", k, len(paths), want)
+		}
+		seen := make(map[string]bool)
+		for i, path := range paths {
+			var name string
+			for _, fn := range path {
+				name += fn.Name
+			}
+			if !all[name] {
+				t.Errorf("k=%d: path %s is not a path from s to t", k, name)
+			}
+			if seen[name] {
+				t.Errorf("k=%d: path %s found twice", k, name)
+			}
+			seen[name] = true
+			if i > 0 && len(path) < len(paths[i-1]) {
+				t.Errorf("k=%d: path %d, %s, is shorter than the one before it", k, i, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package callgraphanalysis
+
+import "sync"
+
+// Phase is a step of an analysis reported to Analyzer.Progress.
+type Phase string
+
+const (
+	// PhaseLoad lists, parses and type-checks the packages, in go list.
+	// Its Done and Total are the packages loaded, once it is done.
+	PhaseLoad Phase = "load"
+	// PhaseBuild builds the SSA form of the packages loaded.
+	PhaseBuild Phase = "build"
+	// PhaseGraph builds the graph from the SSA program. Its Done and Total
+	// are the functions kept, once it is done.
+	PhaseGraph Phase = "graph"
+	// PhaseSearch searches the paths of the sources in Analyze.
+	PhaseSearch Phase = "search"
+)
+
+// Progress tells how far a phase of the analysis is: Done of its Total
+// units, packages, functions or sources, with 0 for both as it starts when
+// its units are not known yet.
+type Progress struct {
+	Phase       Phase
+	Done, Total int
+}
+
+// progress reports Progress through Progress, if set.
+func (a *Analyzer) progress(phase Phase, done, total int) {
+	if a.Progress != nil {
+		a.Progress(Progress{Phase: phase, Done: done, Total: total})
+	}
+}
+
+// counter reports the start of phase, of total units, through report, and
+// returns the function reporting one more unit done, which can be called
+// from several goroutines at once.
+func counter(report func(phase Phase, done, total int), phase Phase, total int) func() {
+	report(phase, 0, total)
+	var mu sync.Mutex
+	done := 0
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		report(phase, done, total)
+	}
+}
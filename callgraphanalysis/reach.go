@@ -0,0 +1,201 @@
+package callgraphanalysis
+
+// Condensation is a reachability graph with its strongly connected components
+// collapsed, which leaves a DAG. Path queries start from it so that cycles of
+// recursive calls are not explored again for every pair.
+type Condensation struct {
+	comp map[*Func]int // component of each function
+	succ [][]int       // successor components of each component, once per call between them
+}
+
+// Condense computes the condensation of g.
+func Condense(g map[*Func]map[*Func]bool) *Condensation {
+	ids := make(map[*Func]int)
+	var funcs []*Func
+	id := func(fn *Func) int {
+		if i, ok := ids[fn]; ok {
+			return i
+		}
+		ids[fn] = len(funcs)
+		funcs = append(funcs, fn)
+		return ids[fn]
+	}
+	for caller, callees := range g {
+		id(caller)
+		for callee := range callees {
+			id(callee)
+		}
+	}
+	succ := make([][]int, len(funcs))
+	for caller, callees := range g {
+		for callee := range callees {
+			succ[ids[caller]] = append(succ[ids[caller]], ids[callee])
+		}
+	}
+
+	comp, n := sccs(succ)
+	c := &Condensation{comp: make(map[*Func]int, len(funcs)), succ: make([][]int, n)}
+	for v, fn := range funcs {
+		c.comp[fn] = comp[v]
+		for _, w := range succ[v] {
+			if comp[w] != comp[v] {
+				c.succ[comp[v]] = append(c.succ[comp[v]], comp[w])
+			}
+		}
+	}
+	return c
+}
+
+// ReachIndex tells which of a set of target functions, the sinks, each
+// function reaches, as one bitset of targets per strongly connected component
+// over the condensed DAG. It is built in one pass and answers every pair in
+// constant time, with memory linear in the number of components.
+type ReachIndex struct {
+	comp    map[*Func]int // component of each function
+	targets map[*Func]int // bit of each target
+	reach   []bitset      // targets reached from each component
+}
+
+// NewReachIndex indexes the targets reached in the graph condensed in c.
+func NewReachIndex(c *Condensation, targets map[*Func]bool) *ReachIndex {
+	idx := &ReachIndex{comp: c.comp, targets: make(map[*Func]int, len(targets)), reach: make([]bitset, len(c.succ))}
+	for i, fn := range SortFuncs(targets) {
+		idx.targets[fn] = i
+	}
+	for v := range idx.reach {
+		idx.reach[v] = newBitset(len(targets))
+	}
+	for fn, t := range idx.targets {
+		if v, ok := c.comp[fn]; ok {
+			idx.reach[v].set(t)
+		}
+	}
+	// Components are numbered in reverse topological order, so the targets
+	// of every successor component are complete before they are needed.
+	for v := range idx.reach {
+		for _, w := range c.succ[v] {
+			idx.reach[v].or(idx.reach[w])
+		}
+	}
+	return idx
+}
+
+// Reaches reports whether there is a path from a function to a target. A
+// function always reaches itself.
+func (idx *ReachIndex) Reaches(from, to *Func) bool {
+	if from == to {
+		return true
+	}
+	cf, ok := idx.comp[from]
+	if !ok {
+		return false
+	}
+	t, ok := idx.targets[to]
+	return ok && idx.reach[cf].has(t)
+}
+
+// ReachesAny reports whether there is a path from a function to some target
+// other than itself.
+func (idx *ReachIndex) ReachesAny(from *Func) bool {
+	cf, ok := idx.comp[from]
+	if !ok {
+		return false
+	}
+	self, isTarget := idx.targets[from]
+	for i, word := range idx.reach[cf] {
+		if isTarget && i == self/64 {
+			word &^= 1 << (self % 64)
+		}
+		if word != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sccs numbers the strongly connected components of the graph given by its
+// successor lists with an iterative Tarjan's algorithm, in reverse
+// topological order of the condensation.
+func sccs(succ [][]int) (comp []int, n int) {
+	index := make([]int, len(succ))
+	low := make([]int, len(succ))
+	onStack := make([]bool, len(succ))
+	comp = make([]int, len(succ))
+	for i := range index {
+		index[i] = -1
+	}
+
+	type frame struct{ v, next int }
+	var stack []int
+	counter := 0
+	visit := func(v int) {
+		index[v], low[v] = counter, counter
+		counter++
+		stack = append(stack, v)
+		onStack[v] = true
+	}
+
+	for root := range succ {
+		if index[root] >= 0 {
+			continue
+		}
+		visit(root)
+		calls := []frame{{v: root}}
+		for len(calls) > 0 {
+			f := &calls[len(calls)-1]
+			if f.next < len(succ[f.v]) {
+				w := succ[f.v][f.next]
+				f.next++
+				if index[w] < 0 {
+					visit(w)
+					calls = append(calls, frame{v: w})
+				} else if onStack[w] && index[w] < low[f.v] {
+					low[f.v] = index[w]
+				}
+				continue
+			}
+
+			v := f.v
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				if p := calls[len(calls)-1].v; low[v] < low[p] {
+					low[p] = low[v]
+				}
+			}
+			if low[v] == index[v] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					comp[w] = n
+					if w == v {
+						break
+					}
+				}
+				n++
+			}
+		}
+	}
+	return comp, n
+}
+
+// bitset is a fixed-size set of small integers.
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+func (b bitset) or(other bitset) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
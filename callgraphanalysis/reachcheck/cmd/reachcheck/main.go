@@ -0,0 +1,12 @@
+// The reachcheck command runs the reachcheck analyzer, standalone or as a
+// vet tool:
+//
+//	go vet -vettool=$(which reachcheck) -sources=... -sinks=... ./...
+package main
+
+import (
+	"educabot.com/callgraph-analysis/callgraphanalysis/reachcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(reachcheck.Analyzer) }
@@ -0,0 +1,201 @@
+// Package reachcheck defines an Analyzer reporting the source functions that
+// reach a forbidden sink, to run under go vet or a multichecker next to
+// other analyzers.
+//
+// Unlike the command, which builds the CHA call graph of the whole module,
+// the Analyzer sees one package at a time, as go/analysis requires: the
+// sinks each function reaches are exported as facts for the packages
+// calling it. It follows static calls and the functions used as values,
+// closures included, but not calls through interfaces, which need the whole
+// program to resolve.
+package reachcheck
+
+import (
+	"fmt"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Analyzer reports every function matching -sources that reaches a function
+// matching -sinks, at the declaration of the source.
+var Analyzer = &analysis.Analyzer{
+	Name:      "reachcheck",
+	Doc:       "report the source functions that reach a forbidden sink function",
+	URL:       "https://pkg.go.dev/educabot.com/callgraph-analysis/callgraphanalysis/reachcheck",
+	Run:       run,
+	Requires:  []*analysis.Analyzer{buildssa.Analyzer},
+	FactTypes: []analysis.Fact{new(reachFact)},
+}
+
+var sourceFlag, sinkFlag string
+
+func init() {
+	Analyzer.Flags.StringVar(&sourceFlag, "sources", "", "regular expression over the full names of the source functions, e.g. /internal/web\\.")
+	Analyzer.Flags.StringVar(&sinkFlag, "sinks", "", "regular expression over the full names of the functions sources must not reach, e.g. ^net/smtp\\.")
+}
+
+// reachFact is exported for the functions reaching a sink: for every sink
+// by full name, the shortest chain of calls to it, the sink last.
+type reachFact struct {
+	Paths map[string][]string
+}
+
+func (*reachFact) AFact() {}
+
+func (f *reachFact) String() string {
+	sinks := make([]string, 0, len(f.Paths))
+	for sink := range f.Paths {
+		sinks = append(sinks, sink)
+	}
+	sort.Strings(sinks)
+	return "reaches " + strings.Join(sinks, ", ")
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	if sinkFlag == "" {
+		return nil, nil
+	}
+	sink, err := regexp.Compile(sinkFlag)
+	if err != nil {
+		return nil, fmt.Errorf("-sinks: %v", err)
+	}
+	var source *regexp.Regexp
+	if sourceFlag != "" {
+		if source, err = regexp.Compile(sourceFlag); err != nil {
+			return nil, fmt.Errorf("-sources: %v", err)
+		}
+	}
+
+	funcs := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA).SrcFuncs
+	local := make(map[*ssa.Function]bool, len(funcs))
+	byObj := make(map[*types.Func]*ssa.Function)
+	for _, fn := range funcs {
+		local[fn] = true
+		if obj, ok := fn.Object().(*types.Func); ok {
+			byObj[obj] = fn
+		}
+	}
+
+	// paths holds, for every function of the package, the chains of calls
+	// to the sinks it reaches, empty for a sink itself
+	paths := make(map[*ssa.Function]map[string][]string)
+	callees := make(map[*ssa.Function][]callee)
+	for _, fn := range funcs {
+		if sink.MatchString(fn.String()) {
+			paths[fn] = map[string][]string{fn.String(): {}}
+		}
+		callees[fn] = calleesOf(pass, fn, local, byObj, sink)
+	}
+
+	// Extend the chains through the calls until none gets shorter
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range funcs {
+			for _, c := range callees[fn] {
+				reached := c.paths
+				if c.fn != nil {
+					reached = paths[c.fn]
+				}
+				for s, p := range reached {
+					old, ok := paths[fn][s]
+					if ok && len(old) <= len(p)+1 {
+						continue
+					}
+					if paths[fn] == nil {
+						paths[fn] = make(map[string][]string)
+					}
+					paths[fn][s] = append([]string{c.name}, p...)
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, fn := range funcs {
+		reached := paths[fn]
+		if len(reached) == 0 {
+			continue
+		}
+		if obj, ok := fn.Object().(*types.Func); ok && byObj[obj] == fn {
+			pass.ExportObjectFact(obj, &reachFact{Paths: reached})
+		}
+		if source == nil || !source.MatchString(fn.String()) {
+			continue
+		}
+		sinks := make([]string, 0, len(reached))
+		for s, p := range reached {
+			if len(p) > 0 {
+				sinks = append(sinks, s)
+			}
+		}
+		sort.Strings(sinks)
+		for _, s := range sinks {
+			pass.Reportf(fn.Pos(), "%s reaches the forbidden sink %s: %s", fn.Name(), s, strings.Join(append([]string{fn.String()}, reached[s]...), " → "))
+		}
+	}
+	return nil, nil
+}
+
+// callee is a function used by a function of the package: another function
+// of the package, or a function of another package with the sinks it
+// reaches, taken from its fact.
+type callee struct {
+	name  string
+	fn    *ssa.Function
+	paths map[string][]string
+}
+
+// calleesOf returns the functions fn calls or uses as values.
+func calleesOf(pass *analysis.Pass, fn *ssa.Function, local map[*ssa.Function]bool, byObj map[*types.Func]*ssa.Function, sink *regexp.Regexp) []callee {
+	var cs []callee
+	seen := make(map[string]bool)
+	var ops []*ssa.Value
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			for _, op := range instr.Operands(ops[:0]) {
+				target, ok := (*op).(*ssa.Function)
+				if !ok {
+					continue
+				}
+				if target.Origin() != nil {
+					target = target.Origin()
+				}
+				obj, _ := target.Object().(*types.Func)
+				if !local[target] && obj != nil && byObj[obj] != nil {
+					// A wrapper of a function of the package, e.g. a bound
+					// method
+					target = byObj[obj]
+				}
+				if seen[target.String()] {
+					continue
+				}
+				seen[target.String()] = true
+				switch {
+				case local[target]:
+					cs = append(cs, callee{name: target.String(), fn: target})
+				case obj != nil && obj.Pkg() != nil && obj.Pkg() != pass.Pkg:
+					c := callee{name: target.String(), paths: make(map[string][]string)}
+					var fact reachFact
+					if pass.ImportObjectFact(obj, &fact) {
+						for s, p := range fact.Paths {
+							c.paths[s] = p
+						}
+					}
+					if sink.MatchString(target.String()) {
+						c.paths[target.String()] = []string{}
+					}
+					if len(c.paths) > 0 {
+						cs = append(cs, c)
+					}
+				}
+			}
+		}
+	}
+	return cs
+}
@@ -0,0 +1,17 @@
+package reachcheck
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	for flag, value := range map[string]string{"sources": `^web\.`, "sinks": `^mail\.Send$`} {
+		if err := Analyzer.Flags.Set(flag, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { sourceFlag, sinkFlag = "", "" })
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "mail", "notify", "web")
+}
@@ -0,0 +1,5 @@
+package mail
+
+func Send(to string) {} // want Send:"reaches mail.Send"
+
+func Format(to string) string { return "To: " + to }
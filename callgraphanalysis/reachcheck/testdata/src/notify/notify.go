@@ -0,0 +1,16 @@
+package notify
+
+import "mail"
+
+func Welcome(to string) { // want Welcome:"reaches mail.Send"
+	mail.Send(to)
+}
+
+func Preview(to string) string {
+	return mail.Format(to)
+}
+
+// Sender sends through an interface, which the Analyzer does not follow.
+type Sender interface{ Send(to string) }
+
+func Through(s Sender, to string) { s.Send(to) }
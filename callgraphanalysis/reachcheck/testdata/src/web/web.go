@@ -0,0 +1,21 @@
+package web
+
+import "notify"
+
+func Signup(to string) { // want Signup:"reaches mail.Send" `Signup reaches the forbidden sink mail\.Send: web\.Signup → notify\.Welcome → mail\.Send`
+	notify.Welcome(to)
+}
+
+func Deferred(to string) func() { // want Deferred:"reaches mail.Send" `Deferred reaches the forbidden sink mail\.Send: web\.Deferred → web\.Deferred\$1 → notify\.Welcome → mail\.Send`
+	return func() { // want `Deferred\$1 reaches the forbidden sink mail\.Send: web\.Deferred\$1 → notify\.Welcome → mail\.Send`
+		notify.Welcome(to)
+	}
+}
+
+func Hook() func(string) { // want Hook:"reaches mail.Send" `Hook reaches the forbidden sink mail\.Send: web\.Hook → notify\.Welcome → mail\.Send`
+	return notify.Welcome
+}
+
+func Profile(s notify.Sender, to string) {
+	notify.Through(s, to)
+}
@@ -0,0 +1,178 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"time"
+)
+
+// Hop is a function of a path, with the position of the call leading to it
+// from the previous function, nil for the first one or when unknown.
+type Hop struct {
+	Func *Func
+	Call *Position
+}
+
+// Pair is what Analyze found between a source and a sink: the paths, in the
+// order searched or ranked, and how many paths there are, up to
+// PathCountCap. Truncated marks that the search from the source ran out of
+// budget before it was done with the sink, which may then be reached by more
+// paths than Paths, or at all.
+type Pair struct {
+	Source, Sink *Func
+	Paths        [][]Hop
+	PathCount    int
+	Truncated    bool
+}
+
+// Result is the outcome of Analyze: the sources and sinks searched, in
+// declaration order, and every pair of them with a path or a truncated
+// search, by source and then sink.
+type Result struct {
+	Sources []*Func
+	Sinks   []*Func
+	Pairs   []Pair
+}
+
+// Query is a search of the paths from Sources to Sinks over Graph, an
+// adjacency of the call graph, as returned by Graph.Adjacency and pruned by
+// the filters.
+type Query struct {
+	Graph          map[*Func]map[*Func]bool
+	Sources, Sinks map[*Func]bool
+	// CallSites positions the calls of the paths, as returned by
+	// Graph.CallSites. The hops have no call positions without it.
+	CallSites map[[2]string]Position
+	// Timeout and Visits are the budget of the search from each source, as
+	// for NewBudget.
+	Timeout time.Duration
+	Visits  int
+	// Rank, if not nil, orders the paths of each pair. It is called
+	// concurrently.
+	Rank func(paths [][]*Func)
+	// Found, if not nil, is called with every pair, in order, as soon as
+	// the searches before it end.
+	Found func(Pair)
+	// Cache, if not nil, stores the reach index of the sinks under a key
+	// derived from Key, which must identify Graph, e.g. a CacheKey of the
+	// module and of the settings Graph was built and pruned with.
+	Cache Cache
+	Key   string
+}
+
+// Analyze searches the paths of q with the Algorithm of a, in source and sink
+// declaration order. The pairs a ReachIndex proves unreachable are skipped
+// without searching, and the paths are only counted for the sources reaching
+// some sink. Once a source runs out of budget, the sinks its search is not
+// done with are reported as truncated. When ctx is done, the pairs left are
+// reported as truncated too, and the partial result is returned with the
+// error of ctx.
+//
+// The sources are searched in parallel, by GOMAXPROCS goroutines, so Rank is
+// called from several of them at once. Found is called from the goroutine of
+// Analyze, in the order of the result, as soon as the pairs of a source and
+// of the ones before it are done.
+func (a *Analyzer) Analyze(ctx context.Context, q Query) (*Result, error) {
+	r := &Result{Sources: SortFuncs(q.Sources), Sinks: SortFuncs(q.Sinks)}
+	a.progress(PhaseSearch, 0, len(r.Sources))
+	cond := Condense(q.Graph)
+	idx := a.reachIndex(ctx, q, cond)
+
+	pairs := make([][]Pair, len(r.Sources))
+	done := make([]chan struct{}, len(r.Sources))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	go parallel(len(r.Sources), func(i int) {
+		pairs[i] = a.searchSource(ctx, q, cond, idx, r.Sources[i], r.Sinks)
+		close(done[i])
+	})
+	for i := range r.Sources {
+		<-done[i]
+		for _, pair := range pairs[i] {
+			r.Pairs = append(r.Pairs, pair)
+			if q.Found != nil {
+				q.Found(pair)
+			}
+		}
+		a.progress(PhaseSearch, i+1, len(r.Sources))
+	}
+	return r, ctx.Err()
+}
+
+// searchSource searches the paths of q from src to sinks, with a budget of
+// its own.
+func (a *Analyzer) searchSource(ctx context.Context, q Query, cond *Condensation, idx *ReachIndex, src *Func, sinks []*Func) []Pair {
+	budget := NewBudget(ctx, q.Timeout, q.Visits)
+	var counts []int
+	if idx.ReachesAny(src) {
+		counts = cond.PathCounts(src)
+	}
+	var reached []*Func
+	for _, sink := range sinks {
+		if idx.Reaches(src, sink) {
+			reached = append(reached, sink)
+		}
+	}
+	search := a.pathsFrom(src, reached, q.Graph, budget)
+	var pairs []Pair
+	for _, sink := range reached {
+		paths, truncated := search(sink)
+		if q.Rank != nil {
+			q.Rank(paths)
+		}
+		pair := Pair{Source: src, Sink: sink, Truncated: truncated}
+		if len(paths) > 0 {
+			pair.PathCount = cond.PathCount(counts, src, sink)
+		}
+		for _, path := range paths {
+			pair.Paths = append(pair.Paths, Hops(path, q.CallSites))
+		}
+		if len(pair.Paths) == 0 && !pair.Truncated {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// pathsFrom returns the search of the paths from src to each of sinks, to be
+// called with them in order, which reports whether the budget ran out before
+// the search of the sink was done. With FirstPath and ShortestPath, whose
+// path to a sink doesn't depend on the others, all of them are searched in
+// one traversal from src; the other algorithms search one sink at a time.
+func (a *Analyzer) pathsFrom(src *Func, sinks []*Func, graph map[*Func]map[*Func]bool, budget *Budget) func(sink *Func) ([][]*Func, bool) {
+	var found map[*Func][]*Func
+	switch a.Algorithm {
+	case FirstPath:
+		found = a.firstPaths(src, sinks, graph, budget)
+	case ShortestPath:
+		found = a.shortestPaths(src, sinks, graph, nil, budget)
+	default:
+		return func(sink *Func) ([][]*Func, bool) {
+			paths := a.FindPaths(src, sink, graph, budget)
+			return paths, budget.Exhausted()
+		}
+	}
+	return func(sink *Func) ([][]*Func, bool) {
+		if path := found[sink]; path != nil {
+			return [][]*Func{path}, false
+		}
+		return nil, budget.Exhausted()
+	}
+}
+
+// Hops describes path with the position of each call in sites, as returned
+// by Graph.CallSites.
+func Hops(path []*Func, sites map[[2]string]Position) []Hop {
+	hops := make([]Hop, 0, len(path))
+	for i, fn := range path {
+		hop := Hop{Func: fn}
+		if i > 0 {
+			if site, ok := sites[[2]string{path[i-1].Function, fn.Function}]; ok {
+				hop.Call = &site
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
@@ -0,0 +1,132 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"strings"
+)
+
+// ScopeIndex indexes the reachability graph by package and keeps its reverse,
+// so the subgraph reachable to or from a package is found with two
+// traversals and no rescans of the graph.
+type ScopeIndex struct {
+	forward map[*Func]map[*Func]bool
+	reverse map[*Func]map[*Func]bool
+	byPkg   map[string][]*Func
+}
+
+// NewScopeIndex indexes the graph g.
+func NewScopeIndex(g map[*Func]map[*Func]bool) *ScopeIndex {
+	idx := &ScopeIndex{
+		forward: g,
+		reverse: Reverse(g),
+		byPkg:   make(map[string][]*Func),
+	}
+	seen := make(map[*Func]bool)
+	index := func(fn *Func) {
+		if seen[fn] || fn.Pkg == "" {
+			return
+		}
+		seen[fn] = true
+		idx.byPkg[fn.Pkg] = append(idx.byPkg[fn.Pkg], fn)
+	}
+	for caller, callees := range g {
+		index(caller)
+		for callee := range callees {
+			index(callee)
+		}
+	}
+	return idx
+}
+
+// Scope returns the functions of the package pattern, an import path,
+// together with every function reaching them or reachable from them. A
+// pattern ending in "/..." matches the package subtree. It only prunes the
+// graph: a path between two functions of the scope may still go around the
+// package, which ThroughScope rules out.
+func (idx *ScopeIndex) Scope(pattern string) map[*Func]bool {
+	match := packageMatcher(pattern)
+	var roots []*Func
+	for path, funcs := range idx.byPkg {
+		if match(path) {
+			roots = append(roots, funcs...)
+		}
+	}
+
+	in := make(map[*Func]bool)
+	for _, adj := range []map[*Func]map[*Func]bool{idx.forward, idx.reverse} {
+		visited := make(map[*Func]bool)
+		stack := append([]*Func(nil), roots...)
+		for len(stack) > 0 {
+			fn := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if visited[fn] {
+				continue
+			}
+			visited[fn] = true
+			in[fn] = true
+			for next := range adj[fn] {
+				stack = append(stack, next)
+			}
+		}
+	}
+	return in
+}
+
+// ThroughScope returns the graph of the paths going through a function of
+// the package pattern, as for Scope, with the sources and sinks to search it
+// between, as ThroughVia does for the functions matching a regular
+// expression.
+func ThroughScope(g map[*Func]map[*Func]bool, pattern string, sourceFuncs, sinkFuncs map[*Func]bool) (sg map[*Func]map[*Func]bool, sources, sinks map[*Func]bool) {
+	match := packageMatcher(pattern)
+	return through(g, func(fn *Func) bool { return match(fn.Pkg) }, sourceFuncs, sinkFuncs)
+}
+
+// AnalyzeScoped is Analyze over the paths of q going through a function of
+// the package pattern, as for Scope. idx is the index of q.Graph, built once
+// for the queries of a graph, so that each only searches the part of it
+// reaching to or from the package. The reach index is not cached, as the
+// graph searched is not q.Graph.
+func (a *Analyzer) AnalyzeScoped(ctx context.Context, q Query, idx *ScopeIndex, pattern string) (*Result, error) {
+	keep := idx.Scope(pattern)
+	inScope := func(set map[*Func]bool) map[*Func]bool {
+		kept := make(map[*Func]bool)
+		for fn := range set {
+			if keep[fn] {
+				kept[fn] = true
+			}
+		}
+		return kept
+	}
+	q.Graph, q.Sources, q.Sinks = ThroughScope(Restrict(q.Graph, keep), pattern, inScope(q.Sources), inScope(q.Sinks))
+	q.Cache, q.Key = nil, ""
+	return a.Analyze(ctx, q)
+}
+
+// packageMatcher reports whether a package path matches the import path
+// pattern, which matches the package subtree when ending in "/...".
+func packageMatcher(pattern string) func(path string) bool {
+	subtree := strings.HasSuffix(pattern, "/...")
+	pattern = strings.TrimSuffix(pattern, "/...")
+	return func(path string) bool {
+		return path == pattern || subtree && strings.HasPrefix(path, pattern+"/")
+	}
+}
+
+// Restrict returns the subgraph of g induced by the functions in keep.
+func Restrict(g map[*Func]map[*Func]bool, keep map[*Func]bool) map[*Func]map[*Func]bool {
+	sub := make(map[*Func]map[*Func]bool)
+	for caller, callees := range g {
+		if !keep[caller] {
+			continue
+		}
+		for callee := range callees {
+			if keep[callee] {
+				if sub[caller] == nil {
+					sub[caller] = make(map[*Func]bool)
+				}
+				sub[caller][callee] = true
+			}
+		}
+	}
+	return sub
+}
@@ -0,0 +1,42 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// TestAnalyzeScoped checks that the scoped paths go through the package,
+// not only through functions reaching it or reached from it.
+func TestAnalyzeScoped(t *testing.T) {
+	fn := func(pkg, name string) *Func {
+		return &Func{Name: name, Function: "example.com/" + pkg + "." + name, Pkg: "example.com/" + pkg}
+	}
+	a, y, p, b, c := fn("api", "A"), fn("svc", "Y"), fn("store", "P"), fn("db", "B"), fn("db", "C")
+	// Y reaches the store and B is reached from it, but A→Y→B goes around
+	// it; C is out of the scope
+	graph := map[*Func]map[*Func]bool{
+		a: {y: true, c: true},
+		y: {b: true, p: true},
+		p: {b: true},
+	}
+	q := Query{Graph: graph, Sources: map[*Func]bool{a: true}, Sinks: map[*Func]bool{b: true, c: true}}
+	an := &Analyzer{Algorithm: AllPaths, PathLimit: 10}
+	res, err := an.AnalyzeScoped(context.Background(), q, NewScopeIndex(graph), "example.com/store/...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths [][]string
+	for _, pair := range res.Pairs {
+		for _, path := range pair.Paths {
+			var names []string
+			for _, hop := range path {
+				names = append(names, hop.Func.Name)
+			}
+			paths = append(paths, names)
+		}
+	}
+	if want := [][]string{{"A", "Y", "P", "B"}}; !slices.EqualFunc(paths, want, slices.Equal) {
+		t.Errorf("paths %v, want %v", paths, want)
+	}
+}
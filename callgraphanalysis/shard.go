@@ -0,0 +1,262 @@
+package callgraphanalysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// shardVersion is the version of the serialized form of a Shard, bumped when
+// it changes.
+const shardVersion = 1
+
+// Shard is the part of the graph of a module built by BuildShard from some of
+// its packages, so that the graph of a module too large for one process is
+// built by several, possibly on different machines, and put together by
+// MergeShards.
+//
+// It holds the functions of those packages with their static calls, and
+// describes their calls through interfaces and function values, and the
+// methods and functions such calls may reach, by name and type, so that the
+// calls crossing shards are resolved once they are merged. File paths are
+// relative to the directory of the module.
+type Shard struct {
+	// Module is the path of the module, from the Analyzer.
+	Module string
+	// Key identifies the configuration the shard was built with, set by
+	// the caller: MergeShards refuses shards of different keys.
+	Key string
+
+	st *incrementalState
+}
+
+type shardFile struct {
+	Version     int
+	Module, Key string
+	State       []byte
+}
+
+// Packages returns the import paths of the packages of s.
+func (s *Shard) Packages() []string {
+	paths := make([]string, 0, len(s.st.packages))
+	for path := range s.st.packages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Encode writes s to w as gob.
+func (s *Shard) Encode(w io.Writer) error {
+	state, err := s.st.encode()
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(shardFile{Version: shardVersion, Module: s.Module, Key: s.Key, State: state})
+}
+
+// DecodeShard reads a shard written by Encode.
+func DecodeShard(r io.Reader) (*Shard, error) {
+	var f shardFile
+	if err := gob.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.Version != shardVersion {
+		return nil, fmt.Errorf("shard of version %d, not %d", f.Version, shardVersion)
+	}
+	st, err := decodeState(bytes.NewReader(f.State))
+	if err != nil {
+		return nil, err
+	}
+	return &Shard{Module: f.Module, Key: f.Key, st: st}, nil
+}
+
+// BuildShard loads the packages matching Patterns, with their dependencies,
+// and keeps the part of the graph of the module they own: their functions,
+// and the functions of the packages outside the module and the instances of
+// generic functions they use, which other shards may hold too. The packages
+// of the shards merged should cover the module, each package in one shard,
+// e.g. with a pattern per subtree. Modules analyzed together and Tests are
+// refused. It stops with the error of ctx once ctx is done.
+func (a *Analyzer) BuildShard(ctx context.Context) (*Shard, error) {
+	cfg, patterns, cleanup, err := a.loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if len(a.workspace) > 0 || a.Tests {
+		return nil, errors.New("shards are built for a single module without tests")
+	}
+	hashes, _, err := listPackages(cfg, patterns)
+	if err != nil {
+		return nil, err
+	}
+	deps, err := a.depsHash(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := loadProgram(ctx, cfg, patterns, a.built(), a.progress)
+	if err != nil {
+		return nil, err
+	}
+	st, err := a.buildState(ctx, prog)
+	if err != nil {
+		return nil, err
+	}
+	st.deps, st.packages = deps, hashes
+	st = a.owned(st)
+	a.progress(PhaseGraph, len(st.graph.Funcs), len(st.graph.Funcs))
+	return &Shard{Module: a.Module, st: st}, nil
+}
+
+// owned returns the part of st its packages own, with its paths relative to
+// Dir: the static calls, external calls and dynamic sites of the functions it
+// owns, with the functions they call, and the targets but for the functions
+// of the packages of other shards, which hold them. The synthetic wrappers
+// are made by the packages using them, so their targets are all kept.
+func (a *Analyzer) owned(st *incrementalState) *incrementalState {
+	own := func(fn *Func) bool {
+		_, ok := st.packages[fn.Pkg]
+		return ok || !a.InModule(fn.Function) || instance(fn)
+	}
+	out := &incrementalState{deps: st.deps, packages: st.packages, graph: &Graph{}, dynamic: make(map[Edge]bool), uses: make(map[*Func][]string)}
+	used := make(map[*Func]bool)
+	for _, e := range st.graph.Edges {
+		if !st.dynamic[e] && own(e.Caller) {
+			e.File = a.relPath(e.File)
+			out.graph.Edges = append(out.graph.Edges, e)
+			used[e.Callee] = true
+		}
+	}
+	for _, c := range st.graph.External {
+		if own(c.Caller) {
+			c.File = a.relPath(c.File)
+			out.graph.External = append(out.graph.External, c)
+		}
+	}
+	for _, s := range st.sites {
+		if own(s.Caller) {
+			s.File = a.relPath(s.File)
+			out.sites = append(out.sites, s)
+		}
+	}
+targets:
+	for _, t := range st.targets {
+		mine := t.wrapper
+		for _, fn := range t.Funcs {
+			mine = mine || own(fn)
+		}
+		for _, p := range t.Uses {
+			_, ok := st.packages[p]
+			mine = mine || ok || !a.InModule(p)
+		}
+		if !mine && (len(t.Funcs) > 0 || len(t.Uses) > 0) {
+			continue targets
+		}
+		for _, fn := range t.Funcs {
+			used[fn] = true
+		}
+		out.targets = append(out.targets, t)
+	}
+	for _, fn := range st.graph.Funcs {
+		if own(fn) || used[fn] {
+			out.graph.Funcs = append(out.graph.Funcs, fn)
+			if uses := st.uses[fn]; uses != nil {
+				out.uses[fn] = uses
+			}
+		}
+	}
+	for _, fn := range out.graph.Funcs {
+		fn.File = a.relPath(fn.File)
+		if fn.Origin != nil {
+			fn.Origin.File = a.relPath(fn.Origin.File)
+		}
+	}
+	return out
+}
+
+// instance reports whether fn is an instance of a generic function, or
+// belongs to one, which the packages instantiating it hold.
+func instance(fn *Func) bool {
+	return strings.Contains(fn.Function, "[")
+}
+
+// MergeShards returns the graph of the module from shards built by
+// BuildShard with the same Key, from the same go.mod, go.sum and Go version:
+// their functions and static calls, and the dynamic calls of every shard
+// resolved against the targets of all of them, as CHA resolves them in the
+// program of the whole module. The file paths are taken relative to Dir, and
+// the EdgeFilters and EdgeHooks apply to the calls as in IncrementalGraph.
+// The graph shares the functions of shards, which are used up.
+func (a *Analyzer) MergeShards(shards []*Shard) (*Graph, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no shard to merge")
+	}
+	first := shards[0]
+	for _, s := range shards[1:] {
+		switch {
+		case s.Module != first.Module:
+			return nil, fmt.Errorf("shards of different modules: %s and %s", first.Module, s.Module)
+		case s.Key != first.Key:
+			return nil, errors.New("shards built with different configurations")
+		case s.st.deps != first.st.deps:
+			return nil, errors.New("shards built from different go.mod, go.sum or Go versions")
+		}
+	}
+	dir, err := filepath.Abs(a.Dir)
+	if err != nil {
+		return nil, err
+	}
+	abs := func(name string) string {
+		if name == "" || filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	merged := &incrementalState{graph: &Graph{}, dynamic: make(map[Edge]bool), uses: make(map[*Func][]string)}
+	covered := make(map[string]bool)
+	for _, s := range shards {
+		st := s.st
+		for p := range st.packages {
+			covered[p] = true
+		}
+		for _, fn := range st.graph.Funcs {
+			fn.File = abs(fn.File)
+			if fn.Origin != nil {
+				fn.Origin.File = abs(fn.Origin.File)
+			}
+		}
+		for i := range st.graph.Edges {
+			st.graph.Edges[i].File = abs(st.graph.Edges[i].File)
+		}
+		for i := range st.graph.External {
+			st.graph.External[i].File = abs(st.graph.External[i].File)
+		}
+		for i := range st.sites {
+			st.sites[i].File = abs(st.sites[i].File)
+		}
+		st.absorb(merged, func(*Func) bool { return true }, nil)
+	}
+	a.resolveDynamic(merged)
+
+	// The functions of the module called from a shard whose package is in
+	// none have lost their own calls
+	missing := make(map[string]bool)
+	for _, fn := range merged.graph.Funcs {
+		if a.InModule(fn.Function) && !covered[fn.Pkg] && !instance(fn) {
+			missing[fn.Pkg] = true
+		}
+	}
+	if len(missing) > 0 {
+		a.logf("No shard holds the packages %s, whose calls are missing from the graph", strings.Join(sortedKeys(missing), ", "))
+	}
+	a.hookEdges(merged.graph, nil)
+	return merged.graph, nil
+}
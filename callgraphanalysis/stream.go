@@ -0,0 +1,158 @@
+package callgraphanalysis
+
+import (
+	"context"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// streamGraph builds the graph BuildGraph keeps from the CHA call graph of
+// prog, resolving the calls of the kept functions as CHA does and bridging
+// the synthetic wrappers as DeleteSyntheticNodes does, without a node for
+// the functions left out.
+func (a *Analyzer) streamGraph(ctx context.Context, prog *ssa.Program) (*Graph, error) {
+	all := ssautil.AllFunctions(prog)
+	fns := make([]*ssa.Function, 0, len(all))
+	for fn := range all {
+		fns = append(fns, fn)
+	}
+	funcs, graph, err := a.keptFuncs(ctx, prog, fns)
+	if err != nil {
+		return nil, err
+	}
+	calleesOf := lazyCallees(all)
+
+	// through returns the functions a call to the synthetic function fn
+	// reaches through the synthetic functions it calls
+	bridged := make(map[*ssa.Function][]*ssa.Function)
+	through := func(fn *ssa.Function) []*ssa.Function {
+		if reached, ok := bridged[fn]; ok {
+			return reached
+		}
+		var reached []*ssa.Function
+		seen := map[*ssa.Function]bool{fn: true}
+		for stack := []*ssa.Function{fn}; len(stack) > 0; {
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, b := range g.Blocks {
+				for _, instr := range b.Instrs {
+					site, ok := instr.(ssa.CallInstruction)
+					if !ok {
+						continue
+					}
+					for _, h := range calleesOf(site) {
+						if seen[h] {
+							continue
+						}
+						seen[h] = true
+						if synthetic(h) {
+							stack = append(stack, h)
+						} else {
+							reached = append(reached, h)
+						}
+					}
+				}
+			}
+		}
+		bridged[fn] = reached
+		return reached
+	}
+
+	seen := make(map[Edge]bool)
+	for fn, caller := range funcs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		own := a.InModule(fn.String())
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				site, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				pos := prog.Fset.PositionFor(site.Pos(), false)
+				stringer := stringerCall(site)
+				for _, g := range calleesOf(site) {
+					callees := []*ssa.Function{g}
+					if synthetic(g) {
+						callees = through(g)
+					}
+					for _, callee := range callees {
+						// Note the calls into other modules of the
+						// organization, which are not kept
+						if own && callee.Pkg != nil && !a.InModule(callee.String()) && a.OrgPrefix != "" && strings.HasPrefix(callee.Pkg.Pkg.Path(), a.OrgPrefix) {
+							graph.External = append(graph.External, ExternalCall{Caller: caller, Callee: callee.String(), File: pos.Filename, Line: pos.Line})
+						}
+						if f := funcs[callee]; f != nil {
+							e := Edge{Caller: caller, Callee: f, File: pos.Filename, Line: pos.Line, Stringer: stringer}
+							a.addEdge(graph, seen, e, site)
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := a.anonEdges(ctx, funcs, graph, seen); err != nil {
+		return nil, err
+	}
+	graph.sort()
+	return graph, nil
+}
+
+// lazyCallees returns the functions of fns a call may reach, as CHA resolves
+// it: the static callee, the methods of the types implementing the interface
+// of an invoke, and the functions with the signature of a function value.
+// It is the resolution of golang.org/x/tools/go/callgraph/cha, whose
+// internals are not exported.
+func lazyCallees(fns map[*ssa.Function]bool) func(site ssa.CallInstruction) []*ssa.Function {
+	var funcsBySig typeutil.Map // value is []*ssa.Function
+	methodsByID := make(map[string][]*ssa.Function)
+	for f := range fns {
+		if f.Signature.Recv() == nil {
+			// Package initializers can never be address-taken
+			if f.Name() == "init" && f.Synthetic == "package initializer" {
+				continue
+			}
+			funcs, _ := funcsBySig.At(f.Signature).([]*ssa.Function)
+			funcsBySig.Set(f.Signature, append(funcs, f))
+		} else if obj := f.Object(); obj != nil {
+			id := obj.(*types.Func).Id()
+			methodsByID[id] = append(methodsByID[id], f)
+		}
+	}
+
+	type imethod struct {
+		I  *types.Interface
+		id string
+	}
+	methodsMemo := make(map[imethod][]*ssa.Function)
+	return func(site ssa.CallInstruction) []*ssa.Function {
+		call := site.Common()
+		if g := call.StaticCallee(); g != nil {
+			return []*ssa.Function{g}
+		}
+		if call.IsInvoke() {
+			I := call.Value.Type().Underlying().(*types.Interface)
+			key := imethod{I, call.Method.Id()}
+			methods, ok := methodsMemo[key]
+			if !ok {
+				for _, f := range methodsByID[key.id] {
+					if types.Implements(f.Signature.Recv().Type(), I) {
+						methods = append(methods, f)
+					}
+				}
+				methodsMemo[key] = methods
+			}
+			return methods
+		}
+		if _, ok := call.Value.(*ssa.Builtin); ok {
+			return nil
+		}
+		funcs, _ := funcsBySig.At(call.Signature()).([]*ssa.Function)
+		return funcs
+	}
+}
@@ -0,0 +1,1122 @@
+// The analysis service of serve -grpc, answering over the call graph the
+// daemon keeps in memory.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: callgraph.proto
+
+package callgraphpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Algorithm selects the paths reported for every source and sink.
+type Algorithm int32
+
+const (
+	// The first path found, as analyze does by default.
+	Algorithm_ALGORITHM_FIRST Algorithm = 0
+	// The path with the fewest calls, as -shortest.
+	Algorithm_ALGORITHM_SHORTEST Algorithm = 1
+	// Every simple path up to max_paths, as -all-paths.
+	Algorithm_ALGORITHM_ALL Algorithm = 2
+	// The k shortest distinct paths, as -k-paths.
+	Algorithm_ALGORITHM_K_SHORTEST Algorithm = 3
+)
+
+// Enum value maps for Algorithm.
+var (
+	Algorithm_name = map[int32]string{
+		0: "ALGORITHM_FIRST",
+		1: "ALGORITHM_SHORTEST",
+		2: "ALGORITHM_ALL",
+		3: "ALGORITHM_K_SHORTEST",
+	}
+	Algorithm_value = map[string]int32{
+		"ALGORITHM_FIRST":      0,
+		"ALGORITHM_SHORTEST":   1,
+		"ALGORITHM_ALL":        2,
+		"ALGORITHM_K_SHORTEST": 3,
+	}
+)
+
+func (x Algorithm) Enum() *Algorithm {
+	p := new(Algorithm)
+	*p = x
+	return p
+}
+
+func (x Algorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Algorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_callgraph_proto_enumTypes[0].Descriptor()
+}
+
+func (Algorithm) Type() protoreflect.EnumType {
+	return &file_callgraph_proto_enumTypes[0]
+}
+
+func (x Algorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Algorithm.Descriptor instead.
+func (Algorithm) EnumDescriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{0}
+}
+
+type Question int32
+
+const (
+	Question_QUESTION_UNSPECIFIED Question = 0
+	// The functions calling those matching function.
+	Question_QUESTION_CALLERS Question = 1
+	// The functions called by those matching function.
+	Question_QUESTION_CALLEES Question = 2
+	// The path with the fewest calls from a function matching function to one
+	// matching to.
+	Question_QUESTION_PATH Question = 3
+)
+
+// Enum value maps for Question.
+var (
+	Question_name = map[int32]string{
+		0: "QUESTION_UNSPECIFIED",
+		1: "QUESTION_CALLERS",
+		2: "QUESTION_CALLEES",
+		3: "QUESTION_PATH",
+	}
+	Question_value = map[string]int32{
+		"QUESTION_UNSPECIFIED": 0,
+		"QUESTION_CALLERS":     1,
+		"QUESTION_CALLEES":     2,
+		"QUESTION_PATH":        3,
+	}
+)
+
+func (x Question) Enum() *Question {
+	p := new(Question)
+	*p = x
+	return p
+}
+
+func (x Question) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Question) Descriptor() protoreflect.EnumDescriptor {
+	return file_callgraph_proto_enumTypes[1].Descriptor()
+}
+
+func (Question) Type() protoreflect.EnumType {
+	return &file_callgraph_proto_enumTypes[1]
+}
+
+func (x Question) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Question.Descriptor instead.
+func (Question) EnumDescriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{1}
+}
+
+type AnalyzeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Files declaring the sources and the sinks, relative to the analyzed
+	// directory or absolute, as -sources and -sinks.
+	Sources   []string  `protobuf:"bytes,1,rep,name=sources,proto3" json:"sources,omitempty"`
+	Sinks     []string  `protobuf:"bytes,2,rep,name=sinks,proto3" json:"sinks,omitempty"`
+	Algorithm Algorithm `protobuf:"varint,3,opt,name=algorithm,proto3,enum=callgraph.v1.Algorithm" json:"algorithm,omitempty"`
+	// Paths reported by pair with ALGORITHM_ALL (default 100), and with
+	// ALGORITHM_K_SHORTEST (default 1).
+	MaxPaths int32 `protobuf:"varint,4,opt,name=max_paths,json=maxPaths,proto3" json:"max_paths,omitempty"`
+	// Only the paths going through a function of the packages matching this
+	// pattern, relative to the module as -scope takes it, e.g. internal/...
+	Scope         string `protobuf:"bytes,5,opt,name=scope,proto3" json:"scope,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	mi := &file_callgraph_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AnalyzeRequest) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetSinks() []string {
+	if x != nil {
+		return x.Sinks
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetAlgorithm() Algorithm {
+	if x != nil {
+		return x.Algorithm
+	}
+	return Algorithm_ALGORITHM_FIRST
+}
+
+func (x *AnalyzeRequest) GetMaxPaths() int32 {
+	if x != nil {
+		return x.MaxPaths
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetScope() string {
+	if x != nil {
+		return x.Scope
+	}
+	return ""
+}
+
+type AnalyzeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*SourceResult        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeResponse) Reset() {
+	*x = AnalyzeResponse{}
+	mi := &file_callgraph_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResponse) ProtoMessage() {}
+
+func (x *AnalyzeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResponse.ProtoReflect.Descriptor instead.
+func (*AnalyzeResponse) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnalyzeResponse) GetResults() []*SourceResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// SourceResult holds the findings of a source, one per path to a sink, as in
+// analyze -format=json. Truncated lists the sinks whose search ran out of
+// time, so they may be reached without a finding.
+type SourceResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        *Frame                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Findings      []*Finding             `protobuf:"bytes,2,rep,name=findings,proto3" json:"findings,omitempty"`
+	Truncated     []*Frame               `protobuf:"bytes,3,rep,name=truncated,proto3" json:"truncated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SourceResult) Reset() {
+	*x = SourceResult{}
+	mi := &file_callgraph_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourceResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourceResult) ProtoMessage() {}
+
+func (x *SourceResult) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourceResult.ProtoReflect.Descriptor instead.
+func (*SourceResult) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SourceResult) GetSource() *Frame {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *SourceResult) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+func (x *SourceResult) GetTruncated() []*Frame {
+	if x != nil {
+		return x.Truncated
+	}
+	return nil
+}
+
+type Finding struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Sink  *Frame                 `protobuf:"bytes,1,opt,name=sink,proto3" json:"sink,omitempty"`
+	Path  []*Frame               `protobuf:"bytes,2,rep,name=path,proto3" json:"path,omitempty"`
+	// Number of paths between the source and the sink, up to a cap.
+	PathCount     int32 `protobuf:"varint,3,opt,name=path_count,json=pathCount,proto3" json:"path_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Finding) Reset() {
+	*x = Finding{}
+	mi := &file_callgraph_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Finding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Finding) ProtoMessage() {}
+
+func (x *Finding) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Finding.ProtoReflect.Descriptor instead.
+func (*Finding) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Finding) GetSink() *Frame {
+	if x != nil {
+		return x.Sink
+	}
+	return nil
+}
+
+func (x *Finding) GetPath() []*Frame {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+func (x *Finding) GetPathCount() int32 {
+	if x != nil {
+		return x.PathCount
+	}
+	return 0
+}
+
+// Frame is a function, in a path or answering a query. Origin is set for the
+// functions of generated files with //line directives, and call on the hops
+// of a path after the first: the position of the call from the previous one.
+type Frame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Function      string                 `protobuf:"bytes,2,opt,name=function,proto3" json:"function,omitempty"`
+	File          string                 `protobuf:"bytes,3,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32                  `protobuf:"varint,4,opt,name=line,proto3" json:"line,omitempty"`
+	Origin        *Position              `protobuf:"bytes,5,opt,name=origin,proto3" json:"origin,omitempty"`
+	Call          *Position              `protobuf:"bytes,6,opt,name=call,proto3" json:"call,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_callgraph_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Frame) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Frame) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *Frame) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Frame) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Frame) GetOrigin() *Position {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *Frame) GetCall() *Position {
+	if x != nil {
+		return x.Call
+	}
+	return nil
+}
+
+type Position struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32                  `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Position) Reset() {
+	*x = Position{}
+	mi := &file_callgraph_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Position) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Position) ProtoMessage() {}
+
+func (x *Position) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Position.ProtoReflect.Descriptor instead.
+func (*Position) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Position) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Position) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+type QueryRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Question Question               `protobuf:"varint,1,opt,name=question,proto3,enum=callgraph.v1.Question" json:"question,omitempty"`
+	// Regular expressions over full function names.
+	Function      string `protobuf:"bytes,2,opt,name=function,proto3" json:"function,omitempty"`
+	To            string `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_callgraph_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *QueryRequest) GetQuestion() Question {
+	if x != nil {
+		return x.Question
+	}
+	return Question_QUESTION_UNSPECIFIED
+}
+
+func (x *QueryRequest) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+type QueryResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The answers of QUESTION_CALLERS and QUESTION_CALLEES, by function
+	// matched.
+	Neighbors []*Neighbors `protobuf:"bytes,1,rep,name=neighbors,proto3" json:"neighbors,omitempty"`
+	// The path of QUESTION_PATH, empty when there is none.
+	Path          []*Frame `protobuf:"bytes,2,rep,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_callgraph_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
+func (*QueryResponse) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *QueryResponse) GetNeighbors() []*Neighbors {
+	if x != nil {
+		return x.Neighbors
+	}
+	return nil
+}
+
+func (x *QueryResponse) GetPath() []*Frame {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+type Neighbors struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Function      *Frame                 `protobuf:"bytes,1,opt,name=function,proto3" json:"function,omitempty"`
+	Functions     []*Frame               `protobuf:"bytes,2,rep,name=functions,proto3" json:"functions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Neighbors) Reset() {
+	*x = Neighbors{}
+	mi := &file_callgraph_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Neighbors) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Neighbors) ProtoMessage() {}
+
+func (x *Neighbors) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Neighbors.ProtoReflect.Descriptor instead.
+func (*Neighbors) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Neighbors) GetFunction() *Frame {
+	if x != nil {
+		return x.Function
+	}
+	return nil
+}
+
+func (x *Neighbors) GetFunctions() []*Frame {
+	if x != nil {
+		return x.Functions
+	}
+	return nil
+}
+
+type ExportGraphRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only the functions of the packages matching these comma-separated
+	// patterns, relative to the module as -scope takes them, and the calls
+	// between them, e.g. internal/...
+	Pkg           string `protobuf:"bytes,1,opt,name=pkg,proto3" json:"pkg,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportGraphRequest) Reset() {
+	*x = ExportGraphRequest{}
+	mi := &file_callgraph_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportGraphRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportGraphRequest) ProtoMessage() {}
+
+func (x *ExportGraphRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportGraphRequest.ProtoReflect.Descriptor instead.
+func (*ExportGraphRequest) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExportGraphRequest) GetPkg() string {
+	if x != nil {
+		return x.Pkg
+	}
+	return ""
+}
+
+// GraphChunk holds some of the functions of the graph and of their calls;
+// a call only names functions of the chunks streamed before it or of its own.
+type GraphChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Functions     []*Function            `protobuf:"bytes,1,rep,name=functions,proto3" json:"functions,omitempty"`
+	Calls         []*Call                `protobuf:"bytes,2,rep,name=calls,proto3" json:"calls,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GraphChunk) Reset() {
+	*x = GraphChunk{}
+	mi := &file_callgraph_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GraphChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GraphChunk) ProtoMessage() {}
+
+func (x *GraphChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GraphChunk.ProtoReflect.Descriptor instead.
+func (*GraphChunk) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GraphChunk) GetFunctions() []*Function {
+	if x != nil {
+		return x.Functions
+	}
+	return nil
+}
+
+func (x *GraphChunk) GetCalls() []*Call {
+	if x != nil {
+		return x.Calls
+	}
+	return nil
+}
+
+type Function struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Full name, which identifies the function in the calls.
+	Function      string    `protobuf:"bytes,2,opt,name=function,proto3" json:"function,omitempty"`
+	Pkg           string    `protobuf:"bytes,3,opt,name=pkg,proto3" json:"pkg,omitempty"`
+	File          string    `protobuf:"bytes,4,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32     `protobuf:"varint,5,opt,name=line,proto3" json:"line,omitempty"`
+	Origin        *Position `protobuf:"bytes,6,opt,name=origin,proto3" json:"origin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Function) Reset() {
+	*x = Function{}
+	mi := &file_callgraph_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Function) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Function) ProtoMessage() {}
+
+func (x *Function) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Function.ProtoReflect.Descriptor instead.
+func (*Function) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Function) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Function) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *Function) GetPkg() string {
+	if x != nil {
+		return x.Pkg
+	}
+	return ""
+}
+
+func (x *Function) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Function) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Function) GetOrigin() *Position {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+type Call struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Caller string                 `protobuf:"bytes,1,opt,name=caller,proto3" json:"caller,omitempty"`
+	Callee string                 `protobuf:"bytes,2,opt,name=callee,proto3" json:"callee,omitempty"`
+	File   string                 `protobuf:"bytes,3,opt,name=file,proto3" json:"file,omitempty"`
+	Line   int32                  `protobuf:"varint,4,opt,name=line,proto3" json:"line,omitempty"`
+	// A call to a String or Error method through an interface, which analyze
+	// doesn't follow unless -stringer-edges is given.
+	Stringer      bool `protobuf:"varint,5,opt,name=stringer,proto3" json:"stringer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Call) Reset() {
+	*x = Call{}
+	mi := &file_callgraph_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Call) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Call) ProtoMessage() {}
+
+func (x *Call) ProtoReflect() protoreflect.Message {
+	mi := &file_callgraph_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Call.ProtoReflect.Descriptor instead.
+func (*Call) Descriptor() ([]byte, []int) {
+	return file_callgraph_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Call) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *Call) GetCallee() string {
+	if x != nil {
+		return x.Callee
+	}
+	return ""
+}
+
+func (x *Call) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Call) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Call) GetStringer() bool {
+	if x != nil {
+		return x.Stringer
+	}
+	return false
+}
+
+var File_callgraph_proto protoreflect.FileDescriptor
+
+const file_callgraph_proto_rawDesc = "" +
+	"\n" +
+	"\x0fcallgraph.proto\x12\fcallgraph.v1\"\xaa\x01\n" +
+	"\x0eAnalyzeRequest\x12\x18\n" +
+	"\asources\x18\x01 \x03(\tR\asources\x12\x14\n" +
+	"\x05sinks\x18\x02 \x03(\tR\x05sinks\x125\n" +
+	"\talgorithm\x18\x03 \x01(\x0e2\x17.callgraph.v1.AlgorithmR\talgorithm\x12\x1b\n" +
+	"\tmax_paths\x18\x04 \x01(\x05R\bmaxPaths\x12\x14\n" +
+	"\x05scope\x18\x05 \x01(\tR\x05scope\"G\n" +
+	"\x0fAnalyzeResponse\x124\n" +
+	"\aresults\x18\x01 \x03(\v2\x1a.callgraph.v1.SourceResultR\aresults\"\xa1\x01\n" +
+	"\fSourceResult\x12+\n" +
+	"\x06source\x18\x01 \x01(\v2\x13.callgraph.v1.FrameR\x06source\x121\n" +
+	"\bfindings\x18\x02 \x03(\v2\x15.callgraph.v1.FindingR\bfindings\x121\n" +
+	"\ttruncated\x18\x03 \x03(\v2\x13.callgraph.v1.FrameR\ttruncated\"z\n" +
+	"\aFinding\x12'\n" +
+	"\x04sink\x18\x01 \x01(\v2\x13.callgraph.v1.FrameR\x04sink\x12'\n" +
+	"\x04path\x18\x02 \x03(\v2\x13.callgraph.v1.FrameR\x04path\x12\x1d\n" +
+	"\n" +
+	"path_count\x18\x03 \x01(\x05R\tpathCount\"\xbb\x01\n" +
+	"\x05Frame\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bfunction\x18\x02 \x01(\tR\bfunction\x12\x12\n" +
+	"\x04file\x18\x03 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x04 \x01(\x05R\x04line\x12.\n" +
+	"\x06origin\x18\x05 \x01(\v2\x16.callgraph.v1.PositionR\x06origin\x12*\n" +
+	"\x04call\x18\x06 \x01(\v2\x16.callgraph.v1.PositionR\x04call\"2\n" +
+	"\bPosition\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x02 \x01(\x05R\x04line\"n\n" +
+	"\fQueryRequest\x122\n" +
+	"\bquestion\x18\x01 \x01(\x0e2\x16.callgraph.v1.QuestionR\bquestion\x12\x1a\n" +
+	"\bfunction\x18\x02 \x01(\tR\bfunction\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\tR\x02to\"o\n" +
+	"\rQueryResponse\x125\n" +
+	"\tneighbors\x18\x01 \x03(\v2\x17.callgraph.v1.NeighborsR\tneighbors\x12'\n" +
+	"\x04path\x18\x02 \x03(\v2\x13.callgraph.v1.FrameR\x04path\"o\n" +
+	"\tNeighbors\x12/\n" +
+	"\bfunction\x18\x01 \x01(\v2\x13.callgraph.v1.FrameR\bfunction\x121\n" +
+	"\tfunctions\x18\x02 \x03(\v2\x13.callgraph.v1.FrameR\tfunctions\"&\n" +
+	"\x12ExportGraphRequest\x12\x10\n" +
+	"\x03pkg\x18\x01 \x01(\tR\x03pkg\"l\n" +
+	"\n" +
+	"GraphChunk\x124\n" +
+	"\tfunctions\x18\x01 \x03(\v2\x16.callgraph.v1.FunctionR\tfunctions\x12(\n" +
+	"\x05calls\x18\x02 \x03(\v2\x12.callgraph.v1.CallR\x05calls\"\xa4\x01\n" +
+	"\bFunction\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bfunction\x18\x02 \x01(\tR\bfunction\x12\x10\n" +
+	"\x03pkg\x18\x03 \x01(\tR\x03pkg\x12\x12\n" +
+	"\x04file\x18\x04 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x05 \x01(\x05R\x04line\x12.\n" +
+	"\x06origin\x18\x06 \x01(\v2\x16.callgraph.v1.PositionR\x06origin\"z\n" +
+	"\x04Call\x12\x16\n" +
+	"\x06caller\x18\x01 \x01(\tR\x06caller\x12\x16\n" +
+	"\x06callee\x18\x02 \x01(\tR\x06callee\x12\x12\n" +
+	"\x04file\x18\x03 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x04 \x01(\x05R\x04line\x12\x1a\n" +
+	"\bstringer\x18\x05 \x01(\bR\bstringer*e\n" +
+	"\tAlgorithm\x12\x13\n" +
+	"\x0fALGORITHM_FIRST\x10\x00\x12\x16\n" +
+	"\x12ALGORITHM_SHORTEST\x10\x01\x12\x11\n" +
+	"\rALGORITHM_ALL\x10\x02\x12\x18\n" +
+	"\x14ALGORITHM_K_SHORTEST\x10\x03*c\n" +
+	"\bQuestion\x12\x18\n" +
+	"\x14QUESTION_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10QUESTION_CALLERS\x10\x01\x12\x14\n" +
+	"\x10QUESTION_CALLEES\x10\x02\x12\x11\n" +
+	"\rQUESTION_PATH\x10\x032\xe2\x01\n" +
+	"\tCallGraph\x12F\n" +
+	"\aAnalyze\x12\x1c.callgraph.v1.AnalyzeRequest\x1a\x1d.callgraph.v1.AnalyzeResponse\x12@\n" +
+	"\x05Query\x12\x1a.callgraph.v1.QueryRequest\x1a\x1b.callgraph.v1.QueryResponse\x12K\n" +
+	"\vExportGraph\x12 .callgraph.v1.ExportGraphRequest\x1a\x18.callgraph.v1.GraphChunk0\x01B-Z+educabot.com/callgraph-analysis/callgraphpbb\x06proto3"
+
+var (
+	file_callgraph_proto_rawDescOnce sync.Once
+	file_callgraph_proto_rawDescData []byte
+)
+
+func file_callgraph_proto_rawDescGZIP() []byte {
+	file_callgraph_proto_rawDescOnce.Do(func() {
+		file_callgraph_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_callgraph_proto_rawDesc), len(file_callgraph_proto_rawDesc)))
+	})
+	return file_callgraph_proto_rawDescData
+}
+
+var file_callgraph_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_callgraph_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_callgraph_proto_goTypes = []any{
+	(Algorithm)(0),             // 0: callgraph.v1.Algorithm
+	(Question)(0),              // 1: callgraph.v1.Question
+	(*AnalyzeRequest)(nil),     // 2: callgraph.v1.AnalyzeRequest
+	(*AnalyzeResponse)(nil),    // 3: callgraph.v1.AnalyzeResponse
+	(*SourceResult)(nil),       // 4: callgraph.v1.SourceResult
+	(*Finding)(nil),            // 5: callgraph.v1.Finding
+	(*Frame)(nil),              // 6: callgraph.v1.Frame
+	(*Position)(nil),           // 7: callgraph.v1.Position
+	(*QueryRequest)(nil),       // 8: callgraph.v1.QueryRequest
+	(*QueryResponse)(nil),      // 9: callgraph.v1.QueryResponse
+	(*Neighbors)(nil),          // 10: callgraph.v1.Neighbors
+	(*ExportGraphRequest)(nil), // 11: callgraph.v1.ExportGraphRequest
+	(*GraphChunk)(nil),         // 12: callgraph.v1.GraphChunk
+	(*Function)(nil),           // 13: callgraph.v1.Function
+	(*Call)(nil),               // 14: callgraph.v1.Call
+}
+var file_callgraph_proto_depIdxs = []int32{
+	0,  // 0: callgraph.v1.AnalyzeRequest.algorithm:type_name -> callgraph.v1.Algorithm
+	4,  // 1: callgraph.v1.AnalyzeResponse.results:type_name -> callgraph.v1.SourceResult
+	6,  // 2: callgraph.v1.SourceResult.source:type_name -> callgraph.v1.Frame
+	5,  // 3: callgraph.v1.SourceResult.findings:type_name -> callgraph.v1.Finding
+	6,  // 4: callgraph.v1.SourceResult.truncated:type_name -> callgraph.v1.Frame
+	6,  // 5: callgraph.v1.Finding.sink:type_name -> callgraph.v1.Frame
+	6,  // 6: callgraph.v1.Finding.path:type_name -> callgraph.v1.Frame
+	7,  // 7: callgraph.v1.Frame.origin:type_name -> callgraph.v1.Position
+	7,  // 8: callgraph.v1.Frame.call:type_name -> callgraph.v1.Position
+	1,  // 9: callgraph.v1.QueryRequest.question:type_name -> callgraph.v1.Question
+	10, // 10: callgraph.v1.QueryResponse.neighbors:type_name -> callgraph.v1.Neighbors
+	6,  // 11: callgraph.v1.QueryResponse.path:type_name -> callgraph.v1.Frame
+	6,  // 12: callgraph.v1.Neighbors.function:type_name -> callgraph.v1.Frame
+	6,  // 13: callgraph.v1.Neighbors.functions:type_name -> callgraph.v1.Frame
+	13, // 14: callgraph.v1.GraphChunk.functions:type_name -> callgraph.v1.Function
+	14, // 15: callgraph.v1.GraphChunk.calls:type_name -> callgraph.v1.Call
+	7,  // 16: callgraph.v1.Function.origin:type_name -> callgraph.v1.Position
+	2,  // 17: callgraph.v1.CallGraph.Analyze:input_type -> callgraph.v1.AnalyzeRequest
+	8,  // 18: callgraph.v1.CallGraph.Query:input_type -> callgraph.v1.QueryRequest
+	11, // 19: callgraph.v1.CallGraph.ExportGraph:input_type -> callgraph.v1.ExportGraphRequest
+	3,  // 20: callgraph.v1.CallGraph.Analyze:output_type -> callgraph.v1.AnalyzeResponse
+	9,  // 21: callgraph.v1.CallGraph.Query:output_type -> callgraph.v1.QueryResponse
+	12, // 22: callgraph.v1.CallGraph.ExportGraph:output_type -> callgraph.v1.GraphChunk
+	20, // [20:23] is the sub-list for method output_type
+	17, // [17:20] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_callgraph_proto_init() }
+func file_callgraph_proto_init() {
+	if File_callgraph_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_callgraph_proto_rawDesc), len(file_callgraph_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_callgraph_proto_goTypes,
+		DependencyIndexes: file_callgraph_proto_depIdxs,
+		EnumInfos:         file_callgraph_proto_enumTypes,
+		MessageInfos:      file_callgraph_proto_msgTypes,
+	}.Build()
+	File_callgraph_proto = out.File
+	file_callgraph_proto_goTypes = nil
+	file_callgraph_proto_depIdxs = nil
+}
@@ -0,0 +1,212 @@
+// The analysis service of serve -grpc, answering over the call graph the
+// daemon keeps in memory.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: callgraph.proto
+
+package callgraphpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CallGraph_Analyze_FullMethodName     = "/callgraph.v1.CallGraph/Analyze"
+	CallGraph_Query_FullMethodName       = "/callgraph.v1.CallGraph/Query"
+	CallGraph_ExportGraph_FullMethodName = "/callgraph.v1.CallGraph/ExportGraph"
+)
+
+// CallGraphClient is the client API for CallGraph service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CallGraphClient interface {
+	// Analyze reports the paths from the functions declared in the source
+	// files to those declared in the sink files, as analyze -daemon does.
+	Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (*AnalyzeResponse, error)
+	// Query answers the questions of the query subcommand.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	// ExportGraph streams the functions and calls of the graph, in chunks.
+	ExportGraph(ctx context.Context, in *ExportGraphRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GraphChunk], error)
+}
+
+type callGraphClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCallGraphClient(cc grpc.ClientConnInterface) CallGraphClient {
+	return &callGraphClient{cc}
+}
+
+func (c *callGraphClient) Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (*AnalyzeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnalyzeResponse)
+	err := c.cc.Invoke(ctx, CallGraph_Analyze_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *callGraphClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryResponse)
+	err := c.cc.Invoke(ctx, CallGraph_Query_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *callGraphClient) ExportGraph(ctx context.Context, in *ExportGraphRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GraphChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CallGraph_ServiceDesc.Streams[0], CallGraph_ExportGraph_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportGraphRequest, GraphChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CallGraph_ExportGraphClient = grpc.ServerStreamingClient[GraphChunk]
+
+// CallGraphServer is the server API for CallGraph service.
+// All implementations must embed UnimplementedCallGraphServer
+// for forward compatibility.
+type CallGraphServer interface {
+	// Analyze reports the paths from the functions declared in the source
+	// files to those declared in the sink files, as analyze -daemon does.
+	Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error)
+	// Query answers the questions of the query subcommand.
+	Query(context.Context, *QueryRequest) (*QueryResponse, error)
+	// ExportGraph streams the functions and calls of the graph, in chunks.
+	ExportGraph(*ExportGraphRequest, grpc.ServerStreamingServer[GraphChunk]) error
+	mustEmbedUnimplementedCallGraphServer()
+}
+
+// UnimplementedCallGraphServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCallGraphServer struct{}
+
+func (UnimplementedCallGraphServer) Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Analyze not implemented")
+}
+func (UnimplementedCallGraphServer) Query(context.Context, *QueryRequest) (*QueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedCallGraphServer) ExportGraph(*ExportGraphRequest, grpc.ServerStreamingServer[GraphChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportGraph not implemented")
+}
+func (UnimplementedCallGraphServer) mustEmbedUnimplementedCallGraphServer() {}
+func (UnimplementedCallGraphServer) testEmbeddedByValue()                   {}
+
+// UnsafeCallGraphServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CallGraphServer will
+// result in compilation errors.
+type UnsafeCallGraphServer interface {
+	mustEmbedUnimplementedCallGraphServer()
+}
+
+func RegisterCallGraphServer(s grpc.ServiceRegistrar, srv CallGraphServer) {
+	// If the following call pancis, it indicates UnimplementedCallGraphServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CallGraph_ServiceDesc, srv)
+}
+
+func _CallGraph_Analyze_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnalyzeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallGraphServer).Analyze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CallGraph_Analyze_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallGraphServer).Analyze(ctx, req.(*AnalyzeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CallGraph_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallGraphServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CallGraph_Query_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallGraphServer).Query(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CallGraph_ExportGraph_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportGraphRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CallGraphServer).ExportGraph(m, &grpc.GenericServerStream[ExportGraphRequest, GraphChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CallGraph_ExportGraphServer = grpc.ServerStreamingServer[GraphChunk]
+
+// CallGraph_ServiceDesc is the grpc.ServiceDesc for CallGraph service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CallGraph_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "callgraph.v1.CallGraph",
+	HandlerType: (*CallGraphServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Analyze",
+			Handler:    _CallGraph_Analyze_Handler,
+		},
+		{
+			MethodName: "Query",
+			Handler:    _CallGraph_Query_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportGraph",
+			Handler:       _CallGraph_ExportGraph_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "callgraph.proto",
+}
@@ -0,0 +1,5 @@
+// Package callgraphpb is the gRPC service of serve -grpc, generated from
+// callgraph.proto.
+package callgraphpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative callgraph.proto
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeownersLocations are where GitHub and GitLab look for CODEOWNERS, in
+// order.
+var codeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// ownerRule is a line of a CODEOWNERS file, with the regular expression its
+// pattern is matched as. A rule without owners leaves the files it matches
+// without any.
+type ownerRule struct {
+	pattern string
+	re      *regexp.Regexp
+	owners  []string
+}
+
+// codeOwners holds the rules of the CODEOWNERS file of the analyzed
+// directory. The last matching rule wins.
+type codeOwners []ownerRule
+
+// loadCodeOwners reads the first CODEOWNERS file found in the analyzed
+// directory, or none if there is none.
+func loadCodeOwners() (codeOwners, error) {
+	for _, loc := range codeownersLocations {
+		f, err := os.Open(filepath.Join(dir, loc))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		var rules codeOwners
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			re, err := ownerPattern(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", loc, err)
+			}
+			rules = append(rules, ownerRule{pattern: fields[0], re: re, owners: fields[1:]})
+		}
+		return rules, scanner.Err()
+	}
+	return nil, nil
+}
+
+// owners returns the owners of file, a path relative to the analyzed
+// directory.
+func (c codeOwners) owners(file string) []string {
+	file = filepath.ToSlash(file)
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].re.MatchString(file) {
+			return c[i].owners
+		}
+	}
+	return nil
+}
+
+// ownerPattern returns the regular expression matching the paths, relative
+// to the analyzed directory, of the files of a CODEOWNERS pattern, which
+// follows the gitignore syntax as GitHub reads it: a pattern starting with or
+// containing a / is anchored at the root, and otherwise matches at any depth;
+// a pattern matching a directory matches the files under it, and one ending
+// with / only matches directories; * and ? don't match a /, and a pattern
+// ending with /* only matches the files right in the directory; ** matches
+// any number of directories as a whole segment.
+func ownerPattern(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch {
+		case seg == "**" && last:
+			re.WriteString(".*")
+		case seg == "**":
+			re.WriteString("(?:.*/)?")
+		default:
+			if err := globRegexp(&re, seg); err != nil {
+				return nil, fmt.Errorf("pattern %q: %v", pattern, err)
+			}
+			if !last {
+				re.WriteString("/")
+			}
+		}
+	}
+	switch {
+	case dirOnly:
+		re.WriteString("/.*")
+	case segments[len(segments)-1] == "*" && len(segments) > 1, segments[len(segments)-1] == "**":
+	default:
+		re.WriteString("(?:/.*)?")
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// globRegexp writes the regular expression of a path segment of a glob
+// pattern to re.
+func globRegexp(re *strings.Builder, seg string) error {
+	for i := 0; i < len(seg); i++ {
+		switch c := seg[i]; c {
+		case '*':
+			re.WriteString("[^/]*")
+		case '?':
+			re.WriteString("[^/]")
+		case '\\':
+			if i++; i < len(seg) {
+				re.WriteString(regexp.QuoteMeta(seg[i : i+1]))
+			}
+		case '[':
+			end := strings.IndexByte(seg[i+1:], ']')
+			if end < 0 {
+				return errors.New("unterminated character class")
+			}
+			class := seg[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^/" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return nil
+}
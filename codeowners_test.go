@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestOwnerPattern checks the patterns of the example CODEOWNERS file of the
+// GitHub documentation against the files it says they match.
+func TestOwnerPattern(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		files   map[string]bool
+	}{
+		{"*", map[string]bool{"main.go": true, "internal/usecases/save.go": true}},
+		{"*.js", map[string]bool{"app.js": true, "web/static/app.js": true, "app.jsx": false, "main.go": false}},
+		{"*.go", map[string]bool{"main.go": true, "internal/usecases/save.go": true, "go.mod": false}},
+		// Anchored at the root with its subdirectories
+		{"/build/logs/", map[string]bool{"build/logs/a.log": true, "build/logs/2024/a.log": true, "src/build/logs/a.log": false, "build/logs": false}},
+		// Only the files right in docs, not further nested ones
+		{"docs/*", map[string]bool{"docs/getting-started.md": true, "docs/build-app/troubleshooting.md": false, "src/docs/a.md": false}},
+		// Any apps directory, at any depth
+		{"apps/", map[string]bool{"apps/a.go": true, "apps/web/a.go": true, "services/apps/a.go": true, "apps": false, "myapps/a.go": false}},
+		{"/docs/", map[string]bool{"docs/a.md": true, "docs/guides/a.md": true, "src/docs/a.md": false}},
+		{"/scripts/", map[string]bool{"scripts/deploy.sh": true, "tools/scripts/deploy.sh": false}},
+		// Any logs directory
+		{"**/logs", map[string]bool{"build/logs/a.log": true, "scripts/logs/a.log": true, "deeply/nested/logs/a.log": true, "logs/a.log": true, "catalogs/a.log": false}},
+		{"/apps/github", map[string]bool{"apps/github/a.go": true, "apps/github": true, "apps/githubber/a.go": false, "x/apps/github/a.go": false}},
+		{"docs/**/*.md", map[string]bool{"docs/a.md": true, "docs/guides/setup/a.md": true, "docs/a.txt": false, "src/docs/a.md": false}},
+		{"internal/", map[string]bool{"internal/store/put.go": true, "cmd/internal/flags.go": true}},
+		{"/cmd/*/main.go", map[string]bool{"cmd/api/main.go": true, "cmd/api/v2/main.go": false}},
+		{"save_v?.go", map[string]bool{"usecases/save_v2.go": true, "usecases/save_v10.go": false}},
+		{"[!a]*.go", map[string]bool{"b.go": true, "a.go": false}},
+	} {
+		re, err := ownerPattern(tt.pattern)
+		if err != nil {
+			t.Errorf("ownerPattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		for file, want := range tt.files {
+			if got := re.MatchString(file); got != want {
+				t.Errorf("%q matches %q: %v, want %v", tt.pattern, file, got, want)
+			}
+		}
+	}
+}
+
+func TestCodeOwnersLastRuleWins(t *testing.T) {
+	defer func(d string) { dir = d }(dir)
+	dir = fixture(t, map[string]string{".github/CODEOWNERS": `# The example of the GitHub documentation
+*       @global-owner1 @global-owner2
+*.js    @js-owner #This is an inline comment.
+/apps/ @octocat
+/apps/github
+`})
+	owners, err := loadCodeOwners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		"main.go":          "@global-owner1 @global-owner2",
+		"web/app.js":       "@js-owner",
+		"apps/app.js":      "@octocat",
+		"apps/github/a.go": "",
+	} {
+		if got := strings.Join(owners.owners(file), " "); got != want {
+			t.Errorf("owners of %s: %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configName is the file looked up in the analyzed directory when -config is
+// not given.
+const configName = "analysis.yaml"
+
+// Config is the content of analysis.yaml. Flags take precedence over it.
+type Config struct {
+	Repo         string     `yaml:"repo"`
+	OrgPrefix    string     `yaml:"org_prefix"`
+	Sources      []string   `yaml:"sources"`
+	ExcludeFiles []string   `yaml:"exclude_files" schema:"regexp"`
+	ExcludeFuncs []string   `yaml:"exclude_funcs" schema:"regexp"`
+	Policy       Policy     `yaml:"policy"`
+	Categories   []Category `yaml:"categories"`
+	Services     []Service  `yaml:"services"`
+}
+
+// Category labels the sinks whose full function name matches Functions, a
+// regular expression. The labels of the sinks an entrypoint reaches are the
+// labels of its -format=tickets draft.
+type Category struct {
+	Label     string `yaml:"label"`
+	Functions string `yaml:"functions" schema:"regexp"`
+}
+
+// Policy lists the conditions that make an analysis fail.
+type Policy struct {
+	// MaxAffectedEntrypoints fails the analysis when more sources than this
+	// reach a sink. Zero means no limit.
+	MaxAffectedEntrypoints int `yaml:"max_affected_entrypoints"`
+	// Forbid fails the analysis when a source matching Source reaches a sink
+	// matching Sink.
+	Forbid []ForbiddenPath `yaml:"forbid"`
+}
+
+// ForbiddenPath holds regular expressions matched against the full names of
+// the source and sink functions, e.g. "educabot.com/ted/internal/web\.".
+type ForbiddenPath struct {
+	Source string `yaml:"source" schema:"regexp"`
+	Sink   string `yaml:"sink" schema:"regexp"`
+}
+
+// defaultExcludeFile is the exclusion used when none is configured: the
+// files generated by Wire.
+const defaultExcludeFile = "wire_gen"
+
+var (
+	config       Config
+	excludeFiles []*regexp.Regexp
+	excludeFuncs []*regexp.Regexp
+
+	// Set by -exclude-file and -exclude-func, added to the exclusions of the
+	// configuration
+	excludeFileFlags patternList
+	excludeFuncFlags patternList
+)
+
+// patternList is a flag that can be repeated, collecting its values.
+type patternList []string
+
+func (l *patternList) String() string { return strings.Join(*l, ",") }
+
+func (l *patternList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// graphFlags registers the flags selecting the functions of the graph.
+func graphFlags(fs *flag.FlagSet) {
+	fs.Var(&excludeFileFlags, "exclude-file", "Regular expression over file paths relative to the repository whose functions are dropped from the graph (repeatable)")
+	fs.Var(&excludeFuncFlags, "exclude-func", "Regular expression over full function names dropped from the graph (repeatable)")
+	fs.Var(&includePkgs, "include-pkg", "Import path patterns, as for go list, of the packages of the module kept in the graph (repeatable, default: all)")
+	fs.Var(&excludePkgs, "exclude-pkg", "Import path patterns, as for go list, of the packages dropped from the graph (repeatable)")
+	fs.Var(&includeDeps, "include-deps", "Keep the functions of third-party packages in the graph, or of the comma-separated import paths given, to follow callbacks through them")
+	fs.Var(&includeStd, "include-std", "Keep the functions of the standard library in the graph, or of the comma-separated packages given, to follow callbacks through them")
+	fs.StringVar(&cacheDir, "cache", defaultCacheDir(), "Directory caching the graph, keyed by the content of the module, the flags building it and the build of the tool, to skip loading the packages when none changed (off: no cache)")
+	fs.Var(&maxMemory, "max-memory", "Memory budget of the analysis, e.g. 4GiB: the garbage collector keeps the heap under it where it can, and the graph is built without the call graph of the whole program")
+	fs.BoolVar(&incremental, "incremental", false, "With -cache, rebuild only the packages changed since the last build of the graph, and those importing them, patching the graph of that build")
+	progressFlags(fs)
+	profileFlags(fs)
+}
+
+// applyConfig loads -config, or analysis.yaml in the analyzed directory if it
+// exists, and fills in the flags that were not given.
+func applyConfig() error {
+	name := configPath
+	if name == "" {
+		base := "./"
+		if repo != "" || dirFlag != "" {
+			setDir()
+			base = dir
+		}
+		name = filepath.Join(base, configName)
+		if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+			return setExclusions("", nil, nil)
+		}
+	}
+
+	cfg, err := loadConfig(name)
+	if err != nil {
+		return err
+	}
+	config = cfg
+	if repo == "" {
+		repo = cfg.Repo
+	}
+	if orgPrefixFlag == "" {
+		orgPrefixFlag = cfg.OrgPrefix
+	}
+	if sourcesFlag == "" {
+		sourcesFlag = strings.Join(cfg.Sources, ",")
+	}
+	return setExclusions(name, cfg.ExcludeFiles, cfg.ExcludeFuncs)
+}
+
+// setExclusions compiles the exclusions of the configuration file name,
+// then those of the flags, falling back to defaultExcludeFile when no file
+// exclusion is given in either.
+func setExclusions(name string, files, funcs []string) error {
+	compile := func(what string, patterns []string) ([]*regexp.Regexp, error) {
+		var res []*regexp.Regexp
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", what, err)
+			}
+			res = append(res, re)
+		}
+		return res, nil
+	}
+	fileRes, err := compile(name+": exclude_files", files)
+	if err != nil {
+		return err
+	}
+	funcRes, err := compile(name+": exclude_funcs", funcs)
+	if err != nil {
+		return err
+	}
+	fileFlags, err := compile("-exclude-file", excludeFileFlags)
+	if err != nil {
+		return err
+	}
+	funcFlags, err := compile("-exclude-func", excludeFuncFlags)
+	if err != nil {
+		return err
+	}
+	excludeFiles = append(fileRes, fileFlags...)
+	excludeFuncs = append(funcRes, funcFlags...)
+	if len(excludeFiles) == 0 {
+		excludeFiles = []*regexp.Regexp{regexp.MustCompile(defaultExcludeFile)}
+	}
+	return nil
+}
+
+// loadConfig parses a configuration file, after validating it.
+func loadConfig(name string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return cfg, err
+	}
+	if err := validateConfig(name, data); err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", name, err)
+	}
+	return cfg, nil
+}
+
+// excludedFile returns whether the functions declared in a file are dropped
+// from the graph of the directory d by the file exclusions res, which match
+// the path relative to d.
+func excludedFile(d string, res []*regexp.Regexp) func(filename string) bool {
+	return func(filename string) bool {
+		rel := filepath.ToSlash(relPathTo(d, filename))
+		for _, re := range res {
+			if re.MatchString(rel) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// excludedFunc returns whether the function of a full name is dropped from
+// the graph by the function exclusions res.
+func excludedFunc(res []*regexp.Regexp) func(function string) bool {
+	return func(function string) bool {
+		for _, re := range res {
+			if re.MatchString(function) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// checkPolicy writes the violations of the configured policy and reports
+// whether there were any.
+func checkPolicy(w io.Writer, p Policy, results []SourceResult) (bool, error) {
+	affected, _ := summarize(results)
+	violations, err := policyViolations(p, affected, results)
+	if err != nil || len(violations) == 0 {
+		return false, err
+	}
+	fmt.Fprintln(w, msg("policyViolations"))
+	for _, v := range violations {
+		fmt.Fprintln(w, "  "+v)
+	}
+	return true, nil
+}
+
+// policyViolations returns the conditions of p violated by a run where
+// affected sources reach a sink, with the given findings.
+func policyViolations(p Policy, affected int, results []SourceResult) ([]string, error) {
+	var violations []string
+	if p.MaxAffectedEntrypoints > 0 && affected > p.MaxAffectedEntrypoints {
+		violations = append(violations, fmt.Sprintf(msg("policyMaxAffected"), affected, p.MaxAffectedEntrypoints))
+	}
+	for _, rule := range p.Forbid {
+		sourceRe, err := regexp.Compile(rule.Source)
+		if err != nil {
+			return nil, fmt.Errorf("policy forbid source: %v", err)
+		}
+		sinkRe, err := regexp.Compile(rule.Sink)
+		if err != nil {
+			return nil, fmt.Errorf("policy forbid sink: %v", err)
+		}
+		for _, res := range results {
+			if !sourceRe.MatchString(res.Source.Function) {
+				continue
+			}
+			for _, finding := range res.Findings {
+				if sinkRe.MatchString(finding.Sink.Function) {
+					violations = append(violations, fmt.Sprintf(msg("policyForbidden"), res.Source.Name, finding.Sink.Name))
+				}
+			}
+		}
+	}
+	return violations, nil
+}
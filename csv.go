@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// writeCSV writes the edge list to edges.csv and the hops of every finding to
+// paths.csv, both in outDir.
+func writeCSV(outDir string, edges []Edge, results []SourceResult) error {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	edges = append([]Edge(nil), edges...)
+	sortEdges(edges)
+	rows := [][]string{{"caller", "callee", "file", "line"}}
+	for _, e := range edges {
+		rows = append(rows, []string{e.Caller.Function, e.Callee.Function, relPath(e.File), strconv.Itoa(e.Line)})
+	}
+	if err := writeCSVFile(filepath.Join(outDir, "edges.csv"), rows); err != nil {
+		return err
+	}
+
+	rows = [][]string{{"source", "sink", "hop", "function", "file", "line", "call_file", "call_line"}}
+	for _, res := range results {
+		for _, finding := range res.Findings {
+			for i, frame := range finding.Path {
+				callFile, callLine := "", ""
+				if frame.Call != nil {
+					callFile, callLine = relPath(frame.Call.File), strconv.Itoa(frame.Call.Line)
+				}
+				rows = append(rows, []string{
+					res.Source.Function, finding.Sink.Function, strconv.Itoa(i),
+					frame.Function, relPath(frame.File), strconv.Itoa(frame.Line), callFile, callLine,
+				})
+			}
+		}
+	}
+	return writeCSVFile(filepath.Join(outDir, "paths.csv"), rows)
+}
+
+// sortEdges orders edges by caller and callee name, then by position.
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if a, b := edges[i].Caller.Function, edges[j].Caller.Function; a != b {
+			return a < b
+		}
+		if a, b := edges[i].Callee.Function, edges[j].Callee.Function; a != b {
+			return a < b
+		}
+		if edges[i].File != edges[j].File {
+			return edges[i].File < edges[j].File
+		}
+		return edges[i].Line < edges[j].Line
+	})
+}
+
+// writeCSVFile creates name holding rows.
+func writeCSVFile(name string, rows [][]string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
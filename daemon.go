@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// socketName is the daemon socket created in the analyzed directory when
+// -socket is not given.
+const socketName = ".callgraph.sock"
+
+// socketPath is the -socket flag shared by the daemon and its clients.
+var socketPath string
+
+// daemon keeps the call graph of a repository in memory and rebuilds it when
+// Go files changed since it was built.
+type daemon struct {
+	mu    sync.Mutex
+	graph *Graph
+	built time.Time
+	key   string                        // the cache key of graph
+	adj   map[*Func]map[*Func]bool      // searched by the query API, once asked
+	radj  map[*Func]map[*Func]bool      // adj reversed, once asked
+	scope *callgraphanalysis.ScopeIndex // of adj, once asked
+	eps   map[*Func]string              // the entrypoints by function, once asked
+	// kinds are the kinds of the entrypoints by full function name, from the
+	// last detection, of the graph kindsOf; detecting is set while one runs,
+	// and detectErr is the error of the last one if it failed
+	kinds     map[string]string
+	kindsOf   *Graph
+	detecting bool
+	detectErr string
+	// failure is the error of the last build if it failed, the graph being
+	// that of the build before
+	failure string
+	// stale is set when the files changed since the graph was built, by
+	// checkTree from tree, their state when it last looked, held by treeMu
+	stale  atomic.Bool
+	treeMu sync.Mutex
+	tree   treeState
+}
+
+// daemonStatus is the answer to GET /status.
+type daemonStatus struct {
+	Repo  string    `json:"repo"`
+	Dir   string    `json:"dir"`
+	Funcs int       `json:"funcs"`
+	Edges int       `json:"edges"`
+	Built time.Time `json:"built"`
+	Error string    `json:"error,omitempty"`
+}
+
+// runDaemon implements the daemon subcommand and its start, stop and status
+// actions.
+func runDaemon(args []string) {
+	const daemonUsage = "Usage: callgraph-analysis daemon start|stop|status [flags] [packages]\n"
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, daemonUsage)
+		os.Exit(2)
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("daemon "+action, flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	targetFlags(fs)
+	fs.StringVar(&socketPath, "socket", "", "Unix socket of the daemon (default: "+socketName+" in the analyzed directory)")
+	if action == "start" {
+		fs.StringVar(&configPath, "config", "", "Configuration file (default: analysis.yaml in the analyzed directory, if present)")
+		graphFlags(fs)
+		apiFlags(fs)
+	}
+	fs.Parse(args[1:])
+	startProfiling()
+	loadPatterns = fs.Args()
+
+	parseTestMode()
+	if action == "start" {
+		if err := applyConfig(); err != nil {
+			fatal("Error loading config:", err)
+		}
+	}
+	requireTarget()
+	if socketPath == "" {
+		socketPath = filepath.Join(dir, socketName)
+	}
+
+	switch action {
+	case "start":
+		startDaemon()
+	case "stop":
+		resp, err := daemonRequest(http.MethodPost, "/stop")
+		if err != nil {
+			fatal("Error stopping daemon:", err)
+		}
+		resp.Body.Close()
+	case "status":
+		resp, err := daemonRequest(http.MethodGet, "/status")
+		if err != nil {
+			fatal("Error querying daemon:", err)
+		}
+		defer resp.Body.Close()
+		io.Copy(os.Stdout, resp.Body)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown daemon action %q\n\n%s", action, daemonUsage)
+		os.Exit(2)
+	}
+}
+
+// startDaemon builds the graph and serves it on socketPath until stopped or
+// interrupted.
+func startDaemon() {
+	if _, err := daemonRequest(http.MethodGet, "/status"); err == nil {
+		fatalf("Error: a daemon is already listening on %s", socketPath)
+	}
+	os.Remove(socketPath)
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		fatal("Error listening:", err)
+	}
+	var apiLn net.Listener
+	if httpAddr != "" {
+		if apiLn, err = net.Listen("tcp", httpAddr); err != nil {
+			fatal("Error listening:", err)
+		}
+	}
+	var grpcLn net.Listener
+	if grpcAddr != "" {
+		if grpcLn, err = net.Listen("tcp", grpcAddr); err != nil {
+			fatal("Error listening:", err)
+		}
+	}
+
+	d := &daemon{}
+	if err := d.checkTree(); err != nil {
+		fatal("Error scanning the directory:", err)
+	}
+	if _, err := d.current(); err != nil {
+		fatal("Error building graph:", err)
+	}
+	go d.pollTree()
+
+	srv := &http.Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /graph", d.serveGraph)
+	mux.HandleFunc("GET /status", d.serveStatus)
+	mux.HandleFunc("POST /stop", func(w http.ResponseWriter, r *http.Request) {
+		go srv.Shutdown(context.Background())
+	})
+	// The queries are answered on the socket too, for the editors of the
+	// directory
+	mux.Handle("/", d.apiHandler())
+	srv.Handler = mux
+	if apiLn != nil {
+		api := &http.Server{Handler: d.apiHandler()}
+		srv.RegisterOnShutdown(func() { api.Shutdown(context.Background()) })
+		go func() {
+			if err := api.Serve(apiLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("Error serving the query API:", err)
+			}
+		}()
+		log.Printf("Serving the query API of %s on http://%s", module, apiLn.Addr())
+	}
+	if grpcLn != nil {
+		gs := d.newGRPCServer()
+		srv.RegisterOnShutdown(gs.GracefulStop)
+		go func() {
+			if err := gs.Serve(grpcLn); err != nil {
+				fatal("Error serving the gRPC service:", err)
+			}
+		}()
+		log.Printf("Serving the gRPC service of %s on %s", module, grpcLn.Addr())
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		srv.Shutdown(context.Background())
+	}()
+
+	log.Printf("Serving the call graph of %s on %s", module, socketPath)
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("Error serving:", err)
+	}
+	os.Remove(socketPath)
+}
+
+// treePollInterval is how often the daemon looks for changed files.
+const treePollInterval = time.Second
+
+// current returns the graph, rebuilding it first if a Go file or go.mod
+// changed since it was built, as last found by checkTree.
+func (d *daemon) current() (*Graph, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.refresh()
+}
+
+// refresh is current with d.mu held.
+func (d *daemon) refresh() (*Graph, error) {
+	stale := d.stale.Swap(false)
+	if d.graph == nil || stale {
+		start := time.Now()
+		g, kinds, key, err := buildTarget()
+		if err != nil && d.graph == nil {
+			return nil, err
+		}
+		if err != nil {
+			// The last graph is served until the next change
+			d.failure = err.Error()
+			log.Println("Warning: rebuilding the graph failed, serving the last one:", err)
+			return d.graph, nil
+		}
+		d.graph, d.built, d.failure, d.key = g, start, "", key
+		d.adj, d.radj, d.scope, d.eps, callSites = nil, nil, nil, nil, d.graph.CallSites()
+		log.Printf("Built %d functions and %d edges in %s", len(d.graph.Funcs), len(d.graph.Edges), time.Since(start).Round(time.Millisecond))
+		if kinds != nil {
+			d.kinds, d.kindsOf = kinds, g
+		} else if sourcesFlag == "" {
+			d.detectInBackground()
+		}
+	}
+	return d.graph, nil
+}
+
+// buildTarget builds the graph of the target as tryTargetGraph does, and
+// returns it with its cache key and, without -sources, the kinds of the
+// entrypoints detected in it by full function name, from the program loaded
+// for the graph or from the cache. They are nil when the graph was built
+// without loading the whole program, from the cache or -incremental, and the
+// cache has none for it.
+func buildTarget() (*Graph, map[string]string, string, error) {
+	key := graphCacheKey()
+	if g := cachedGraph(key); g != nil {
+		return g, cachedEntrypoints(key), key, nil
+	}
+	if sourcesFlag != "" || incremental && caching() {
+		g, err := tryNewGraph()
+		if err != nil {
+			return nil, nil, "", err
+		}
+		cacheGraph(key, g)
+		return g, nil, key, nil
+	}
+	prog, err := analyzer.Load(runCtx)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("loading packages: %w", err)
+	}
+	g, err := analyzer.BuildGraph(runCtx, prog)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("visiting edges: %w", err)
+	}
+	cacheGraph(key, g)
+	kinds, err := entrypointKinds(analyzer, prog)
+	if err != nil {
+		// Detected again in the background, which keeps the error
+		log.Println("Warning: detecting the entrypoints:", err)
+		return g, nil, key, nil
+	}
+	log.Printf("Detected %d entrypoints", len(kinds))
+	cacheEntrypoints(key, kinds)
+	return g, kinds, key, nil
+}
+
+// detectInBackground detects the entrypoints of the graph in a goroutine,
+// loading the packages with an analyzer of its own, as the queries and the
+// rebuilds go on meanwhile with the shared one. It is called with d.mu held,
+// and detects them again if the graph was rebuilt by the time it's done.
+func (d *daemon) detectInBackground() {
+	if d.detecting {
+		return
+	}
+	d.detecting = true
+	g, key := d.graph, d.key
+	go func() {
+		a := newAnalyzer()
+		a.Progress, a.Detectors = nil, analyzer.Detectors
+		log.Println("Detecting the entrypoints of the graph")
+		var kinds map[string]string
+		prog, err := a.Load(runCtx)
+		if err == nil {
+			kinds, err = entrypointKinds(a, prog)
+		}
+		if err == nil {
+			log.Printf("Detected %d entrypoints", len(kinds))
+			cacheEntrypoints(key, kinds)
+		} else {
+			log.Println("Warning: detecting the entrypoints, keeping the last ones:", err)
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.detecting = false
+		if err != nil {
+			d.detectErr = err.Error()
+		} else {
+			d.kinds, d.kindsOf, d.eps, d.detectErr = kinds, g, nil, ""
+		}
+		if d.graph != g {
+			d.detectInBackground()
+		}
+	}()
+}
+
+// serveGraph serves the graph to analyze -daemon, looking for changed files
+// first, as a run after an edit must not get the graph from before it.
+func (d *daemon) serveGraph(w http.ResponseWriter, r *http.Request) {
+	if err := d.checkTree(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	g, err := d.current()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := encodeGraph(w, g); err != nil {
+		log.Println("Error sending graph:", err)
+	}
+}
+
+func (d *daemon) serveStatus(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	status := daemonStatus{Repo: repo, Dir: dir, Funcs: len(d.graph.Funcs), Edges: len(d.graph.Edges), Built: d.built, Error: d.failure}
+	d.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// checkTree marks the graph stale if the files under dir changed since it
+// last looked.
+func (d *daemon) checkTree() error {
+	d.treeMu.Lock()
+	defer d.treeMu.Unlock()
+	tree, err := scanTree()
+	if err != nil {
+		return err
+	}
+	if tree.changedFrom(d.tree) {
+		d.tree = tree
+		d.stale.Store(true)
+	}
+	return nil
+}
+
+// pollTree runs checkTree every treePollInterval, so that the queries only
+// look at the stale flag instead of walking the directory.
+func (d *daemon) pollTree() {
+	for range time.Tick(treePollInterval) {
+		if err := d.checkTree(); err != nil {
+			log.Println("Warning: looking for changes:", err)
+		}
+	}
+}
+
+// treeState is the modification time of the files the graph is built from,
+// by path: the Go files, go.mod and go.sum under dir, and the modules.txt of
+// its vendor directories, which lists the vendored modules.
+type treeState map[string]time.Time
+
+// scanTree returns the state of the files under dir. Hidden directories,
+// testdata and vendor, but for its modules.txt, are skipped.
+func scanTree() (treeState, error) {
+	tree := make(treeState)
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := e.Name()
+		if e.IsDir() {
+			if name == "vendor" {
+				if info, err := os.Stat(filepath.Join(path, "modules.txt")); err == nil {
+					tree[filepath.Join(path, "modules.txt")] = info.ModTime()
+				}
+			}
+			if path != dir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") && name != "go.mod" && name != "go.sum" {
+			return nil
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		tree[path] = info.ModTime()
+		return nil
+	})
+	return tree, err
+}
+
+// changedFrom reports whether a file of tree was added, removed or modified
+// since old.
+func (tree treeState) changedFrom(old treeState) bool {
+	if len(tree) != len(old) {
+		return true
+	}
+	for name, t := range tree {
+		if o, ok := old[name]; !ok || !o.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// daemonRequest sends a request to the daemon on socketPath.
+func daemonRequest(method, path string) (*http.Response, error) {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	req, err := http.NewRequest(method, "http://daemon"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// fetchGraph gets the graph from the daemon.
+func fetchGraph() (*Graph, error) {
+	resp, err := daemonRequest(http.MethodGet, "/graph")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decodeGraph(resp.Body)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanTree(t *testing.T) {
+	defer func(d string) { dir = d }(dir)
+	dir = fixture(t, map[string]string{
+		"go.mod":              "module example.com/svc\n\ngo 1.21\n",
+		"api/handler.go":      "package api\n",
+		"api/old.go":          "package api\n",
+		"vendor/modules.txt":  "# example.com/lib v1.0.0\n",
+		"vendor/lib/lib.go":   "package lib\n",
+		"testdata/fixture.go": "package fixture\n",
+	})
+	scan := func() treeState {
+		t.Helper()
+		tree, err := scanTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree
+	}
+	tree := scan()
+	// Changes inside the past, so that they can't be told by time alone
+	past := time.Now().Add(-time.Hour)
+	for _, tt := range []struct {
+		name    string
+		change  func(string) error
+		file    string
+		changed bool
+	}{
+		{"non-Go file added", func(name string) error { return os.WriteFile(name, nil, 0o644) }, "README.md", false},
+		{"vendored source edited", func(name string) error { return os.Chtimes(name, past, past) }, "vendor/lib/lib.go", false},
+		{"Go file removed", os.Remove, "api/old.go", true},
+		{"Go file renamed", func(name string) error { return os.Rename(name, filepath.Join(dir, "api/new.go")) }, "api/handler.go", true},
+		{"Go file touched back in time", func(name string) error { return os.Chtimes(name, past, past) }, "api/new.go", true},
+		{"vendored modules updated", func(name string) error { return os.Chtimes(name, past, past) }, "vendor/modules.txt", true},
+	} {
+		if err := tt.change(filepath.Join(dir, tt.file)); err != nil {
+			t.Fatal(err)
+		}
+		now := scan()
+		if got := now.changedFrom(tree); got != tt.changed {
+			t.Errorf("%s: changed = %v, want %v", tt.name, got, tt.changed)
+		}
+		tree = now
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import "strings"
+
+// depsFlag is -include-deps or -include-std: the packages outside the module
+// whose functions are kept in the graph, so that paths going through
+// callbacks registered with them, like routers, worker pools or sort.Slice
+// comparators, are not lost. Given alone, it keeps every third-party package,
+// or every package of the standard library if std is set; given import paths,
+// those and their subpackages.
+type depsFlag struct {
+	std      bool
+	all      bool
+	prefixes []string
+}
+
+// includeDeps and includeStd are the -include-deps and -include-std flags.
+var (
+	includeDeps depsFlag
+	includeStd  = depsFlag{std: true}
+)
+
+func (d *depsFlag) IsBoolFlag() bool { return true }
+
+func (d *depsFlag) String() string {
+	if d.all {
+		return "true"
+	}
+	return strings.Join(d.prefixes, ",")
+}
+
+func (d *depsFlag) Set(v string) error {
+	switch v {
+	case "true":
+		d.all, d.prefixes = true, nil
+	case "false":
+		d.all, d.prefixes = false, nil
+	default:
+		d.all = false
+		for _, p := range strings.Split(v, ",") {
+			d.prefixes = append(d.prefixes, strings.TrimSuffix(strings.TrimSpace(p), "/..."))
+		}
+	}
+	return nil
+}
+
+// includes reports whether the functions of the package path are kept. The
+// packages of the standard library are told apart by their first path
+// element, which has no dot.
+func (d *depsFlag) includes(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	if std := !strings.Contains(first, "."); std != d.std {
+		return false
+	}
+	if d.all {
+		return true
+	}
+	for _, p := range d.prefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
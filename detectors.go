@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"plugin"
+	"strings"
+	"sync"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+	"golang.org/x/tools/go/ssa"
+)
+
+var (
+	pluginPaths   patternList // -plugin
+	detectorsFlag string      // -detectors
+)
+
+// detectorFlags registers the flags loading and selecting the source and
+// sink detectors.
+func detectorFlags(fs *flag.FlagSet) {
+	fs.Var(&pluginPaths, "plugin", "Go plugin, built with go build -buildmode=plugin, registering source and sink detectors (repeatable)")
+	fs.StringVar(&detectorsFlag, "detectors", "", "Comma-separated names of the registered detectors to run, or none (default: all)")
+}
+
+// detectorsWanted reports whether detectors may run: a plugin is loaded or
+// -detectors names some.
+func detectorsWanted() bool {
+	return len(pluginPaths) > 0 || detectorsFlag != "" && detectorsFlag != "none"
+}
+
+// loadDetectors opens the -plugin files, whose init functions register their
+// detectors, and sets the detectors of the analyzer to those of -detectors.
+func loadDetectors() error {
+	for _, name := range pluginPaths {
+		if _, err := plugin.Open(name); err != nil {
+			return err
+		}
+	}
+	switch detectorsFlag {
+	case "":
+		analyzer.Detectors = nil
+	case "none":
+		analyzer.Detectors = []callgraphanalysis.Detector{}
+	default:
+		analyzer.Detectors = []callgraphanalysis.Detector{}
+		for _, name := range strings.Split(detectorsFlag, ",") {
+			d := callgraphanalysis.LookupDetector(strings.TrimSpace(name))
+			if d == nil {
+				return fmt.Errorf("no detector %q is registered", name)
+			}
+			analyzer.Detectors = append(analyzer.Detectors, d)
+		}
+	}
+	return nil
+}
+
+// detections holds what the detectors found in the program detectedIn, which
+// both the entrypoints and the sinks are taken from. The daemon detects the
+// entrypoints of every program it loads, apart from its queries.
+var (
+	detectMu   sync.Mutex
+	detections map[string]callgraphanalysis.Detection
+	detectedIn *ssa.Program
+)
+
+// detect runs the detectors of a on prog, once, and returns what each found
+// by name.
+func detect(a *callgraphanalysis.Analyzer, prog *ssa.Program) (map[string]callgraphanalysis.Detection, error) {
+	detectMu.Lock()
+	defer detectMu.Unlock()
+	if detectedIn != prog {
+		found, err := a.Detect(prog)
+		if err != nil {
+			return nil, fmt.Errorf("running detectors: %w", err)
+		}
+		detections, detectedIn = found, prog
+	}
+	return detections, nil
+}
+
+// detectedSinks returns the full names of the sinks the detectors found in
+// prog.
+func detectedSinks(prog *ssa.Program) (map[string]bool, error) {
+	found, err := detect(analyzer, prog)
+	if err != nil {
+		return nil, err
+	}
+	sinks := make(map[string]bool)
+	for _, det := range found {
+		for _, fn := range det.Sinks {
+			sinks[fn.String()] = true
+		}
+	}
+	return sinks, nil
+}
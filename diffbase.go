@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// diffHunks returns the changed line ranges of the Go files of the analyzed
+// directory between the merge base of base and HEAD and the working tree, by
+// file relative to it. Deleted files are left out since they declare no
+// function anymore.
+func diffHunks(base string) (map[string][]lineRange, error) {
+	mergeBase, err := exec.Command("git", "-C", dir, "merge-base", base, "HEAD").Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	out, err := exec.Command("git", "-C", dir, "diff", "-U0", "--no-color", "--no-ext-diff", "--relative",
+		strings.TrimSpace(string(mergeBase)), "--", ".").Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+
+	hunks := make(map[string][]lineRange)
+	var file string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = ""
+			if name, ok := strings.CutPrefix(line, "+++ b/"); ok && strings.HasSuffix(name, ".go") {
+				file = name
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			r, err := parseHunk(line)
+			if err != nil {
+				return nil, fmt.Errorf("git diff: %s: %v", file, err)
+			}
+			hunks[file] = append(hunks[file], r)
+		}
+	}
+	return hunks, scanner.Err()
+}
+
+// parseHunk returns the lines of the new file covered by the hunk header
+// "@@ -a,b +c,d @@".
+func parseHunk(header string) (lineRange, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return lineRange{}, fmt.Errorf("malformed hunk header %q", header)
+	}
+	startText, countText, hasCount := strings.Cut(fields[2][1:], ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return lineRange{}, fmt.Errorf("malformed hunk header %q", header)
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return lineRange{}, fmt.Errorf("malformed hunk header %q", header)
+		}
+	}
+	if count == 0 {
+		// Only deletions, after line start
+		return lineRange{start + 1, start}, nil
+	}
+	return lineRange{start, start + count - 1}, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeDOT writes graph in the DOT language of Graphviz: a node per function,
+// labeled with its name and grouped in a cluster per package, and an edge per
+// pair of functions calling each other, labeled with the number of call sites
+// when there are several.
+func writeDOT(w io.Writer, graph *Graph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph callgraph {")
+	fmt.Fprintln(bw, "  node [shape=box];")
+
+	ids := make(map[*Func]int, len(graph.Funcs))
+	byPkg := make(map[string][]*Func)
+	for i, fn := range graph.Funcs {
+		ids[fn] = i
+		byPkg[fn.Pkg] = append(byPkg[fn.Pkg], fn)
+	}
+	for i, pkg := range graph.Packages() {
+		fmt.Fprintf(bw, "  subgraph cluster_%d {\n    label=%s;\n", i, dotQuote(pkg))
+		for _, fn := range byPkg[pkg] {
+			fmt.Fprintf(bw, "    n%d [label=%s, tooltip=%s];\n", ids[fn], dotQuote(fn.Name), dotQuote(fn.Function))
+		}
+		fmt.Fprintln(bw, "  }")
+	}
+	for _, e := range collapsedEdges(graph, ids) {
+		if e[2] > 1 {
+			fmt.Fprintf(bw, "  n%d -> n%d [label=%d];\n", e[0], e[1], e[2])
+		} else {
+			fmt.Fprintf(bw, "  n%d -> n%d;\n", e[0], e[1])
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote returns s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// lineRange is a range of lines of a file, from start to end included. Lines
+// deleted after line n are the empty range from n+1 to n, which only the
+// declarations around them overlap.
+type lineRange struct {
+	start, end int
+}
+
+// Declaration is a top-level function or method declaration enclosing some
+// of the lines asked for.
+type Declaration struct {
+	Name     string `json:"name"`
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	End      int    `json:"end"`
+
+	nameLine int // line of the name, as the position of its SSA function
+}
+
+// enclosingDecls returns the top-level function and method declarations of
+// the files of ranges, relative to dir, overlapping their lines, ordered by
+// file and line. The files are parsed as they are on disk, so the lines are
+// those of the working tree, //line directives aside. It is used when no
+// program is loaded, as by locate and for a cached graph; programDecls is
+// used otherwise.
+func enclosingDecls(ranges map[string][]lineRange) ([]Declaration, error) {
+	var decls []Declaration
+	fset := token.NewFileSet()
+	for file, rs := range ranges {
+		name, err := filepath.Abs(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		pkg := packagePath(filepath.ToSlash(filepath.Dir(relPath(name))), f.Name.Name)
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			start, end := fset.PositionFor(fd.Pos(), false).Line, fset.PositionFor(fd.End(), false).Line
+			for _, r := range rs {
+				if r.start <= end && r.end >= start {
+					decls = append(decls, Declaration{
+						Name:     fd.Name.Name,
+						Function: functionName(pkg, fd),
+						File:     name,
+						Line:     start,
+						End:      end,
+						nameLine: fset.PositionFor(fd.Name.Pos(), false).Line,
+					})
+					break
+				}
+			}
+		}
+	}
+	sortDecls(decls)
+	return decls, nil
+}
+
+// programDecls is enclosingDecls over the syntax trees of prog, of the files
+// as they were loaded: the declarations are those the functions of the graph
+// were built from, the ones of files left out by the build constraints
+// excluded, and are named by their SSA functions.
+func programDecls(prog *ssa.Program, ranges map[string][]lineRange) ([]Declaration, error) {
+	byFile := make(map[string][]lineRange, len(ranges))
+	for file, rs := range ranges {
+		name, err := filepath.Abs(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		byFile[name] = rs
+	}
+	var decls []Declaration
+	for fn := range ssautil.AllFunctions(prog) {
+		if fn.Parent() != nil || fn.Origin() != nil || fn.Synthetic != "" {
+			continue
+		}
+		fd, ok := fn.Syntax().(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		pos := prog.Fset.PositionFor(fd.Pos(), false)
+		end := prog.Fset.PositionFor(fd.End(), false).Line
+		for _, r := range byFile[pos.Filename] {
+			if r.start <= end && r.end >= pos.Line {
+				decls = append(decls, Declaration{
+					Name:     fn.Name(),
+					Function: fn.String(),
+					File:     pos.Filename,
+					Line:     pos.Line,
+					End:      end,
+					nameLine: prog.Fset.PositionFor(fn.Pos(), false).Line,
+				})
+				break
+			}
+		}
+	}
+	sortDecls(decls)
+	return decls, nil
+}
+
+// sortDecls orders decls by file and line.
+func sortDecls(decls []Declaration) {
+	sort.Slice(decls, func(i, j int) bool {
+		if decls[i].File != decls[j].File {
+			return decls[i].File < decls[j].File
+		}
+		return decls[i].Line < decls[j].Line
+	})
+}
+
+// packagePath returns the import path of the package named name in the
+// directory rel of the module, that of an external test package ending in
+// _test.
+func packagePath(rel, name string) string {
+	pkg := dirPackage(rel)
+	if strings.HasSuffix(name, "_test") {
+		pkg += "_test"
+	}
+	return pkg
+}
+
+// functionName returns the full name of the function declared by fd in the
+// package pkg, written as by its SSA function.
+func functionName(pkg string, fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return pkg + "." + fd.Name.Name
+	}
+	recv := fd.Recv.List[0].Type
+	ptr := ""
+	if star, ok := recv.(*ast.StarExpr); ok {
+		ptr, recv = "*", star.X
+	}
+	var params []string
+	switch t := recv.(type) {
+	case *ast.IndexExpr:
+		recv, params = t.X, []string{identName(t.Index)}
+	case *ast.IndexListExpr:
+		recv = t.X
+		for _, index := range t.Indices {
+			params = append(params, identName(index))
+		}
+	}
+	name := identName(recv)
+	if len(params) > 0 {
+		name += "[" + strings.Join(params, ",") + "]"
+	}
+	return "(" + ptr + pkg + "." + name + ")." + fd.Name.Name
+}
+
+// identName returns the name of the receiver type or type parameter expr.
+func identName(expr ast.Expr) string {
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name
+	}
+	return "_"
+}
+
+// key returns the declaration d is in the graph as.
+func (d Declaration) key() declKey {
+	return declKey{d.File, d.nameLine, d.Name}
+}
+
+// declKey identifies a function declaration of the graph by the file and
+// line of its name, as the Func of its SSA function.
+type declKey struct {
+	file string
+	line int
+	name string
+}
+
+// funcKey returns the declaration fn was built from, instantiations of a
+// generic function sharing the one of the generic function.
+func funcKey(fn *Func) declKey {
+	name, _, _ := strings.Cut(fn.Name, "[")
+	return declKey{fn.File, fn.Line, name}
+}
+
+// parseLocation reads a FILE:LINE or FILE:START-END argument of locate.
+func parseLocation(arg string) (string, lineRange, error) {
+	i := strings.LastIndex(arg, ":")
+	if i < 0 {
+		return "", lineRange{}, fmt.Errorf("%q: expected FILE:LINE or FILE:START-END", arg)
+	}
+	startText, endText, isRange := strings.Cut(arg[i+1:], "-")
+	start, err := strconv.Atoi(startText)
+	if err != nil || start < 1 {
+		return "", lineRange{}, fmt.Errorf("%q: invalid line %q", arg, startText)
+	}
+	end := start
+	if isRange {
+		if end, err = strconv.Atoi(endText); err != nil || end < start {
+			return "", lineRange{}, fmt.Errorf("%q: invalid line %q", arg, endText)
+		}
+	}
+	return arg[:i], lineRange{start, end}, nil
+}
+
+// runLocate implements the locate subcommand: it prints the top-level
+// function and method declarations enclosing the FILE:LINE or
+// FILE:START-END arguments, or the lines changed since -diff-base, as JSON.
+func runLocate(args []string) {
+	fs := flag.NewFlagSet("locate", flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	fs.StringVar(&dirFlag, "dir", "", "Directory of the repository the files are relative to (default: the current directory)")
+	fs.StringVar(&orgPrefixFlag, "org-prefix", "", "Import path prefix of the modules of the organization, completing a bare -repo name (default: the first element of the module path of go.mod)")
+	fs.StringVar(&diffBase, "diff-base", "", "Git ref to diff the working tree against, locating the lines changed since its merge base")
+	fs.Parse(args)
+
+	if (fs.NArg() == 0) == (diffBase == "") {
+		fatal("Error: give either FILE:LINE or FILE:START-END arguments or -diff-base")
+	}
+	requireTarget()
+
+	ranges := make(map[string][]lineRange)
+	if diffBase != "" {
+		var err error
+		if ranges, err = diffHunks(diffBase); err != nil {
+			fatal("Error diffing against the base:", err)
+		}
+	}
+	for _, arg := range fs.Args() {
+		file, r, err := parseLocation(arg)
+		if err != nil {
+			fatal("Error:", err)
+		}
+		ranges[file] = append(ranges[file], r)
+	}
+	decls, err := enclosingDecls(ranges)
+	if err != nil {
+		fatal("Error reading the files:", err)
+	}
+	for i := range decls {
+		decls[i].File = filepath.ToSlash(relPath(decls[i].File))
+	}
+	if decls == nil {
+		decls = []Declaration{}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(decls); err != nil {
+		fatal("Error writing declarations:", err)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph/cha"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// Entrypoint kinds reported by detectEntrypoints.
+const (
+	kindMain          = "main"
+	kindHTTP          = "http"
+	kindGRPC          = "grpc"
+	kindJob           = "job"
+	kindCLI           = "cli"
+	kindCloudFunction = "cloudfunction"
+)
+
+// Entrypoint is a function invoked from outside the program: the main of a
+// binary, an HTTP route handler, a gRPC method, a scheduled job, a CLI command,
+// a cloud function, or a source of a detector, whose name is its kind.
+type Entrypoint struct {
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	Function string   `json:"function"`
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Binaries []string `json:"binaries"`
+
+	fn        *ssa.Function
+	registrar *ssa.Function // function that registers fn, nil for main and signature matches
+}
+
+// httpVerbs are the router methods named after the HTTP method they
+// register (gin, echo, chi, gorilla/mux).
+var httpVerbs = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "HEAD": true, "OPTIONS": true, "CONNECT": true, "TRACE": true,
+}
+
+// routeMethods are the router methods, besides the verbs, that register an
+// HTTP handler for a path.
+var routeMethods = map[string]bool{
+	"Handle": true, "HandleFunc": true, "Any": true, "Method": true, "MethodFunc": true,
+}
+
+// jobMethods are the scheduler methods (robfig/cron, gocron) that register a
+// job with a schedule spec.
+var jobMethods = map[string]bool{"AddFunc": true, "AddJob": true, "Schedule": true}
+
+// cloudFunctionRegistrars are the functions-framework-go functions that
+// register a cloud function under a name.
+var cloudFunctionRegistrars = map[string]bool{
+	"HTTP":                              true,
+	"CloudEvent":                        true,
+	"RegisterHTTPFunctionContext":       true,
+	"RegisterEventFunctionContext":      true,
+	"RegisterCloudEventFunctionContext": true,
+}
+
+// detectEntrypoints walks every module function of a looking for mains,
+// handler registrations and cloud function signatures.
+func detectEntrypoints(a *callgraphanalysis.Analyzer, prog *ssa.Program) ([]*Entrypoint, error) {
+	var eps []*Entrypoint
+	seen := make(map[string]bool)
+	add := func(ep *Entrypoint) {
+		if ep.fn == nil || !a.InModule(ep.fn.String()) {
+			return
+		}
+		key := ep.Kind + " " + ep.Name + " " + ep.fn.String()
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		pos := prog.Fset.Position(ep.fn.Pos())
+		ep.Function = ep.fn.String()
+		ep.File = relPath(pos.Filename)
+		ep.Line = pos.Line
+		ep.Binaries = []string{}
+		eps = append(eps, ep)
+	}
+
+	for fn := range ssautil.AllFunctions(prog) {
+		if fn.Pkg == nil || fn.Synthetic != "" || !a.InModule(fn.String()) {
+			continue
+		}
+		if kind := signatureKind(a, fn); kind != "" {
+			name := fn.Name()
+			if kind == kindMain {
+				name = path.Base(fn.Pkg.Pkg.Path())
+			}
+			add(&Entrypoint{Kind: kind, Name: name, fn: fn})
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case ssa.CallInstruction:
+					for _, ep := range callEntrypoints(prog, instr.Common()) {
+						ep.registrar = fn
+						add(ep)
+					}
+				case *ssa.Store:
+					if ep := commandEntrypoint(prog, instr); ep != nil {
+						ep.registrar = fn
+						add(ep)
+					}
+				}
+			}
+		}
+	}
+
+	// The sources of the detectors are entrypoints of the kind named after
+	// them
+	found, err := detect(a, prog)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(found)) {
+		for _, fn := range found[name].Sources {
+			add(&Entrypoint{Kind: name, Name: fn.Name(), fn: fn})
+		}
+	}
+
+	sort.Slice(eps, func(i, j int) bool {
+		if eps[i].File != eps[j].File {
+			return eps[i].File < eps[j].File
+		}
+		if eps[i].Line != eps[j].Line {
+			return eps[i].Line < eps[j].Line
+		}
+		return eps[i].Name < eps[j].Name
+	})
+	return eps, nil
+}
+
+// signatureKind reports whether fn is an entrypoint by its declaration alone:
+// the main of a main package, or an exported function of the root package of
+// a module of a with an HTTP or background cloud function signature.
+func signatureKind(a *callgraphanalysis.Analyzer, fn *ssa.Function) string {
+	if fn.Parent() != nil || fn.Signature.Recv() != nil {
+		return ""
+	}
+	pkg := fn.Pkg.Pkg
+	if pkg.Name() == "main" && fn.Name() == "main" {
+		return kindMain
+	}
+	if !a.ModuleRoot(pkg.Path()) || !token.IsExported(fn.Name()) {
+		return ""
+	}
+	params := fn.Signature.Params()
+	if params.Len() != 2 {
+		return ""
+	}
+	first, second := params.At(0).Type().String(), params.At(1).Type().String()
+	if first == "net/http.ResponseWriter" && second == "*net/http.Request" {
+		return kindCloudFunction
+	}
+	results := fn.Signature.Results()
+	if first == "context.Context" && results.Len() == 1 && results.At(0).Type().String() == "error" {
+		return kindCloudFunction
+	}
+	return ""
+}
+
+// callEntrypoints returns the entrypoints registered by a call to a router,
+// gRPC server, scheduler or functions framework.
+func callEntrypoints(prog *ssa.Program, c *ssa.CallCommon) []*Entrypoint {
+	var name, pkgPath string
+	args := c.Args
+	if c.IsInvoke() {
+		name = c.Method.Name()
+	} else if callee := c.StaticCallee(); callee != nil {
+		name = callee.Name()
+		if callee.Pkg != nil {
+			pkgPath = callee.Pkg.Pkg.Path()
+		}
+		if callee.Signature.Recv() != nil && len(args) > 0 {
+			args = args[1:]
+		}
+	} else {
+		return nil
+	}
+
+	switch {
+	case httpVerbs[strings.ToUpper(name)] || routeMethods[name]:
+		strs := stringArgs(args)
+		handler := lastHandler(prog, args, "ServeHTTP")
+		if len(strs) == 0 || handler == nil {
+			return nil
+		}
+		route := strs[0]
+		if name == "Method" || name == "MethodFunc" {
+			if len(strs) < 2 {
+				return nil
+			}
+			route = strings.ToUpper(strs[0]) + " " + strs[1]
+		} else if httpVerbs[strings.ToUpper(name)] {
+			route = strings.ToUpper(name) + " " + route
+		}
+		if !strings.Contains(route, "/") {
+			return nil
+		}
+		return []*Entrypoint{{Kind: kindHTTP, Name: route, fn: handler}}
+
+	case jobMethods[name]:
+		strs := stringArgs(args)
+		handler := lastHandler(prog, args, "Run")
+		if len(strs) == 0 || handler == nil {
+			return nil
+		}
+		return []*Entrypoint{{Kind: kindJob, Name: strs[0], fn: handler}}
+
+	case cloudFunctionRegistrars[name] && strings.Contains(pkgPath, "functions-framework-go"):
+		strs := stringArgs(args)
+		handler := lastHandler(prog, args, "")
+		if len(strs) == 0 || handler == nil {
+			return nil
+		}
+		return []*Entrypoint{{Kind: kindCloudFunction, Name: strs[0], fn: handler}}
+
+	case !c.IsInvoke() && strings.HasPrefix(name, "Register") && strings.HasSuffix(name, "Server") && len(args) == 2:
+		return grpcEntrypoints(prog, c.StaticCallee(), args[1])
+	}
+	return nil
+}
+
+// grpcEntrypoints returns one entrypoint per service method implemented by
+// the server passed to a generated RegisterXxxServer function.
+func grpcEntrypoints(prog *ssa.Program, register *ssa.Function, srv ssa.Value) []*Entrypoint {
+	mi, ok := srv.(*ssa.MakeInterface)
+	if !ok {
+		return nil
+	}
+	named, ok := types.Unalias(register.Signature.Params().At(1).Type()).(*types.Named)
+	if !ok {
+		return nil
+	}
+	iface, ok := named.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	service := strings.TrimSuffix(named.Obj().Name(), "Server")
+	mset := prog.MethodSets.MethodSet(mi.X.Type())
+
+	var eps []*Entrypoint
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if !m.Exported() {
+			continue
+		}
+		sel := mset.Lookup(m.Pkg(), m.Name())
+		// Methods promoted from an embedded Unimplemented server are stubs.
+		if sel == nil || len(sel.Index()) > 1 {
+			continue
+		}
+		fn := prog.MethodValue(sel)
+		if fn != nil && fn.Synthetic != "" {
+			fn = prog.FuncValue(sel.Obj().(*types.Func))
+		}
+		eps = append(eps, &Entrypoint{Kind: kindGRPC, Name: "/" + service + "/" + m.Name(), fn: fn})
+	}
+	return eps
+}
+
+// commandEntrypoint recognizes stores of a handler into the Run, RunE or
+// Action field of a Command struct (cobra, urfave/cli) and names the entrypoint
+// after the command's Use or Name field.
+func commandEntrypoint(prog *ssa.Program, store *ssa.Store) *Entrypoint {
+	fa, ok := store.Addr.(*ssa.FieldAddr)
+	if !ok {
+		return nil
+	}
+	st, ok := commandStruct(fa.X.Type())
+	if !ok {
+		return nil
+	}
+	switch st.Field(fa.Field).Name() {
+	case "Run", "RunE", "Action":
+	default:
+		return nil
+	}
+	handler := resolveFunc(prog, store.Val, "")
+	if handler == nil {
+		return nil
+	}
+
+	name := handler.Name()
+	for _, ref := range *fa.X.Referrers() {
+		other, ok := ref.(*ssa.FieldAddr)
+		if !ok {
+			continue
+		}
+		if field := st.Field(other.Field).Name(); field != "Use" && field != "Name" {
+			continue
+		}
+		for _, r := range *other.Referrers() {
+			if s, ok := r.(*ssa.Store); ok {
+				if c, ok := s.Val.(*ssa.Const); ok && c.Value != nil && c.Value.Kind() == constant.String {
+					if fields := strings.Fields(constant.StringVal(c.Value)); len(fields) > 0 {
+						name = fields[0]
+					}
+				}
+			}
+		}
+	}
+	return &Entrypoint{Kind: kindCLI, Name: name, fn: handler}
+}
+
+// commandStruct returns the struct type behind a pointer to a type named
+// Command.
+func commandStruct(t types.Type) (*types.Struct, bool) {
+	ptr, ok := t.Underlying().(*types.Pointer)
+	if !ok {
+		return nil, false
+	}
+	named, ok := types.Unalias(ptr.Elem()).(*types.Named)
+	if !ok || named.Obj().Name() != "Command" {
+		return nil, false
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// stringArgs returns the constant string arguments of a call, in order.
+func stringArgs(args []ssa.Value) []string {
+	var strs []string
+	for _, arg := range args {
+		if c, ok := arg.(*ssa.Const); ok && c.Value != nil && c.Value.Kind() == constant.String {
+			strs = append(strs, constant.StringVal(c.Value))
+		}
+	}
+	return strs
+}
+
+// lastHandler returns the function behind the last argument that resolves to
+// one; routers take middlewares before the final handler.
+func lastHandler(prog *ssa.Program, args []ssa.Value, method string) *ssa.Function {
+	for i := len(args) - 1; i >= 0; i-- {
+		if fn := resolveFunc(prog, args[i], method); fn != nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+// resolveFunc returns the function a value refers to: a function, a closure,
+// a bound method, a converted function such as http.HandlerFunc(f), or the
+// given method of a concrete value stored in an interface.
+func resolveFunc(prog *ssa.Program, v ssa.Value, method string) *ssa.Function {
+	switch v := v.(type) {
+	case *ssa.Function:
+		return v
+	case *ssa.MakeClosure:
+		fn := v.Fn.(*ssa.Function)
+		if fn.Synthetic != "" {
+			if obj, ok := fn.Object().(*types.Func); ok {
+				return prog.FuncValue(obj)
+			}
+		}
+		return fn
+	case *ssa.ChangeType:
+		return resolveFunc(prog, v.X, method)
+	case *ssa.MakeInterface:
+		if fn := resolveFunc(prog, v.X, ""); fn != nil {
+			return fn
+		}
+		if method == "" {
+			return nil
+		}
+		sel := prog.MethodSets.MethodSet(v.X.Type()).Lookup(nil, method)
+		if sel == nil {
+			return nil
+		}
+		return prog.MethodValue(sel)
+	}
+	return nil
+}
+
+// assignBinaries records, for every entrypoint, the main packages of the
+// modules of a whose main or init reach the function that registers it.
+func assignBinaries(a *callgraphanalysis.Analyzer, prog *ssa.Program, eps []*Entrypoint) {
+	cg := cha.CallGraph(prog)
+	cg.DeleteSyntheticNodes()
+
+	for _, pkg := range prog.AllPackages() {
+		if pkg.Pkg.Name() != "main" || !a.InModule(pkg.Pkg.Path()) {
+			continue
+		}
+		main := pkg.Func("main")
+		if main == nil {
+			continue
+		}
+		reached := make(map[*ssa.Function]bool)
+		queue := []*ssa.Function{main}
+		if init := pkg.Func("init"); init != nil {
+			queue = append(queue, init)
+		}
+		for len(queue) > 0 {
+			fn := queue[0]
+			queue = queue[1:]
+			if reached[fn] || !a.InModule(fn.String()) {
+				continue
+			}
+			reached[fn] = true
+			queue = append(queue, fn.AnonFuncs...)
+			if node := cg.Nodes[fn]; node != nil {
+				for _, edge := range node.Out {
+					queue = append(queue, edge.Callee.Func)
+				}
+			}
+		}
+
+		binary := path.Base(pkg.Pkg.Path())
+		for _, ep := range eps {
+			target := ep.registrar
+			if target == nil {
+				target = ep.fn
+			}
+			if reached[target] {
+				ep.Binaries = append(ep.Binaries, binary)
+			}
+		}
+	}
+}
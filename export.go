@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nodeFeatures are the per-function features written by export features.
+type nodeFeatures struct {
+	InDegree  int     `json:"in_degree"`
+	OutDegree int     `json:"out_degree"`
+	Depth     int     `json:"depth"`    // calls from the nearest function without callers, -1 if none
+	Churn     int     `json:"churn"`    // commits touching the file in the -churn-since window
+	Coverage  float64 `json:"coverage"` // fraction of statements covered, -1 without -coverage
+}
+
+// featureNames name the columns of the PyTorch Geometric x matrix, before the
+// package one-hot columns.
+var featureNames = []string{"in_degree", "out_degree", "depth", "churn", "coverage"}
+
+// runExport implements the export subcommand. Its only kind is features.
+func runExport(args []string) {
+	const exportUsage = "Usage: callgraph-analysis export features [flags] [packages]\n"
+	if len(args) == 0 || args[0] != "features" {
+		fmt.Fprint(os.Stderr, exportUsage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("export features", flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	targetFlags(fs)
+	fs.StringVar(&configPath, "config", "", "Configuration file (default: analysis.yaml in the analyzed directory, if present)")
+	graphFlags(fs)
+	fs.StringVar(&graphPath, "graph", "", "Use a graph saved by the graph subcommand instead of loading the packages")
+	layout := fs.String("format", "networkx", "Output layout: networkx (node-link JSON) or pyg (PyTorch Geometric tensors as JSON)")
+	coverProfile := fs.String("coverage", "", "Go coverage profile (go test -coverprofile) to compute per-function coverage from")
+	churnSince := fs.String("churn-since", "90 days ago", "Start of the git history counted as churn, as accepted by git log --since")
+	output := fs.String("o", "", "File to write (default: stdout)")
+	fs.Parse(args[1:])
+	startProfiling()
+	loadPatterns = fs.Args()
+
+	parseTestMode()
+	if err := applyConfig(); err != nil {
+		fatal("Error loading config:", err)
+	}
+	if *layout != "networkx" && *layout != "pyg" {
+		fatalf("Error: format must be networkx or pyg, got %q", *layout)
+	}
+	requireTarget()
+
+	var graph *Graph
+	if graphPath != "" {
+		var err error
+		if graph, err = loadGraph(graphPath); err != nil {
+			fatal("Error loading graph:", err)
+		}
+	} else {
+		graph = targetGraph()
+	}
+
+	churn, err := fileChurn(*churnSince)
+	if err != nil {
+		fatal("Error reading git history:", err)
+	}
+	var coverage map[*Func]float64
+	if *coverProfile != "" {
+		if coverage, err = funcCoverage(graph, *coverProfile); err != nil {
+			fatal("Error reading coverage:", err)
+		}
+	}
+	features := graphFeatures(graph, churn, coverage)
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := createOutput(*output)
+		if err != nil {
+			fatal("Error creating output:", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *layout == "pyg" {
+		err = writePyG(w, graph, features)
+	} else {
+		err = writeNodeLink(w, graph, features)
+	}
+	if err != nil {
+		fatal("Error writing features:", err)
+	}
+}
+
+// graphFeatures computes the features of every function of g.
+func graphFeatures(g *Graph, churn map[string]int, coverage map[*Func]float64) map[*Func]*nodeFeatures {
+	features := make(map[*Func]*nodeFeatures, len(g.Funcs))
+	for _, fn := range g.Funcs {
+		nf := &nodeFeatures{Depth: -1, Churn: churn[filepath.ToSlash(relPath(fn.File))], Coverage: -1}
+		if c, ok := coverage[fn]; ok {
+			nf.Coverage = c
+		}
+		features[fn] = nf
+	}
+	adj := g.Adjacency()
+	for caller, callees := range adj {
+		for callee := range callees {
+			features[caller].OutDegree++
+			features[callee].InDegree++
+		}
+	}
+
+	// Breadth-first from every function without callers at once
+	var queue []*Func
+	for _, fn := range g.Funcs {
+		if features[fn].InDegree == 0 {
+			features[fn].Depth = 0
+			queue = append(queue, fn)
+		}
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		for next := range adj[fn] {
+			if features[next].Depth < 0 {
+				features[next].Depth = features[fn].Depth + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return features
+}
+
+// writeNodeLink writes g in the node-link JSON layout read by NetworkX's
+// node_link_graph, with the features as node attributes.
+func writeNodeLink(w io.Writer, g *Graph, features map[*Func]*nodeFeatures) error {
+	type node struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Function string `json:"function"`
+		Package  string `json:"package"`
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		*nodeFeatures
+	}
+	type link struct {
+		Source int `json:"source"`
+		Target int `json:"target"`
+		Calls  int `json:"calls"`
+	}
+	doc := struct {
+		Directed   bool           `json:"directed"`
+		Multigraph bool           `json:"multigraph"`
+		Graph      map[string]any `json:"graph"`
+		Nodes      []node         `json:"nodes"`
+		Links      []link         `json:"links"`
+	}{Directed: true, Graph: map[string]any{"module": module, "packages": g.Packages()}, Nodes: []node{}, Links: []link{}}
+
+	ids := make(map[*Func]int, len(g.Funcs))
+	for i, fn := range g.Funcs {
+		ids[fn] = i
+		doc.Nodes = append(doc.Nodes, node{
+			ID: i, Name: fn.Name, Function: fn.Function, Package: fn.Pkg,
+			File: filepath.ToSlash(relPath(fn.File)), Line: fn.Line, nodeFeatures: features[fn],
+		})
+	}
+	for _, l := range collapsedEdges(g, ids) {
+		doc.Links = append(doc.Links, link{Source: l[0], Target: l[1], Calls: l[2]})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writePyG writes g as the tensors of a PyTorch Geometric Data object: the
+// node feature matrix x, with the package one-hot after the features named in
+// featureNames, and the edge_index pair of rows.
+func writePyG(w io.Writer, g *Graph, features map[*Func]*nodeFeatures) error {
+	pkgs := g.Packages()
+	pkgIndex := make(map[string]int, len(pkgs))
+	for i, p := range pkgs {
+		pkgIndex[p] = i
+	}
+	doc := struct {
+		FeatureNames []string    `json:"feature_names"`
+		Packages     []string    `json:"packages"`
+		Functions    []string    `json:"functions"`
+		X            [][]float64 `json:"x"`
+		EdgeIndex    [2][]int    `json:"edge_index"`
+		EdgeWeight   []int       `json:"edge_weight"`
+	}{Packages: pkgs, Functions: []string{}, X: [][]float64{}, EdgeIndex: [2][]int{{}, {}}, EdgeWeight: []int{}}
+	doc.FeatureNames = append(doc.FeatureNames, featureNames...)
+	for _, p := range pkgs {
+		doc.FeatureNames = append(doc.FeatureNames, "package="+p)
+	}
+
+	ids := make(map[*Func]int, len(g.Funcs))
+	for i, fn := range g.Funcs {
+		ids[fn] = i
+		nf := features[fn]
+		row := make([]float64, len(featureNames)+len(pkgs))
+		row[0], row[1], row[2], row[3], row[4] = float64(nf.InDegree), float64(nf.OutDegree), float64(nf.Depth), float64(nf.Churn), nf.Coverage
+		row[len(featureNames)+pkgIndex[fn.Pkg]] = 1
+		doc.Functions = append(doc.Functions, fn.Function)
+		doc.X = append(doc.X, row)
+	}
+	for _, l := range collapsedEdges(g, ids) {
+		doc.EdgeIndex[0] = append(doc.EdgeIndex[0], l[0])
+		doc.EdgeIndex[1] = append(doc.EdgeIndex[1], l[1])
+		doc.EdgeWeight = append(doc.EdgeWeight, l[2])
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// collapsedEdges returns the caller, callee and number of call sites of every
+// pair of functions of g calling each other, by ids.
+func collapsedEdges(g *Graph, ids map[*Func]int) [][3]int {
+	calls := make(map[[2]int]int)
+	for _, e := range g.Edges {
+		calls[[2]int{ids[e.Caller], ids[e.Callee]}]++
+	}
+	edges := make([][3]int, 0, len(calls))
+	for pair, n := range calls {
+		edges = append(edges, [3]int{pair[0], pair[1], n})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// fileChurn counts the commits since the given date touching each file,
+// keyed by path relative to the analyzed directory.
+func fileChurn(since string) (map[string]int, error) {
+	out, err := exec.Command("git", "-C", dir, "log", "--since="+since, "--format=", "--name-only", "--relative").Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	churn := make(map[string]int)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			churn[line]++
+		}
+	}
+	return churn, nil
+}
+
+// funcCoverage computes the fraction of covered statements of every function
+// of g from a Go coverage profile. Blocks are attributed to the function of
+// their file declared last before them.
+func funcCoverage(g *Graph, profile string) (map[*Func]float64, error) {
+	f, err := os.Open(profile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	byFile := make(map[string][]*Func)
+	for _, fn := range g.Funcs {
+		rel := filepath.ToSlash(relPath(fn.File))
+		byFile[rel] = append(byFile[rel], fn)
+	}
+	for _, funcs := range byFile {
+		sort.Slice(funcs, func(i, j int) bool { return funcs[i].Line < funcs[j].Line })
+	}
+
+	total := make(map[*Func]int)
+	covered := make(map[*Func]int)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line == 1 && strings.HasPrefix(text, "mode:") || text == "" {
+			continue
+		}
+		// name.go:startLine.startCol,endLine.endCol numStmts count
+		colon := strings.LastIndex(text, ":")
+		fields := strings.Fields(text[colon+1:])
+		if colon < 0 || len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: malformed line", profile, line)
+		}
+		start, err := strconv.Atoi(strings.SplitN(fields[0], ".", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", profile, line, err)
+		}
+		stmts, err1 := strconv.Atoi(fields[1])
+		count, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%s:%d: malformed counts", profile, line)
+		}
+
+		file := strings.TrimPrefix(strings.TrimPrefix(text[:colon], module), "/")
+		var owner *Func
+		for _, fn := range byFile[file] {
+			if fn.Line > start {
+				break
+			}
+			owner = fn
+		}
+		if owner == nil {
+			continue
+		}
+		total[owner] += stmts
+		if count > 0 {
+			covered[owner] += stmts
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	coverage := make(map[*Func]float64)
+	for _, fn := range g.Funcs {
+		coverage[fn] = 0
+		if total[fn] > 0 {
+			coverage[fn] = float64(covered[fn]) / float64(total[fn])
+		}
+	}
+	return coverage, nil
+}
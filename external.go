@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"strings"
+)
+
+// ExternalCaller is a function of another repository calling a sink, as
+// recorded in its saved graph. File is relative to that repository.
+type ExternalCaller struct {
+	Module string `json:"module"`
+	Frame
+}
+
+// externalCallers holds the known callers of the functions of the module in
+// the -org-graphs, by full function name.
+var externalCallers map[string][]ExternalCaller
+
+// loadExternalCallers reads the graphs saved by the graph subcommand in other
+// repositories of the organization and indexes their calls into the module.
+// Calls from the same function through several call sites are kept once.
+func loadExternalCallers(names []string) (map[string][]ExternalCaller, error) {
+	callers := make(map[string][]ExternalCaller)
+	seen := make(map[[2]string]bool)
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readGraphFile(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if data.Module == module {
+			continue
+		}
+		for _, e := range data.External {
+			if !analyzer.InModule(e.Callee) {
+				continue
+			}
+			caller := data.Funcs[e.Caller]
+			if key := [2]string{caller.Function, e.Callee}; !seen[key] {
+				seen[key] = true
+				frame := Frame{Name: caller.Name, Function: caller.Function, File: caller.File, Line: caller.Line}
+				callers[e.Callee] = append(callers[e.Callee], ExternalCaller{Module: data.Module, Frame: frame})
+			}
+		}
+	}
+	return callers, nil
+}
+
+// publicAPI reports whether fn can be called from other modules: it is an
+// exported function or method, not an anonymous one, of a package outside
+// internal directories.
+func publicAPI(fn *Func) bool {
+	if !token.IsExported(fn.Name) || strings.Contains(fn.Name, "$") {
+		return false
+	}
+	for _, elem := range strings.Split(fn.Pkg, "/") {
+		if elem == "internal" {
+			return false
+		}
+	}
+	return true
+}
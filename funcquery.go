@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// functionAnswer is the answer to /function about one function: its callers
+// and callees, with the position of the calls, and the entrypoints reaching
+// it.
+type functionAnswer struct {
+	Function    Frame                `json:"function"`
+	Callers     []Frame              `json:"callers"`
+	Callees     []Frame              `json:"callees"`
+	Entrypoints []reachingEntrypoint `json:"entrypoints"`
+}
+
+// reachingEntrypoint is an entrypoint reaching the function of an answer,
+// with the path of the fewest calls from it. Kind is that of inventory, empty
+// for the sources of the configuration.
+type reachingEntrypoint struct {
+	Kind string  `json:"kind,omitempty"`
+	Path []Frame `json:"path"`
+}
+
+// functionQuery answers /function, for editors: about the functions declared
+// at the FILE:LINE or FILE:START-END of at, or else matching func, whom they
+// are called by, what they call and which entrypoints reach them.
+func (d *daemon) functionQuery(ctx context.Context, r *http.Request) (any, error) {
+	var fns []*Func
+	var err error
+	if at := r.URL.Query().Get("at"); at != "" {
+		fns, err = d.declaredAt(at)
+	} else {
+		fns, err = d.funcsParam(r, "func")
+	}
+	if err != nil {
+		return nil, err
+	}
+	eps, err := d.entrypoints()
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*Func, 0, len(eps))
+	for fn := range eps {
+		targets = append(targets, fn)
+	}
+	callers, callees := neighbors(d.graph, fns, true), neighbors(d.graph, fns, false)
+
+	answers := make([]functionAnswer, 0, len(fns))
+	for i, fn := range fns {
+		answer := functionAnswer{Function: newFrame(fn), Callers: callers[i].Functions, Callees: callees[i].Functions, Entrypoints: []reachingEntrypoint{}}
+		// Searched back from fn, over the callers
+		paths := analyzer.FindShortestPaths(fn, targets, d.callers(), callgraphanalysis.NewBudget(ctx, 0, 0))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, ep := range callgraphanalysis.SortFuncs(funcSet(targets)) {
+			if path := paths[ep]; path != nil {
+				slices.Reverse(path)
+				answer.Entrypoints = append(answer.Entrypoints, reachingEntrypoint{Kind: eps[ep], Path: pathFrames(path)})
+			}
+		}
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
+
+// declaredAt returns the functions of the graph declared by the top-level
+// declarations enclosing the lines of at, FILE:LINE or FILE:START-END with
+// FILE absolute or relative to the analyzed directory, as locate finds them.
+func (d *daemon) declaredAt(at string) ([]*Func, error) {
+	file, lines, err := parseLocation(at)
+	if err != nil {
+		return nil, badQuery("parameter at: %v", err)
+	}
+	decls, err := enclosingDecls(map[string][]lineRange{relPath(file): {lines}})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &apiError{http.StatusNotFound, err.Error()}
+	} else if err != nil {
+		return nil, badQuery("parameter at: %v", err)
+	}
+	keys := make(map[declKey]bool, len(decls))
+	for _, decl := range decls {
+		keys[decl.key()] = true
+	}
+	var fns []*Func
+	for _, fn := range d.graph.Funcs {
+		if keys[funcKey(fn)] {
+			fns = append(fns, fn)
+		}
+	}
+	if len(fns) == 0 {
+		return nil, &apiError{http.StatusNotFound, "no function of the graph is declared at " + at}
+	}
+	return fns, nil
+}
+
+// callers returns the reverse of the graph searched by the queries.
+func (d *daemon) callers() map[*Func]map[*Func]bool {
+	if d.radj == nil {
+		d.radj = callgraphanalysis.Reverse(d.adjacency())
+	}
+	return d.radj
+}
+
+// entrypoints returns the entrypoints of the graph with their kind: the
+// functions declared in the sources of the configuration, or else those
+// inventory detects, as detected when the graph was built or in the
+// background since. While a detection runs, or after it failed, those of the
+// last one are used.
+func (d *daemon) entrypoints() (map[*Func]string, error) {
+	if d.eps != nil {
+		return d.eps, nil
+	}
+	eps := make(map[*Func]string)
+	if sourcesFlag != "" {
+		files := make(map[string]bool)
+		for _, src := range strings.Split(sourcesFlag, ",") {
+			files[absPath(strings.TrimSpace(src))] = true
+		}
+		for _, fn := range d.graph.Funcs {
+			if files[fn.File] {
+				eps[fn] = ""
+			}
+		}
+	} else {
+		if d.kinds == nil {
+			// None detected yet, or the last detection failed
+			msg := "the entrypoints are being detected, try again shortly"
+			if d.detectErr != "" {
+				msg += ", the last detection failed: " + d.detectErr
+			}
+			d.detectInBackground()
+			return nil, &apiError{http.StatusServiceUnavailable, msg}
+		}
+		for _, fn := range d.graph.Funcs {
+			if kind, ok := d.kinds[fn.Function]; ok {
+				eps[fn] = kind
+			}
+		}
+	}
+	d.eps = eps
+	return eps, nil
+}
+
+// entrypointKinds returns the kinds of the entrypoints a detects in prog, by
+// full function name.
+func entrypointKinds(a *callgraphanalysis.Analyzer, prog *ssa.Program) (map[string]string, error) {
+	eps, err := detectEntrypoints(a, prog)
+	if err != nil {
+		return nil, err
+	}
+	kinds := make(map[string]string)
+	for _, ep := range eps {
+		kinds[ep.Function] = ep.Kind
+	}
+	return kinds, nil
+}
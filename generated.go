@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// skipGenerated holds what -skip-generated leaves the functions of generated
+// files out of: "sources", "sinks" and "paths", the calls in between.
+var skipGenerated map[string]bool
+
+// parseSkipGenerated splits the -skip-generated parts, "all" standing for
+// every one.
+func parseSkipGenerated(flag string) (map[string]bool, error) {
+	skip := make(map[string]bool)
+	for _, part := range strings.Split(flag, ",") {
+		switch part = strings.TrimSpace(part); part {
+		case "all":
+			skip["sources"], skip["sinks"], skip["paths"] = true, true, true
+		case "sources", "sinks", "paths":
+			skip[part] = true
+		default:
+			return nil, fmt.Errorf("unknown part %q, expected sources, sinks, paths or all", part)
+		}
+	}
+	return skip, nil
+}
+
+// dropGenerated removes the functions of generated files from the sources
+// and sinks, and from g except as the ends of a path, as -skip-generated
+// says.
+func dropGenerated(graph *Graph, g map[*Func]map[*Func]bool, sourceFuncs, sinkFuncs map[*Func]bool) map[*Func]map[*Func]bool {
+	for _, side := range []struct {
+		part  string
+		funcs map[*Func]bool
+	}{{"sources", sourceFuncs}, {"sinks", sinkFuncs}} {
+		if !skipGenerated[side.part] {
+			continue
+		}
+		for fn := range side.funcs {
+			if generatedFunc(fn) {
+				delete(side.funcs, fn)
+			}
+		}
+	}
+	if !skipGenerated["paths"] {
+		return g
+	}
+	keep := make(map[*Func]bool, len(graph.Funcs))
+	for _, fn := range graph.Funcs {
+		if sourceFuncs[fn] || sinkFuncs[fn] || !generatedFunc(fn) {
+			keep[fn] = true
+		}
+	}
+	return callgraphanalysis.Restrict(g, keep)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// writeGitHub writes the findings as GitHub Actions workflow commands, which
+// annotate the sink in the Files Changed view of the pull request: an error
+// for the pairs the policy forbids, a warning for the other findings and a
+// notice for the searches truncated before reaching a sink.
+func writeGitHub(w io.Writer, results []SourceResult, p Policy) error {
+	type rule struct{ source, sink *regexp.Regexp }
+	var forbid []rule
+	for _, r := range p.Forbid {
+		source, err := regexp.Compile(r.Source)
+		if err != nil {
+			return fmt.Errorf("policy forbid source: %v", err)
+		}
+		sink, err := regexp.Compile(r.Sink)
+		if err != nil {
+			return fmt.Errorf("policy forbid sink: %v", err)
+		}
+		forbid = append(forbid, rule{source, sink})
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, res := range results {
+		for _, finding := range res.Findings {
+			level, title := "warning", fmt.Sprintf(msg("ghTitle"), res.Source.Name)
+			message := fmt.Sprintf(msg("ghMessage"), finding.Sink.Name, res.Source.Name,
+				fmt.Sprintf(msg("hops"), len(finding.Path)-1), pathString(finding.Path))
+			for _, r := range forbid {
+				if r.source.MatchString(res.Source.Function) && r.sink.MatchString(finding.Sink.Function) {
+					level, title = "error", fmt.Sprintf(msg("policyForbidden"), res.Source.Name, finding.Sink.Name)
+					break
+				}
+			}
+			if finding.Truncated {
+				level, title = "notice", fmt.Sprintf(msg("ghTitle"), res.Source.Name)
+				message = fmt.Sprintf(msg("ghTruncated"), res.Source.Name, finding.Sink.Name)
+			}
+			file, line := finding.Sink.edited()
+			fmt.Fprintf(bw, "::%s file=%s,line=%d,title=%s::%s\n",
+				level, ghProperty(workspacePath(file)), line, ghProperty(title), ghData(message))
+		}
+	}
+	return bw.Flush()
+}
+
+// workspacePath returns file relative to GITHUB_WORKSPACE, the checkout the
+// annotations are resolved against, or to the analyzed directory outside
+// of GitHub Actions.
+func workspacePath(file string) string {
+	if ws := os.Getenv("GITHUB_WORKSPACE"); ws != "" {
+		if rel, err := filepath.Rel(ws, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(relPath(file))
+}
+
+// ghData escapes the message of a workflow command.
+func ghData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// ghProperty escapes a property value of a workflow command.
+func ghProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
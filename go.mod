@@ -1,12 +1,22 @@
-module entrypoints
+module educabot.com/callgraph-analysis
 
 go 1.23.0
 
 toolchain go1.23.1
 
-require golang.org/x/tools v0.31.0
+require (
+	golang.org/x/mod v0.24.0
+	golang.org/x/tools v0.31.0
+	gonum.org/v1/gonum v0.16.0
+	google.golang.org/grpc v1.72.2
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	golang.org/x/mod v0.24.0 // indirect
+	golang.org/x/net v0.37.0 // indirect
 	golang.org/x/sync v0.12.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a // indirect
 )
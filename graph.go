@@ -0,0 +1,314 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+	"golang.org/x/tools/go/ssa"
+)
+
+// The call graph of the library, which the CLI reports on.
+type (
+	Func         = callgraphanalysis.Func
+	Position     = callgraphanalysis.Position
+	Edge         = callgraphanalysis.Edge
+	ExternalCall = callgraphanalysis.ExternalCall
+	Graph        = callgraphanalysis.Graph
+)
+
+// orgPrefix is the import path prefix of the modules of the organization,
+// ending in a slash: -org-prefix, or else the first element of the module
+// path of the go.mod of the target.
+var orgPrefix string
+
+// analyzer is the analysis of the target, configured from the flags by
+// setTarget.
+var analyzer *callgraphanalysis.Analyzer
+
+// newAnalyzer configures an analysis of dir from the flags.
+func newAnalyzer() *callgraphanalysis.Analyzer {
+	a := &callgraphanalysis.Analyzer{
+		Dir:        dir,
+		Module:     module,
+		OrgPrefix:  orgPrefix,
+		Patterns:   loadPatterns,
+		Tags:       buildTags,
+		Tests:      withTests,
+		Logf:       log.Printf,
+		Progress:   libraryProgress(),
+		IncludeDep: func(path string) bool { return includeDeps.includes(path) || includeStd.includes(path) },
+		MaxDepth:   maxDepth,
+	}
+	a.Filters = graphFilters(a)
+	// The detectors of plugins may inspect the code of the dependencies
+	a.BuildDeps = len(pluginPaths) > 0
+	// Within a memory budget, the garbage collector runs as often as it needs
+	// to stay under it, and the graph is built without the CHA call graph of
+	// the whole program
+	if maxMemory > 0 {
+		debug.SetMemoryLimit(int64(maxMemory))
+		a.LowMemory = true
+	}
+	if goos != "" {
+		a.Env = append(a.Env, "GOOS="+goos)
+	}
+	if goarch != "" {
+		a.Env = append(a.Env, "GOARCH="+goarch)
+	}
+	if cgo != "" {
+		if cgo != "0" && cgo != "1" {
+			fatalf("Error: cgo must be 0 or 1, got %q", cgo)
+		}
+		a.Env = append(a.Env, "CGO_ENABLED="+cgo)
+	}
+	switch {
+	case allPaths:
+		a.Algorithm, a.PathLimit = callgraphanalysis.AllPaths, maxPaths
+	case kPaths > 0:
+		a.Algorithm, a.PathLimit = callgraphanalysis.KShortestPaths, kPaths
+	case shortest:
+		a.Algorithm = callgraphanalysis.ShortestPath
+	}
+	return a
+}
+
+// graphFilters returns the filters of the functions kept in the graph: the
+// file and function exclusions of the target, -exclude-pkg, and -include-pkg
+// for the functions of the module.
+func graphFilters(a *callgraphanalysis.Analyzer) []callgraphanalysis.FilterFunc {
+	filters := []callgraphanalysis.FilterFunc{
+		callgraphanalysis.Not(callgraphanalysis.ByFile(excludedFile(a.Dir, excludeFiles))),
+		callgraphanalysis.Not(callgraphanalysis.ByFunction(excludedFunc(excludeFuncs))),
+	}
+	if len(excludePkgs.res) > 0 {
+		filters = append(filters, callgraphanalysis.Not(callgraphanalysis.ByPackage(excludePkgs.matches)))
+	}
+	if len(includePkgs.res) > 0 {
+		own := callgraphanalysis.ByFunction(a.InModule)
+		filters = append(filters, callgraphanalysis.Any(callgraphanalysis.Not(own), callgraphanalysis.ByPackage(includePkgs.matches)))
+	}
+	return filters
+}
+
+// loadProgram loads the packages matching the pattern arguments, every
+// package of the module under dir by default, and builds their SSA form.
+func loadProgram() *ssa.Program {
+	prog, err := analyzer.Load(runCtx)
+	if err != nil {
+		failed("Error loading packages:", err)
+	}
+	return prog
+}
+
+// buildGraph computes the call graph of prog.
+func buildGraph(prog *ssa.Program) *Graph {
+	g, err := analyzer.BuildGraph(runCtx, prog)
+	if err != nil {
+		failed("Error visiting edges:", err)
+	}
+	return g
+}
+
+// scopePattern returns the import path pattern of -scope, which is taken
+// relative to the module unless it starts with its path.
+func scopePattern(pattern string) string {
+	if strings.HasPrefix(pattern, module) {
+		return pattern
+	}
+	return dirPackage(strings.TrimPrefix(pattern, "./"))
+}
+
+// dirPackage returns the import path of the directory rel of the analyzed
+// one: in the module under it declaring the directory, when it has no
+// go.mod.
+func dirPackage(rel string) string {
+	pkg, depth := path.Join(module, filepath.ToSlash(rel)), -1
+	if len(modules) > 0 {
+		abs, _ := filepath.Abs(filepath.Join(dir, rel))
+		for _, m := range modules {
+			r, err := filepath.Rel(m.Dir, abs)
+			if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+				continue
+			}
+			// The innermost module, for modules nested in others
+			if n := strings.Count(m.Dir, string(filepath.Separator)); n > depth {
+				pkg, depth = path.Join(m.Path, filepath.ToSlash(r)), n
+			}
+		}
+	}
+	return pkg
+}
+
+// sortFuncs returns the functions of set ordered by declaration position.
+func sortFuncs(set map[*Func]bool) []*Func {
+	return callgraphanalysis.SortFuncs(set)
+}
+
+// graphFileVersion is bumped whenever the layout of graphFile changes.
+const graphFileVersion = 4
+
+// graphFile is the serialized form of a Graph: functions are referenced by
+// index and files are relative to the analyzed directory, so the file can be
+// loaded on another checkout of the same commit.
+type graphFile struct {
+	Version  int
+	Module   string
+	Funcs    []Func
+	Edges    []graphFileEdge
+	External []graphFileExternal
+}
+
+type graphFileEdge struct {
+	Caller, Callee int
+	File           string
+	Line           int
+	Stringer       bool
+}
+
+type graphFileExternal struct {
+	Caller int
+	Callee string
+	File   string
+	Line   int
+}
+
+// saveGraph writes g to name, see encodeGraph.
+func saveGraph(name string, g *Graph) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := encodeGraph(f, g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// encodeGraph writes g to w as gzipped gob.
+func encodeGraph(w io.Writer, g *Graph) error {
+	data := graphFile{Version: graphFileVersion, Module: module}
+	index := make(map[*Func]int, len(g.Funcs))
+	for i, f := range g.Funcs {
+		index[f] = i
+		stored := *f
+		stored.File = filepath.ToSlash(relPath(f.File))
+		if f.Origin != nil {
+			stored.Origin = &Position{File: filepath.ToSlash(relPath(f.Origin.File)), Line: f.Origin.Line}
+		}
+		data.Funcs = append(data.Funcs, stored)
+	}
+	for _, e := range g.Edges {
+		data.Edges = append(data.Edges, graphFileEdge{
+			Caller:   index[e.Caller],
+			Callee:   index[e.Callee],
+			File:     filepath.ToSlash(relPath(e.File)),
+			Line:     e.Line,
+			Stringer: e.Stringer,
+		})
+	}
+	for _, e := range g.External {
+		data.External = append(data.External, graphFileExternal{
+			Caller: index[e.Caller],
+			Callee: e.Callee,
+			File:   filepath.ToSlash(relPath(e.File)),
+			Line:   e.Line,
+		})
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// loadGraph reads a graph written by saveGraph.
+func loadGraph(name string) (*Graph, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := decodeGraph(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return g, nil
+}
+
+// readGraphFile reads the serialized form written by encodeGraph, of any
+// module.
+func readGraphFile(r io.Reader) (*graphFile, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var data graphFile
+	if err := gob.NewDecoder(zr).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Version != graphFileVersion {
+		return nil, fmt.Errorf("unsupported graph file version %d", data.Version)
+	}
+	for _, e := range data.External {
+		if e.Caller < 0 || e.Caller >= len(data.Funcs) {
+			return nil, fmt.Errorf("corrupt external call from %d", e.Caller)
+		}
+	}
+	return &data, nil
+}
+
+// decodeGraph reads a graph written by encodeGraph, resolving its files
+// against the analyzed directory.
+func decodeGraph(r io.Reader) (*Graph, error) {
+	data, err := readGraphFile(r)
+	if err != nil {
+		return nil, err
+	}
+	if data.Module != module {
+		return nil, fmt.Errorf("graph of module %s, not %s", data.Module, module)
+	}
+
+	g := &Graph{}
+	for i := range data.Funcs {
+		fn := data.Funcs[i]
+		fn.File = absPath(fn.File)
+		if fn.Origin != nil {
+			fn.Origin.File = absPath(fn.Origin.File)
+		}
+		g.Funcs = append(g.Funcs, &fn)
+	}
+	for _, e := range data.Edges {
+		if e.Caller < 0 || e.Caller >= len(g.Funcs) || e.Callee < 0 || e.Callee >= len(g.Funcs) {
+			return nil, fmt.Errorf("corrupt edge %d -> %d", e.Caller, e.Callee)
+		}
+		g.Edges = append(g.Edges, Edge{Caller: g.Funcs[e.Caller], Callee: g.Funcs[e.Callee], File: absPath(e.File), Line: e.Line, Stringer: e.Stringer})
+	}
+	for _, e := range data.External {
+		g.External = append(g.External, ExternalCall{Caller: g.Funcs[e.Caller], Callee: e.Callee, File: absPath(e.File), Line: e.Line})
+	}
+	return g, nil
+}
+
+// absPath resolves a path relative to the analyzed directory; empty and
+// absolute paths are returned unchanged.
+func absPath(rel string) string {
+	if rel == "" || filepath.IsAbs(rel) {
+		return rel
+	}
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return rel
+	}
+	return filepath.Join(base, filepath.FromSlash(rel))
+}
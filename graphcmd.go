@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// runGraph implements the graph subcommand: it builds the pruned call graph
+// and saves it, so later analyze -graph runs can skip loading the packages.
+func runGraph(args []string) {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	targetFlags(fs)
+	fs.StringVar(&configPath, "config", "", "Configuration file (default: analysis.yaml in the analyzed directory, if present)")
+	graphFlags(fs)
+	output := fs.String("o", "callgraph.gob.gz", "File to write the graph to, or with -dirs the directory to write REPO.gob.gz to for each repository (default: the current directory)")
+	dirs := fs.String("dirs", "", "Comma-separated directories of repositories whose graphs are built in one run, each from its own go.mod, parsing the files they share once")
+	shard := fs.Bool("shard", false, "Build the part of the graph owned by the packages given, saving it to -o for -merge")
+	merge := fs.Bool("merge", false, "Merge the shards given as arguments, built with -shard, into the graph of the module")
+	fs.Parse(args)
+	startProfiling()
+	loadPatterns = fs.Args()
+
+	if *shard && *merge {
+		fatal("Error: -shard and -merge can't be combined")
+	}
+	if (*shard || *merge) && (*dirs != "" || incremental) {
+		fatal("Error: -shard and -merge can't be combined with -dirs or -incremental")
+	}
+	if *shard && !flagGiven(fs, "o") {
+		fatal("Error: -shard needs -o, the file to save the shard to")
+	}
+	// The arguments of -merge are the shards
+	var shards []string
+	if *merge {
+		shards, loadPatterns = loadPatterns, nil
+	}
+
+	if *dirs != "" {
+		if dirFlag != "" || repo != "" {
+			fatal("Error: -dirs can't be combined with -dir or -repo")
+		}
+		outDir := "."
+		if flagGiven(fs, "o") {
+			outDir = *output
+		}
+		runGraphs(strings.Split(*dirs, ","), outDir)
+		return
+	}
+
+	parseTestMode()
+	if err := applyConfig(); err != nil {
+		fatal("Error loading config:", err)
+	}
+	requireTarget()
+
+	switch {
+	case *shard:
+		buildShard(*output)
+		return
+	case *merge:
+		mergeShards(shards, *output)
+		return
+	}
+	graph := targetGraph()
+	if err := saveGraph(*output, graph); err != nil {
+		fatal("Error saving graph:", err)
+	}
+	log.Printf("Saved %d functions and %d edges to %s", len(graph.Funcs), len(graph.Edges), *output)
+}
+
+// runGraphs builds the graphs of the repositories in dirs, as a
+// MultiAnalyzer, and saves each in outDir as REPO.gob.gz and in the -cache
+// directory, for the analyze -graph or -cache runs of the repositories. A
+// repository that fails to build doesn't stop the others, but the command
+// still exits with an error.
+func runGraphs(dirs []string, outDir string) {
+	type target struct {
+		dir, module, repo, key string
+	}
+	var targets []target
+	multi := &callgraphanalysis.MultiAnalyzer{Logf: log.Printf, Progress: libraryProgress()}
+	for _, d := range dirs {
+		dirFlag, repo = strings.TrimSpace(d), ""
+		if err := applyConfig(); err != nil {
+			fatal("Error loading config:", err)
+		}
+		requireTarget()
+		targets = append(targets, target{dir: dir, module: module, repo: repo, key: graphCacheKey()})
+		multi.Analyzers = append(multi.Analyzers, analyzer)
+	}
+
+	progs, err := multi.Load(runCtx)
+	if progs == nil {
+		fatal("Error loading packages:", err)
+	}
+	warnModules("loading packages:", err)
+	graphs, err := multi.BuildGraphs(runCtx, progs)
+	if graphs == nil {
+		fatal("Error visiting edges:", err)
+	}
+	warnModules("visiting edges:", err)
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		fatal("Error saving graph:", err)
+	}
+	failed := 0
+	for i, t := range targets {
+		if graphs[i] == nil {
+			failed++
+			continue
+		}
+		// The files of the graph are stored relative to its repository
+		dir, module = t.dir, t.module
+		name := filepath.Join(outDir, strings.ReplaceAll(t.repo, "/", "_")+".gob.gz")
+		if err := saveGraph(name, graphs[i]); err != nil {
+			fatal("Error saving graph:", err)
+		}
+		cacheGraph(t.key, graphs[i])
+		log.Printf("Saved %d functions and %d edges to %s", len(graphs[i].Funcs), len(graphs[i].Edges), name)
+	}
+	if failed > 0 {
+		fatalf("Error: the graphs of %d of %d repositories could not be built", failed, len(targets))
+	}
+}
+
+// warnModules logs a warning for each module of err, the errors a
+// MultiAnalyzer joins, if any.
+func warnModules(prefix string, err error) {
+	if err == nil {
+		return
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		log.Println("Warning:", prefix, err)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GraphDiff holds the structural differences between two call graphs, by
+// full function name: positions are ignored since unrelated edits move them.
+// Functions and calls are those of the graph they are in, and the newly
+// reached functions those of the second graph.
+type GraphDiff struct {
+	AddedFuncs   []Frame        `json:"added_functions"`
+	RemovedFuncs []Frame        `json:"removed_functions"`
+	AddedCalls   []CallChange   `json:"added_calls"`
+	RemovedCalls []CallChange   `json:"removed_calls"`
+	NewlyReached []NewReachable `json:"newly_reached"`
+}
+
+// CallChange is a call from caller to callee found in one graph only, at the
+// position of its first call site.
+type CallChange struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+}
+
+// NewReachable holds the functions an entrypoint of both graphs reaches in
+// the second one only, each with the shortest path to it.
+type NewReachable struct {
+	Entrypoint Frame         `json:"entrypoint"`
+	Reached    []ReachedFunc `json:"reached"`
+}
+
+// ReachedFunc is a function newly reached from an entrypoint.
+type ReachedFunc struct {
+	Function Frame   `json:"function"`
+	Path     []Frame `json:"path"`
+}
+
+// runGraphDiff implements the diff graph subcommand: it compares the call
+// graphs of two git refs, saved graph files or the working tree. Like diff,
+// it exits with status 1 when they differ.
+func runGraphDiff(args []string) {
+	fs := flag.NewFlagSet("diff graph", flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	targetFlags(fs)
+	fs.StringVar(&configPath, "config", "", "Configuration file (default: analysis.yaml in the analyzed directory, if present)")
+	graphFlags(fs)
+	fs.StringVar(&format, "format", "text", "Output format: text or json")
+	fs.StringVar(&lang, "lang", "en", "Language of the result text: en or es")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: callgraph-analysis diff graph [flags] BEFORE [AFTER]\n\nBEFORE and AFTER are git refs or files saved by the graph subcommand; AFTER defaults to the working tree.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startProfiling()
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if format != "text" && format != "json" {
+		fatalf("Error: format must be text or json, got %q", format)
+	}
+	if catalogs[lang] == nil {
+		fatalf("Error: unsupported language %q", lang)
+	}
+	parseTestMode()
+	if err := applyConfig(); err != nil {
+		fatal("Error loading config:", err)
+	}
+	requireTarget()
+
+	before := graphAt(fs.Arg(0))
+	after := graphAt(fs.Arg(1))
+	d := diffGraphs(before, after)
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			fatal("Error writing graph diff:", err)
+		}
+	} else {
+		d.print(os.Stdout)
+	}
+	if !d.empty() {
+		exit(1)
+	}
+}
+
+// graphAt returns the graph of operand, a file saved by the graph
+// subcommand, a git ref or, if empty, the working tree, with its files
+// relative to the analyzed directory of its checkout.
+func graphAt(operand string) *Graph {
+	if operand == "" {
+		return relativeGraph(targetGraph(), dir)
+	}
+	if info, err := os.Stat(operand); err == nil && info.Mode().IsRegular() {
+		g, err := loadGraph(operand)
+		if err != nil {
+			fatal("Error loading graph:", err)
+		}
+		return relativeGraph(g, dir)
+	}
+
+	tmp, err := checkout(operand)
+	if err != nil {
+		fatalf("Error checking out %s: %v", operand, err)
+	}
+	defer exec.Command("git", "-C", dir, "worktree", "remove", "--force", tmp).Run()
+	prefix, err := exec.Command("git", "-C", dir, "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		fatal("Error reading the repository:", gitError(err))
+	}
+	savedDir, savedAnalyzer := dir, analyzer
+	dir = filepath.Join(tmp, strings.TrimSpace(string(prefix)))
+	analyzer = newAnalyzer()
+	defer func() { dir, analyzer = savedDir, savedAnalyzer }()
+	return relativeGraph(targetGraph(), dir)
+}
+
+// checkout adds a detached git worktree of ref in a temporary directory,
+// which the caller removes with git worktree remove.
+func checkout(ref string) (string, error) {
+	tmp, err := os.MkdirTemp("", "callgraph-ref")
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command("git", "-C", dir, "worktree", "add", "--quiet", "--detach", tmp, ref).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("git worktree add: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return tmp, nil
+}
+
+// relativeGraph makes the files of g relative to base, so that the graphs
+// of different checkouts read alike.
+func relativeGraph(g *Graph, base string) *Graph {
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return g
+	}
+	rel := func(name string) string {
+		if r, err := filepath.Rel(abs, name); err == nil && !strings.HasPrefix(r, "..") {
+			return filepath.ToSlash(r)
+		}
+		return name
+	}
+	for _, fn := range g.Funcs {
+		fn.File = rel(fn.File)
+		if fn.Origin != nil {
+			fn.Origin.File = rel(fn.Origin.File)
+		}
+	}
+	for i := range g.Edges {
+		g.Edges[i].File = rel(g.Edges[i].File)
+	}
+	return g
+}
+
+// diffGraphs compares the functions and calls of before and after, and the
+// functions the entrypoints of both reach.
+func diffGraphs(before, after *Graph) GraphDiff {
+	var d GraphDiff
+	beforeFuncs, afterFuncs := funcsByName(before), funcsByName(after)
+	for _, fn := range after.Funcs {
+		if beforeFuncs[fn.Function] == nil {
+			d.AddedFuncs = append(d.AddedFuncs, newFrame(fn))
+		}
+	}
+	for _, fn := range before.Funcs {
+		if afterFuncs[fn.Function] == nil {
+			d.RemovedFuncs = append(d.RemovedFuncs, newFrame(fn))
+		}
+	}
+	d.AddedCalls = callChanges(after, before)
+	d.RemovedCalls = callChanges(before, after)
+	d.NewlyReached = newlyReached(before, after)
+	return d
+}
+
+// funcsByName indexes the functions of g by full name.
+func funcsByName(g *Graph) map[string]*Func {
+	m := make(map[string]*Func, len(g.Funcs))
+	for _, fn := range g.Funcs {
+		m[fn.Function] = fn
+	}
+	return m
+}
+
+// callChanges returns the calls of g missing from other, in edge order.
+func callChanges(g, other *Graph) []CallChange {
+	sites, otherSites := g.CallSites(), other.CallSites()
+	var changes []CallChange
+	seen := make(map[[2]string]bool)
+	for _, e := range g.Edges {
+		pair := [2]string{e.Caller.Function, e.Callee.Function}
+		if _, ok := otherSites[pair]; ok || seen[pair] {
+			continue
+		}
+		seen[pair] = true
+		site := sites[pair]
+		changes = append(changes, CallChange{Caller: pair[0], Callee: pair[1], File: site.File, Line: site.Line})
+	}
+	return changes
+}
+
+// graphRoots returns the functions of g no other function calls, which are
+// the entrypoints of the program as far as the graph tells: mains, handlers
+// registered as function values, exported API.
+func graphRoots(g *Graph) []*Func {
+	called := make(map[*Func]bool)
+	for _, e := range g.Edges {
+		if e.Caller != e.Callee {
+			called[e.Callee] = true
+		}
+	}
+	var roots []*Func
+	for _, fn := range g.Funcs {
+		if !called[fn] {
+			roots = append(roots, fn)
+		}
+	}
+	return roots
+}
+
+// newlyReached returns, for every root of after that is in before too, the
+// functions it reaches in after but not in before.
+func newlyReached(before, after *Graph) []NewReachable {
+	beforeFuncs := funcsByName(before)
+	beforeAdj, afterAdj := before.Adjacency(), after.Adjacency()
+	var results []NewReachable
+	for _, root := range graphRoots(after) {
+		old := beforeFuncs[root.Function]
+		if old == nil {
+			continue
+		}
+		reachedBefore := make(map[string]bool)
+		for fn := range reachable(old, beforeAdj) {
+			reachedBefore[fn.Function] = true
+		}
+		parent := reachable(root, afterAdj)
+		res := NewReachable{Entrypoint: newFrame(root)}
+		for _, fn := range after.Funcs {
+			if _, ok := parent[fn]; !ok || reachedBefore[fn.Function] {
+				continue
+			}
+			var path []Frame
+			for p := fn; p != nil; p = parent[p] {
+				path = append(path, newFrame(p))
+			}
+			slices.Reverse(path)
+			res.Reached = append(res.Reached, ReachedFunc{Function: newFrame(fn), Path: path})
+		}
+		if len(res.Reached) > 0 {
+			results = append(results, res)
+		}
+	}
+	return results
+}
+
+// reachable returns the functions reachable from src in g, src included,
+// with the caller each was first reached from breadth-first, nil for src.
+func reachable(src *Func, g map[*Func]map[*Func]bool) map[*Func]*Func {
+	parent := map[*Func]*Func{src: nil}
+	queue := []*Func{src}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		for _, next := range sortFuncs(g[fn]) {
+			if _, seen := parent[next]; !seen {
+				parent[next] = fn
+				queue = append(queue, next)
+			}
+		}
+	}
+	return parent
+}
+
+// empty reports whether the graphs compared alike.
+func (d GraphDiff) empty() bool {
+	return len(d.AddedFuncs)+len(d.RemovedFuncs)+len(d.AddedCalls)+len(d.RemovedCalls) == 0
+}
+
+// print writes the differences in the human-readable text format.
+func (d GraphDiff) print(w io.Writer) {
+	if d.empty() {
+		fmt.Fprintln(w, msg("graphDiffNone"))
+		return
+	}
+	if len(d.AddedFuncs) > 0 {
+		fmt.Fprintf(w, msg("graphDiffAddedFuncs"), len(d.AddedFuncs))
+		for _, f := range d.AddedFuncs {
+			fmt.Fprintf(w, "  + %s (%s:%d)\n", f.Function, f.File, f.Line)
+		}
+	}
+	if len(d.RemovedFuncs) > 0 {
+		fmt.Fprintf(w, msg("graphDiffRemovedFuncs"), len(d.RemovedFuncs))
+		for _, f := range d.RemovedFuncs {
+			fmt.Fprintf(w, "  - %s (%s:%d)\n", f.Function, f.File, f.Line)
+		}
+	}
+	if len(d.AddedCalls) > 0 {
+		fmt.Fprintf(w, msg("graphDiffAddedCalls"), len(d.AddedCalls))
+		for _, c := range d.AddedCalls {
+			fmt.Fprintf(w, "  + %s -> %s (%s:%d)\n", c.Caller, c.Callee, c.File, c.Line)
+		}
+	}
+	if len(d.RemovedCalls) > 0 {
+		fmt.Fprintf(w, msg("graphDiffRemovedCalls"), len(d.RemovedCalls))
+		for _, c := range d.RemovedCalls {
+			fmt.Fprintf(w, "  - %s -> %s (%s:%d)\n", c.Caller, c.Callee, c.File, c.Line)
+		}
+	}
+	if len(d.NewlyReached) > 0 {
+		fmt.Fprintf(w, msg("graphDiffReached"), len(d.NewlyReached))
+		for _, r := range d.NewlyReached {
+			fmt.Fprintf(w, msg("graphDiffReaches"), r.Entrypoint.Function, len(r.Reached))
+			for _, f := range r.Reached {
+				fmt.Fprintf(w, "    + %s\n      %s\n", f.Function.Function, pathString(f.Path))
+			}
+		}
+	}
+}
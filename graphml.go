@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// The GraphML subset needed to export the call graph with node attributes.
+type (
+	graphML struct {
+		XMLName xml.Name     `xml:"graphml"`
+		XMLNS   string       `xml:"xmlns,attr"`
+		Keys    []graphMLKey `xml:"key"`
+		Graph   graphMLGraph `xml:"graph"`
+	}
+	graphMLKey struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+	graphMLGraph struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	}
+	graphMLNode struct {
+		ID   string        `xml:"id,attr"`
+		Data []graphMLData `xml:"data"`
+	}
+	graphMLEdge struct {
+		Source string        `xml:"source,attr"`
+		Target string        `xml:"target,attr"`
+		Data   []graphMLData `xml:"data"`
+	}
+	graphMLData struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+)
+
+// writeGraphML writes the pruned call graph as GraphML, marking the source
+// and sink functions.
+func writeGraphML(w io.Writer, graph *Graph, sourceFuncs, sinkFuncs map[*Func]bool) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "name", For: "node", Name: "name", Type: "string"},
+			{ID: "function", For: "node", Name: "function", Type: "string"},
+			{ID: "package", For: "node", Name: "package", Type: "string"},
+			{ID: "file", For: "node", Name: "file", Type: "string"},
+			{ID: "line", For: "node", Name: "line", Type: "int"},
+			{ID: "source", For: "node", Name: "source", Type: "boolean"},
+			{ID: "sink", For: "node", Name: "sink", Type: "boolean"},
+			{ID: "calls", For: "edge", Name: "calls", Type: "int"},
+		},
+		Graph: graphMLGraph{ID: "callgraph", EdgeDefault: "directed"},
+	}
+
+	ids := make(map[*Func]string)
+	for i, fn := range graph.Funcs {
+		id := fmt.Sprintf("n%d", i)
+		ids[fn] = id
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: id, Data: []graphMLData{
+			{Key: "name", Value: fn.Name},
+			{Key: "function", Value: fn.Function},
+			{Key: "package", Value: fn.Pkg},
+			{Key: "file", Value: relPath(fn.File)},
+			{Key: "line", Value: strconv.Itoa(fn.Line)},
+			{Key: "source", Value: strconv.FormatBool(sourceFuncs[fn])},
+			{Key: "sink", Value: strconv.FormatBool(sinkFuncs[fn])},
+		}})
+	}
+
+	// Parallel call sites are collapsed into one edge with a call count.
+	type pair struct{ caller, callee *Func }
+	calls := make(map[pair]int)
+	for _, e := range graph.Edges {
+		calls[pair{e.Caller, e.Callee}]++
+	}
+	pairs := make([]pair, 0, len(calls))
+	for p := range calls {
+		if ids[p.caller] != "" && ids[p.callee] != "" {
+			pairs = append(pairs, p)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if a, b := ids[pairs[i].caller], ids[pairs[j].caller]; a != b {
+			return a < b
+		}
+		return ids[pairs[i].callee] < ids[pairs[j].callee]
+	})
+	for _, p := range pairs {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: ids[p.caller],
+			Target: ids[p.callee],
+			Data:   []graphMLData{{Key: "calls", Value: strconv.Itoa(calls[p])}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+	pb "educabot.com/callgraph-analysis/callgraphpb"
+)
+
+// exportChunk is the number of functions and calls in a chunk streamed by
+// ExportGraph, which keeps the chunks well under the 4 MB gRPC clients accept
+// by default.
+const exportChunk = 1000
+
+// grpcServer serves the CallGraph service over the graph of the daemon.
+type grpcServer struct {
+	pb.UnimplementedCallGraphServer
+	d *daemon
+}
+
+// newGRPCServer returns the gRPC server of serve -grpc.
+func (d *daemon) newGRPCServer() *grpc.Server {
+	s := grpc.NewServer()
+	pb.RegisterCallGraphServer(s, &grpcServer{d: d})
+	return s
+}
+
+func (s *grpcServer) Analyze(ctx context.Context, req *pb.AnalyzeRequest) (*pb.AnalyzeResponse, error) {
+	algorithm, limit := callgraphanalysis.FirstPath, 0
+	switch req.Algorithm {
+	case pb.Algorithm_ALGORITHM_FIRST:
+	case pb.Algorithm_ALGORITHM_SHORTEST:
+		algorithm = callgraphanalysis.ShortestPath
+	case pb.Algorithm_ALGORITHM_ALL:
+		algorithm, limit = callgraphanalysis.AllPaths, 100
+	case pb.Algorithm_ALGORITHM_K_SHORTEST:
+		algorithm, limit = callgraphanalysis.KShortestPaths, 1
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown algorithm %v", req.Algorithm)
+	}
+	if req.MaxPaths < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "max_paths must not be negative, got %d", req.MaxPaths)
+	}
+	if req.MaxPaths > 0 {
+		limit = int(req.MaxPaths)
+	}
+	if len(req.Sinks) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "sinks are required")
+	}
+	if len(req.Sources) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "sources are required")
+	}
+
+	var results []SourceResult
+	err := s.d.run(ctx, func(ctx context.Context) error {
+		byFile := make(map[string][]*Func)
+		for _, fn := range s.d.graph.Funcs {
+			byFile[fn.File] = append(byFile[fn.File], fn)
+		}
+		declared := func(files []string) map[*Func]bool {
+			set := make(map[*Func]bool)
+			for _, file := range files {
+				for _, fn := range byFile[absPath(file)] {
+					set[fn] = true
+				}
+			}
+			return set
+		}
+		var err error
+		results, err = s.d.search(ctx, declared(req.Sources), declared(req.Sinks), algorithm, limit, req.Scope)
+		return err
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.AnalyzeResponse{}
+	for _, res := range results {
+		r := &pb.SourceResult{Source: pbFrame(res.Source), Truncated: pbFrames(res.Truncated)}
+		for _, f := range res.Findings {
+			r.Findings = append(r.Findings, &pb.Finding{Sink: pbFrame(f.Sink), Path: pbFrames(f.Path), PathCount: int32(f.PathCount)})
+		}
+		resp.Results = append(resp.Results, r)
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) Query(ctx context.Context, req *pb.QueryRequest) (*pb.QueryResponse, error) {
+	resp := &pb.QueryResponse{}
+	err := s.d.run(ctx, func(ctx context.Context) error {
+		fns, err := s.d.matching("function", req.Function)
+		if err != nil {
+			return err
+		}
+		switch req.Question {
+		case pb.Question_QUESTION_CALLERS, pb.Question_QUESTION_CALLEES:
+			for _, a := range neighbors(s.d.graph, fns, req.Question == pb.Question_QUESTION_CALLERS) {
+				resp.Neighbors = append(resp.Neighbors, &pb.Neighbors{Function: pbFrame(a.Function), Functions: pbFrames(a.Functions)})
+			}
+		case pb.Question_QUESTION_PATH:
+			to, err := s.d.matching("to", req.To)
+			if err != nil {
+				return err
+			}
+			path := shortestPath(ctx, fns, to, s.d.adjacency())
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			resp.Path = pbFrames(pathFrames(path))
+		default:
+			return badQuery("unknown question %v", req.Question)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) ExportGraph(req *pb.ExportGraphRequest, stream grpc.ServerStreamingServer[pb.GraphChunk]) error {
+	g, err := s.d.current()
+	if err != nil {
+		return grpcError(err)
+	}
+	if req.Pkg != "" {
+		g = packageGraph(g, req.Pkg)
+	}
+	// The functions come first, so every call names functions already sent
+	var chunk pb.GraphChunk
+	send := func(force bool) error {
+		if !force && len(chunk.Functions)+len(chunk.Calls) < exportChunk {
+			return nil
+		}
+		err := stream.Send(&chunk)
+		chunk = pb.GraphChunk{}
+		return err
+	}
+	for _, fn := range g.Funcs {
+		chunk.Functions = append(chunk.Functions, &pb.Function{Name: fn.Name, Function: fn.Function, Pkg: fn.Pkg, File: fn.File, Line: int32(fn.Line), Origin: pbPosition(fn.Origin)})
+		if err := send(false); err != nil {
+			return err
+		}
+	}
+	for _, e := range g.Edges {
+		chunk.Calls = append(chunk.Calls, &pb.Call{Caller: e.Caller.Function, Callee: e.Callee.Function, File: e.File, Line: int32(e.Line), Stringer: e.Stringer})
+		if err := send(false); err != nil {
+			return err
+		}
+	}
+	if len(chunk.Functions)+len(chunk.Calls) > 0 {
+		return send(true)
+	}
+	return nil
+}
+
+// grpcError returns err with the gRPC code matching the HTTP status the
+// query API answers it with.
+func grpcError(err error) error {
+	var e *apiError
+	switch {
+	case errors.As(err, &e) && e.status == http.StatusNotFound:
+		return status.Error(codes.NotFound, e.msg)
+	case errors.As(err, &e):
+		return status.Error(codes.InvalidArgument, e.msg)
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, "query timed out after %v", queryTimeout)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// pbFrame converts f to its message.
+func pbFrame(f Frame) *pb.Frame {
+	return &pb.Frame{Name: f.Name, Function: f.Function, File: f.File, Line: int32(f.Line), Origin: pbPosition(f.Origin), Call: pbPosition(f.Call)}
+}
+
+// pbFrames converts frames to their messages.
+func pbFrames(frames []Frame) []*pb.Frame {
+	var out []*pb.Frame
+	for _, f := range frames {
+		out = append(out, pbFrame(f))
+	}
+	return out
+}
+
+// pbPosition converts p to its message, nil for nil.
+func pbPosition(p *Position) *pb.Position {
+	if p == nil {
+		return nil
+	}
+	return &pb.Position{File: p.File, Line: int32(p.Line)}
+}
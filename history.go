@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// minHistory is the number of past records needed before a blast radius is
+// compared against the history.
+const minHistory = 10
+
+// historyRecord is one line of the history file, written for every run.
+type historyRecord struct {
+	Time                time.Time `json:"time"`
+	Repo                string    `json:"repo"`
+	Branch              string    `json:"branch"`
+	AffectedEntrypoints int       `json:"affected_entrypoints"`
+	Findings            int       `json:"findings"`
+	// Pairs are the sources reaching a sink, replayed by policy simulate.
+	// Records written by older versions don't have them.
+	Pairs []historyPair `json:"pairs,omitempty"`
+}
+
+// historyPair is a source reaching a sink in a recorded run, by full function
+// name.
+type historyPair struct {
+	Source string `json:"source"`
+	Sink   string `json:"sink"`
+}
+
+// blastRadius is the score compared against the history: the number of
+// source to sink findings.
+func (r historyRecord) blastRadius() int {
+	return r.Findings
+}
+
+// checkHistory compares the run against the recent runs of the repo when
+// -slo-percentile is set, then appends it to the history file.
+func checkHistory(results []SourceResult) error {
+	records, err := readHistory(historyFile)
+	if err != nil {
+		return err
+	}
+	affected, findings := summarize(results)
+	current := historyRecord{
+		Time:                time.Now().UTC(),
+		Repo:                repo,
+		Branch:              branchName(),
+		AffectedEntrypoints: affected,
+		Findings:            findings,
+	}
+	seen := make(map[historyPair]bool)
+	for _, res := range results {
+		for _, finding := range res.Findings {
+			pair := historyPair{res.Source.Function, finding.Sink.Function}
+			if !seen[pair] {
+				seen[pair] = true
+				current.Pairs = append(current.Pairs, pair)
+			}
+		}
+	}
+
+	if sloPct > 0 {
+		var recent []int
+		for _, r := range records {
+			if r.Repo == repo {
+				recent = append(recent, r.blastRadius())
+			}
+		}
+		if len(recent) > sloWindow {
+			recent = recent[len(recent)-sloWindow:]
+		}
+		reportBlastRadius(reportWriter(), current.blastRadius(), recent)
+	}
+
+	return appendHistory(historyFile, current)
+}
+
+// reportBlastRadius writes how score ranks among the recent scores, flagging
+// it as an outlier above the -slo-percentile threshold.
+func reportBlastRadius(w io.Writer, score int, recent []int) {
+	if len(recent) < minHistory {
+		fmt.Fprintf(w, msg("blastNoHistory"), score, len(recent), minHistory)
+		return
+	}
+	smaller := 0
+	for _, s := range recent {
+		if s < score {
+			smaller++
+		}
+	}
+	rank := 100 * float64(smaller) / float64(len(recent))
+	fmt.Fprintf(w, msg("blastRank"), score, rank, len(recent), sloPct, percentile(recent, sloPct))
+	if rank >= sloPct {
+		fmt.Fprintf(w, msg("blastOutlier"), sloPct)
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of values.
+func percentile(values []int, p float64) int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// readHistory returns the records of the history file, or none if it doesn't
+// exist yet.
+func readHistory(name string) ([]historyRecord, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []historyRecord
+	scanner := bufio.NewScanner(f)
+	// A record holds a pair for every source reaching a sink, so it runs well
+	// past the default line limit of the scanner
+	scanner.Buffer(nil, 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// appendHistory adds r as a new line of the history file.
+func appendHistory(name string, r historyRecord) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
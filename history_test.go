@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryLongRecord(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history.jsonl")
+	big := historyRecord{Repo: "ted", Findings: 5000}
+	for i := range big.Findings {
+		big.Pairs = append(big.Pairs, historyPair{
+			Source: fmt.Sprintf("example.com/ted/api.Handler%d", i),
+			Sink:   "example.com/ted/store.Put",
+		})
+	}
+	for _, r := range []historyRecord{big, {Repo: "ted", Findings: 1}} {
+		if err := appendHistory(name, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := readHistory(name)
+	if err != nil {
+		t.Fatalf("readHistory: %v", err)
+	}
+	if len(records) != 2 || len(records[0].Pairs) != big.Findings || records[1].Findings != 1 {
+		t.Errorf("read %d records, want the 2 written", len(records))
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// htmlReport is the data rendered by htmlTemplate.
+type htmlReport struct {
+	Repo      string
+	Sinks     []Frame
+	Results   []SourceResult
+	Timelines []sinkTimeline
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"msg":   msg,
+	"link":  sourceLink,
+	"blame": blameSuffix,
+	"rel":   func(file string) string { return filepath.ToSlash(relPath(file)) },
+	"cell": func(res SourceResult, sink Frame) *Finding {
+		for i := range res.Findings {
+			if res.Findings[i].Sink.Function == sink.Function {
+				return &res.Findings[i]
+			}
+		}
+		return nil
+	},
+	"anchor": func(src, sink Frame) string {
+		h := fnv.New64a()
+		h.Write([]byte(src.Function + "|" + sink.Function))
+		return fmt.Sprintf("p-%016x", h.Sum64())
+	},
+	"hops":  func(f *Finding) int { return len(f.Path) - 1 },
+	"count": formatPathCount,
+	"short": func(hash string) string { return hash[:min(len(hash), 10)] },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{msg "htmlTitle"}} · {{.Repo}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; font-size: 13px; }
+th.sink { writing-mode: vertical-rl; transform: rotate(180deg); text-align: left; }
+td.hit { background: #ffd8d3; text-align: center; }
+td.hit a { color: #cf222e; text-decoration: none; font-weight: bold; }
+details { margin: 4px 0 4px 1em; }
+summary { cursor: pointer; }
+ol { font-family: ui-monospace, Menlo, monospace; font-size: 12px; }
+a { color: #0969da; }
+.muted { color: #656d76; }
+.timeline { display: flex; flex-wrap: wrap; gap: 1em; margin-bottom: 2em; }
+.panel { border: 1px solid #d0d7de; border-radius: 6px; padding: 8px 12px; min-width: 20em; font-size: 13px; }
+.panel h3 { margin: 0 0 4px 0; font-size: 14px; }
+.panel ul { list-style: none; padding-left: 0; margin: 4px 0; }
+.panel li { border-left: 2px solid #d0d7de; padding: 2px 0 2px 8px; }
+.panel li.entry { border-left-color: #cf222e; }
+code { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>{{msg "htmlTitle"}} · {{.Repo}}</h1>
+
+<h2>{{msg "htmlSummary"}}</h2>
+<table>
+<tr><th></th>{{range .Sinks}}<th class="sink" title="{{.Function}}">{{.Name}}</th>{{end}}</tr>
+{{- $sinks := .Sinks}}
+{{range $res := .Results}}<tr><th title="{{$res.Source.Function}}">{{$res.Source.Name}}</th>
+{{- range $sink := $sinks}}{{with cell $res $sink}}<td class="hit"><a href="#{{anchor $res.Source $sink}}" title="{{printf (msg "hops") (hops .)}}">●</a></td>{{else}}<td></td>{{end}}{{end}}</tr>
+{{end}}</table>
+
+{{with .Timelines}}<h2>{{msg "htmlTimeline"}}</h2>
+<div class="timeline">
+{{range .}}<div class="panel">
+<h3 title="{{.Sink.Function}}">{{.Sink.Name}} <a class="muted" href="{{link .Sink}}">{{rel .Sink.File}}:{{.Sink.Line}}</a></h3>
+<ul>{{range .Commits}}<li><span class="muted">{{date .Date}}</span> <code>{{short .Hash}}</code> {{.Subject}} <span class="muted">· {{.Author}}</span></li>
+{{else}}<li class="muted">{{msg "htmlNoCommits"}}</li>
+{{end}}</ul>
+<div class="muted">{{printf (msg "htmlReachedFrom") (len .Entrypoints)}}</div>
+<ul>{{range .Entrypoints}}<li class="entry">{{.Name}} <a class="muted" href="{{link .}}">{{rel .File}}:{{.Line}}</a></li>{{end}}</ul>
+</div>
+{{end}}</div>
+{{end}}
+
+<h2>{{msg "htmlPaths"}}</h2>
+{{range $res := .Results}}
+<h3>{{$res.Source.Name}} <a class="muted" href="{{link $res.Source}}">{{rel $res.Source.File}}:{{$res.Source.Line}}</a></h3>
+{{range .Findings}}<details id="{{anchor $res.Source .Sink}}">
+<summary>{{.Sink.Name}} <span class="muted">({{printf (msg "hops") (hops .)}}{{if gt .PathCount 1}}, {{printf (msg "paths") (count .PathCount)}}{{end}})</span></summary>
+<ol>{{range .Path}}<li>{{.Name}} <a href="{{link .}}">{{rel .File}}:{{.Line}}</a>{{with .Call}}<span class="muted">{{printf (msg "calledAt") (rel .File) .Line}}</span>{{end}}{{with blame .}}<span class="muted">{{.}}</span>{{end}}</li>{{end}}</ol>
+</details>
+{{else}}<p class="muted">{{msg "noSinks"}}</p>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// writeHTML writes a single-file HTML report with a sources × sinks summary
+// table, the timeline of every reached sink and the path of every finding.
+func writeHTML(w io.Writer, results []SourceResult, sinks []Frame) error {
+	timelines, err := sinkTimelines(results, sinks)
+	if err != nil {
+		log.Println("Warning: timeline without git history:", err)
+	}
+	return htmlTemplate.Execute(w, htmlReport{Repo: repo, Sinks: sinks, Results: results, Timelines: timelines})
+}
+
+// sourceLink returns the URL of a frame's position in the file to edit: under
+// -source-url when set, otherwise the local file.
+func sourceLink(f Frame) string {
+	file, line := f.edited()
+	if sourceURL == "" {
+		return "file://" + filepath.ToSlash(file)
+	}
+	return fmt.Sprintf("%s/%s#L%d", strings.TrimSuffix(sourceURL, "/"), filepath.ToSlash(relPath(file)), line)
+}
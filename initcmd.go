@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/analysis.yaml.tmpl
+var configTemplateText string
+
+var configTemplate = template.Must(template.New("analysis.yaml").Funcs(template.FuncMap{
+	// quote renders a YAML single-quoted scalar.
+	"quote": func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" },
+}).Parse(configTemplateText))
+
+// generatedHeader is the comment the Go convention requires at the top of
+// generated files.
+var generatedHeader = regexp.MustCompile(`^// Code generated (.*)DO NOT EDIT\.$`)
+
+// scaffold is the data rendered by configTemplate.
+type scaffold struct {
+	Repo       string
+	Module     string
+	Sources    []scaffoldEntry
+	Exclusions []scaffoldEntry
+}
+
+// scaffoldEntry is a list value of the generated config with the comment
+// explaining why it was proposed.
+type scaffoldEntry struct {
+	Value string
+	Note  string
+}
+
+// exampleScaffold is printed by init -example.
+var exampleScaffold = scaffold{
+	Repo:   "ted",
+	Module: "educabot.com/ted",
+	Sources: []scaffoldEntry{
+		{Value: "functions.go", Note: "cloudfunction: SaveVideo"},
+		{Value: "src/app/web/mapping.go", Note: "http: POST /videos, GET /videos/{id}"},
+	},
+	Exclusions: []scaffoldEntry{
+		{Value: `wire_gen\.go$`, Note: "generated by Wire"},
+		{Value: `(^|/)mocks/`, Note: "generated mocks"},
+	},
+}
+
+// runInit implements the init subcommand: it detects the entrypoints and the
+// generated code of the repository and writes a starter analysis.yaml.
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	targetFlags(fs)
+	output := fs.String("o", "", "File to write (default: analysis.yaml in the analyzed directory)")
+	force := fs.Bool("force", false, "Overwrite an existing file")
+	example := fs.Bool("example", false, "Print an annotated example configuration and exit")
+	fs.Parse(args)
+	loadPatterns = fs.Args()
+
+	if *example {
+		if err := configTemplate.Execute(os.Stdout, exampleScaffold); err != nil {
+			fatal("Error writing example:", err)
+		}
+		return
+	}
+
+	parseTestMode()
+	requireTarget()
+
+	name := *output
+	if name == "" {
+		name = filepath.Join(dir, configName)
+	}
+	if _, err := os.Stat(name); err == nil && !*force {
+		fatalf("Error: %s already exists, use -force to overwrite it", name)
+	}
+
+	exclusions, generated, err := detectGenerated()
+	if err != nil {
+		fatal("Error scanning generated files:", err)
+	}
+
+	prog := loadProgram()
+	eps, err := detectEntrypoints(analyzer, prog)
+	if err != nil {
+		fatal("Error detecting entrypoints:", err)
+	}
+	byFile := make(map[string][]string)
+	for _, ep := range eps {
+		if ep.Kind == kindMain || generated[ep.File] {
+			continue
+		}
+		byFile[ep.File] = append(byFile[ep.File], ep.Kind+": "+ep.Name)
+	}
+	data := scaffold{Repo: repo, Module: module, Exclusions: exclusions}
+	for file, names := range byFile {
+		note := strings.Join(names, ", ")
+		if len(names) > 3 {
+			note = strings.Join(names[:3], ", ") + fmt.Sprintf(" and %d more", len(names)-3)
+		}
+		data.Sources = append(data.Sources, scaffoldEntry{Value: filepath.ToSlash(file), Note: note})
+	}
+	sort.Slice(data.Sources, func(i, j int) bool { return data.Sources[i].Value < data.Sources[j].Value })
+
+	f, err := os.Create(name)
+	if err != nil {
+		fatal("Error writing config:", err)
+	}
+	if err := configTemplate.Execute(f, data); err != nil {
+		fatal("Error writing config:", err)
+	}
+	if err := f.Close(); err != nil {
+		fatal("Error writing config:", err)
+	}
+	log.Printf("Wrote %s with %d sources and %d exclusions", name, len(data.Sources), len(data.Exclusions))
+}
+
+// detectGenerated finds the generated Go files under dir and proposes
+// exclusion patterns for them. It also returns the set of generated files,
+// relative to dir.
+func detectGenerated() ([]scaffoldEntry, map[string]bool, error) {
+	generated := make(map[string]bool)
+	notes := make(map[string]map[string]bool)
+	var patterns []string
+
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if base := d.Name(); name != dir && (base == "vendor" || base == "testdata" || strings.HasPrefix(base, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		generator, ok, err := generatedBy(name)
+		if err != nil || !ok {
+			return err
+		}
+		abs, _ := filepath.Abs(name)
+		rel := filepath.ToSlash(relPath(abs))
+		generated[rel] = true
+
+		pattern, note := exclusionFor(rel, generator)
+		if notes[pattern] == nil {
+			notes[pattern] = make(map[string]bool)
+			patterns = append(patterns, pattern)
+		}
+		notes[pattern][note] = true
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []scaffoldEntry
+	for _, pattern := range patterns {
+		var ns []string
+		for n := range notes[pattern] {
+			ns = append(ns, n)
+		}
+		sort.Strings(ns)
+		entries = append(entries, scaffoldEntry{Value: pattern, Note: strings.Join(ns, ", ")})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Value < entries[j].Value })
+	return entries, generated, nil
+}
+
+// generatedBy reports whether the Go file name carries the generated code
+// header before its package clause, and the generator it names.
+func generatedBy(name string) (string, bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if m := generatedHeader.FindStringSubmatch(line); m != nil {
+			generator := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(m[1]), "by"))
+			generator = strings.TrimSuffix(generator, ".")
+			return generator, true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return "", false, err
+	}
+	return "", false, nil
+}
+
+// exclusionFor proposes the exclusion pattern covering a generated file:
+// one per well-known generator, or the file itself.
+func exclusionFor(rel, generator string) (pattern, note string) {
+	base := path.Base(rel)
+	switch {
+	case base == "wire_gen.go":
+		return `wire_gen\.go$`, "generated by Wire"
+	case strings.HasSuffix(base, ".pb.go"):
+		return `\.pb\.go$`, "generated protobuf and gRPC code"
+	case strings.Contains("/"+rel, "/mocks/"):
+		return `(^|/)mocks/`, "generated mocks"
+	}
+	note = "generated code"
+	if generator != "" {
+		note = "generated by " + generator
+	}
+	return "^" + regexp.QuoteMeta(rel) + "$", note
+}
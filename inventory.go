@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+)
+
+// runInventory implements the inventory subcommand: it prints every detected
+// entrypoint of the repository as JSON, with no sources or sinks required.
+func runInventory(args []string) {
+	fs := flag.NewFlagSet("inventory", flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	targetFlags(fs)
+	detectorFlags(fs)
+	fs.Parse(args)
+	loadPatterns = fs.Args()
+
+	parseTestMode()
+	requireTarget()
+	if err := loadDetectors(); err != nil {
+		fatal("Error loading detectors:", err)
+	}
+
+	prog := loadProgram()
+	eps, err := detectEntrypoints(analyzer, prog)
+	if err != nil {
+		fatal("Error detecting entrypoints:", err)
+	}
+	assignBinaries(analyzer, prog, eps)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(eps); err != nil {
+		fatal("Error writing inventory:", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// The JUnit XML subset understood by Jenkins and GitLab.
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Name     string       `xml:"name,attr"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Skipped  int          `xml:"skipped,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+	junitSuite struct {
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Skipped  int         `xml:"skipped,attr"`
+		Cases    []junitCase `xml:"testcase"`
+	}
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		Classname string        `xml:"classname,attr"`
+		File      string        `xml:"file,attr,omitempty"`
+		Line      int           `xml:"line,attr,omitempty"`
+		Failure   *junitFailure `xml:"failure"`
+		Skipped   *junitSkipped `xml:"skipped"`
+	}
+	junitSkipped struct {
+		Message string `xml:"message,attr"`
+	}
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Body    string `xml:",chardata"`
+	}
+)
+
+// writeJUnit writes one test case per source and sink pair. With the forbid
+// polarity a case fails when a path exists; with require, when it doesn't.
+// Pairs whose search was truncated are skipped.
+func writeJUnit(w io.Writer, results []SourceResult, sinks []Frame, polarity string) error {
+	doc := junitSuites{Name: "callgraph-analysis"}
+	for _, res := range results {
+		suite := junitSuite{Name: res.Source.Name}
+		for _, sink := range sinks {
+			tc := junitCase{
+				Name:      fmt.Sprintf("%s -> %s", res.Source.Name, sink.Name),
+				Classname: res.Source.Function,
+				File:      filepath.ToSlash(relPath(res.Source.File)),
+				Line:      res.Source.Line,
+			}
+			var finding *Finding
+			for i := range res.Findings {
+				if res.Findings[i].Sink.Function == sink.Function {
+					finding = &res.Findings[i]
+					break
+				}
+			}
+			truncated := false
+			for _, t := range res.Truncated {
+				if t.Function == sink.Function {
+					truncated = true
+					break
+				}
+			}
+			switch {
+			case truncated:
+				tc.Skipped = &junitSkipped{Message: "search truncated by the per-source budget"}
+			case polarity == "forbid" && finding != nil:
+				var body strings.Builder
+				for i, frame := range finding.Path {
+					fmt.Fprintf(&body, "%d. %s (%s:%d)", i+1, frame.Name, filepath.ToSlash(relPath(frame.File)), frame.Line)
+					if frame.Call != nil {
+						fmt.Fprintf(&body, ", called at %s:%d", filepath.ToSlash(relPath(frame.Call.File)), frame.Call.Line)
+					}
+					body.WriteString("\n")
+				}
+				tc.Failure = &junitFailure{
+					Message: fmt.Sprintf("%s reaches %s", res.Source.Name, sink.Name),
+					Type:    "reachable",
+					Body:    body.String(),
+				}
+			case polarity == "require" && finding == nil:
+				tc.Failure = &junitFailure{
+					Message: fmt.Sprintf("%s does not reach %s", res.Source.Name, sink.Name),
+					Type:    "unreachable",
+				}
+			}
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -1,59 +1,387 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"go/token"
+	"io"
 	"log"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
-	"golang.org/x/tools/go/callgraph"
-	"golang.org/x/tools/go/callgraph/cha"
-	"golang.org/x/tools/go/packages"
+	"educabot.com/callgraph-analysis/callgraphanalysis"
 	"golang.org/x/tools/go/ssa"
-	"golang.org/x/tools/go/ssa/ssautil"
 )
 
 var (
-	repo         string
-	module       string
-	dir          string
-	sourcesFlag  string
-	sinksFlag    string
-	testModeFlag string
-	testMode     bool
+	repo          string
+	module        string
+	orgPrefixFlag string
+	modules       []callgraphanalysis.Module // under dir, when it has no go.mod
+	dir           string
+	dirFlag       string
+	sourcesFlag   string
+	sinksFlag     string
+	diffBase      string
+	newSince      string
+	blame         bool
+	testModeFlag  string
+	testMode      bool
+	buildTags     string
+	loadPatterns  []string
+	goos          string
+	goarch        string
+	cgo           string
+	withTests     bool
+	pushgateway   string
+	branch        string
+	format        string
+	csvDir        string
+	historyFile   string
+	sloPct        float64
+	sloWindow     int
+	scopePkg      string
+
+	useReachIndex bool
+	lang          string
+	sourceURL     string
+	configPath    string
+	junitPolarity string
+	graphPath     string
+	failOn        string
+	legacyRun     bool // run without a subcommand
+
+	perSourceTimeout time.Duration
+	maxVisits        int
+	quiet            bool
+	outputPath       string
+	useDaemon        bool
+	colorMode        string
+	allPaths         bool
+	maxPaths         int
+	shortest         bool
+	kPaths           int
+	bySink           bool
+	summary          bool
+	rankFlag         string
+	skipGenFlag      string
+	rank             []string
+	maxDepth         int
+	orgGraphs        string
+	avoidFlag        string
+	viaFlag          string
+	stringerEdges    bool
+)
+
+// runCtx is the context of the analysis, canceled by -timeout and by
+// interrupts.
+var runCtx = context.Background()
+
+// reportOut is where the text report and its notes are written, stdout unless
+// -output is given.
+var reportOut io.Writer = os.Stdout
+
+// Exit statuses of the analysis, see -fail-on.
+const (
+	exitClean    = 0 // no failing condition
+	exitFindings = 1 // a failing condition was met
+	exitError    = 2 // the analysis could not run
+	exitTimeout  = 3 // a time limit stopped the analysis
 )
 
 var srcs []string
 var sinks []string
 
+// usage is printed when the tool is run without arguments or with an unknown
+// subcommand.
+const usage = `Usage: callgraph-analysis <command> [flags] [packages]
+
+Commands:
+  analyze    find the paths from sources (entrypoints) to sinks (changed code)
+  graph      save the call graph for query-only analyze -graph runs
+  diff       compare two analyze -format=json results (diff results) or the
+             call graphs of two git refs or saved graphs (diff graph)
+  query      answer questions about the call graph: callers, callees, path
+  serve      keep the call graph warm for analyze -daemon
+  daemon     control the running server (stop, status)
+  inventory  list the detected entrypoints as JSON
+  locate     list the functions enclosing file lines, or a git diff, as JSON
+  init       write a starter analysis.yaml
+  export     write per-function features and the edge list (export features)
+  policy     replay the -history runs through a proposed policy (policy simulate)
+
+The commands loading the code take go list package patterns after the
+flags, ./... by default. Run a command with -h to see its flags.
+`
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	defer stopProfiling()
+	switch cmd := os.Args[1]; {
+	case cmd == "analyze":
+		runAnalyze(os.Args[2:])
+	case cmd == "inventory":
+		runInventory(os.Args[2:])
+	case cmd == "locate":
+		runLocate(os.Args[2:])
+	case cmd == "init":
+		runInit(os.Args[2:])
+	case cmd == "graph":
+		runGraph(os.Args[2:])
+	case cmd == "diff":
+		runDiff(os.Args[2:])
+	case cmd == "query":
+		runQuery(os.Args[2:])
+	case cmd == "serve":
+		runDaemon(append([]string{"start"}, os.Args[2:]...))
+	case cmd == "daemon":
+		runDaemon(os.Args[2:])
+	case cmd == "results-diff", cmd == "graph-diff":
+		// The commands before diff grouped them, kept so existing CI jobs
+		// keep working
+		sub := strings.TrimSuffix(cmd, "-diff")
+		log.Printf("Warning: %s is deprecated and will be removed, use \"diff %s\" with the same flags", cmd, sub)
+		runDiff(append([]string{sub}, os.Args[2:]...))
+	case cmd == "export":
+		runExport(os.Args[2:])
+	case cmd == "policy":
+		runPolicy(os.Args[2:])
+	case strings.HasPrefix(cmd, "-"):
+		// Legacy invocation with the analysis flags and no subcommand, kept
+		// so existing CI jobs keep working.
+		log.Println("Warning: running without a subcommand is deprecated and will be removed, use \"analyze\" with the same flags")
+		legacyRun = true
+		runAnalyze(os.Args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// runDiff implements the diff subcommand, dispatching to the comparison of
+// results or of call graphs.
+func runDiff(args []string) {
+	const diffUsage = "Usage: callgraph-analysis diff results|graph [flags] [args]\n"
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, diffUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "results":
+		runResultsDiff(args[1:])
+	case "graph":
+		runGraphDiff(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown diff %q\n\n%s", args[0], diffUsage)
+		os.Exit(2)
+	}
+}
+
+// runAnalyze implements the analyze subcommand.
+func runAnalyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+
 	// Define command-line flags
-	flag.StringVar(&repo, "repo", "", "Name of the repository using the tool")
-	flag.StringVar(&sourcesFlag, "sources", "", "Comma-separated filepaths where the entrypoints/cloudfns are called")
-	flag.StringVar(&sinksFlag, "sinks", "", "Comma-separated filepaths that have changes made")
-	flag.StringVar(&testModeFlag, "test", "false", "Test mode, true or false")
-	flag.Parse()
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	fs.StringVar(&sourcesFlag, "sources", "", "Comma-separated filepaths where the entrypoints/cloudfns are called")
+	fs.StringVar(&sinksFlag, "sinks", "", "Comma-separated filepaths that have changes made")
+	fs.StringVar(&newSince, "new-since", "", "Git ref or saved graph to report only the source and sink pairs it didn't connect yet, e.g. the base branch of a pull request")
+	fs.StringVar(&diffBase, "diff-base", "", "Git ref to diff the working tree against, taking the functions changed since its merge base as the sinks instead of -sinks")
+	targetFlags(fs)
+	fs.StringVar(&configPath, "config", "", "Configuration file (default: analysis.yaml in the analyzed directory, if present)")
+	graphFlags(fs)
+	detectorFlags(fs)
+	fs.BoolVar(&blame, "blame", false, "Annotate every function of the reported paths with the author and date of the last commit changing it, from git")
+	fs.BoolVar(&quiet, "quiet", false, "Print only the results, with no narration or notes; implies -format=json unless -format is given")
+	fs.StringVar(&format, "format", "text", "Output format: text, json, ndjson, sarif, csv, html, junit, markdown, github, tickets, services, mermaid or graphml")
+	fs.StringVar(&servicesPath, "services", "", "YAML file listing the services of a monorepo for -format=services (default: the services of the configuration, or one per directory of cmd)")
+	fs.StringVar(&outputPath, "output", "", "File to write the results to instead of stdout, creating its directory (not used by -format=csv)")
+	fs.BoolVar(&summary, "summary", false, "Print the text format as one tree per source showing only the calls where its paths diverge")
+	fs.StringVar(&colorMode, "color", "auto", "Render the text format as a colored tree: auto (when stdout is a terminal), always or never")
+	fs.StringVar(&csvDir, "csv-dir", ".", "Directory where -format=csv writes edges.csv and paths.csv")
+	fs.StringVar(&sourceURL, "source-url", "", "Base URL of the source tree for report links, e.g. https://github.com/org/repo/blob/main")
+	fs.StringVar(&lang, "lang", "en", "Language of the result text: en or es")
+	fs.StringVar(&junitPolarity, "junit-polarity", "forbid", "Whether -format=junit fails the pairs with a path (forbid) or without one (require)")
+	fs.StringVar(&pushgateway, "pushgateway", "", "Prometheus Pushgateway URL to push per-run gauges to")
+	fs.StringVar(&branch, "branch", "", "Branch label for pushed metrics (defaults to the checked out branch)")
+	fs.StringVar(&scopePkg, "scope", "", "Only analyze paths going through this package (pkg/... for a subtree)")
+	fs.StringVar(&avoidFlag, "avoid", "", "Regular expression over full function names that paths must not go through, e.g. /mocks\\.")
+	fs.StringVar(&viaFlag, "via", "", "Regular expression over full function names that paths must go through, e.g. /internal/authz\\.")
+	fs.BoolVar(&stringerEdges, "stringer-edges", false, "Follow the calls to String and Error methods through interfaces, which reach every implementation")
+	fs.StringVar(&graphPath, "graph", "", "Query a graph saved by the graph subcommand instead of loading the packages")
+	fs.BoolVar(&useDaemon, "daemon", false, "Get the graph from the daemon started with \"serve\", building it if none is running")
+	fs.StringVar(&socketPath, "socket", "", "Unix socket of the daemon (default: "+socketName+" in the analyzed directory)")
+	fs.StringVar(&orgGraphs, "org-graphs", "", "Comma-separated graphs saved by the graph subcommand in other repositories (globs allowed), to list the external callers of exported sinks")
+	fs.BoolVar(&useReachIndex, "reach-index", false, "Deprecated, the index of the sinks reached is always built")
+	fs.BoolVar(&allPaths, "all-paths", false, "Report every simple path between a source and a sink instead of the first one found")
+	fs.BoolVar(&shortest, "shortest", false, "Report the path with the fewest calls between a source and a sink instead of the first one found")
+	fs.IntVar(&kPaths, "k-paths", 0, "Report up to this many shortest distinct paths between a source and a sink (0: one path)")
+	fs.BoolVar(&bySink, "by-sink", false, "List the sources reaching each sink instead of the paths between them")
+	fs.StringVar(&skipGenFlag, "skip-generated", "", "Comma-separated parts of the analysis leaving out the functions of generated files: sources, sinks, paths (the calls in between) or all")
+	fs.StringVar(&rankFlag, "rank", "hops,generated,bridges,packages", "Comma-separated criteria ordering the paths of a pair with -all-paths and -k-paths, most important first (empty: search order)")
+	fs.IntVar(&maxPaths, "max-paths", 100, "Maximum number of paths reported per source and sink with -all-paths (0: no limit)")
+	fs.IntVar(&maxDepth, "max-depth", 0, "Maximum number of calls in a reported path (0: no limit)")
+	timeout := fs.Duration("timeout", 0, "Time the whole analysis may take: loading the packages fails once it is reached, and the search reports the pairs left as truncated, exiting with status 3 (0: no limit)")
+	phaseTimeoutFlags(fs)
+	fs.BoolVar(&watch, "watch", false, "Keep running, and analyze again every time a Go file, go.mod or go.sum changes, incrementally with -cache unless -incremental=false")
+	fs.DurationVar(&perSourceTimeout, "per-source-timeout", 0, "Time the search from one source may take before its remaining sinks are reported as truncated (0: no limit)")
+	fs.IntVar(&maxVisits, "max-visits", 0, "Functions the search from one source may visit before its remaining sinks are reported as truncated (0: no limit)")
+	fs.StringVar(&failOn, "fail-on", "path", "Condition exiting with status 1: path (any source reaches a sink), policy (the configured policy is violated) or none")
+	fs.StringVar(&historyFile, "history", "", "JSON lines file recording the blast radius of every run")
+	fs.Float64Var(&sloPct, "slo-percentile", 0, "Flag runs whose blast radius exceeds this percentile of the history (0 disables)")
+	fs.IntVar(&sloWindow, "slo-window", 50, "Number of recent history records the percentile is computed over")
+	fs.Parse(args)
+	startProfiling()
+	loadPatterns = fs.Args()
+	start := time.Now()
+	// Without a subcommand, the exit status is the one from before -fail-on
+	if legacyRun && !flagGiven(fs, "fail-on") {
+		failOn = "policy"
+		log.Println("Warning: without a subcommand, only a policy violation exits with status 1, as -fail-on=policy; analyze exits with status 1 when a path is found, unless given -fail-on=policy or -fail-on=none")
+	}
+	if quiet && !flagGiven(fs, "format") {
+		format = "json"
+	}
+
+	// An interrupt, -timeout or the time limit of a phase stops the
+	// analysis, which still writes the results found so far; a second
+	// interrupt exits right away
+	var stop context.CancelFunc
+	runCtx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(runCtx, stop)
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeoutCause(runCtx, *timeout, &timeoutError{"-timeout", *timeout})
+		defer cancel()
+	}
+	runCtx = limitPhases(runCtx)
+
+	parseTestMode()
+	if err := applyConfig(); err != nil {
+		fatal("Error loading config:", err)
+	}
 
 	// Validate required flags
-	if repo == "" || sourcesFlag == "" || sinksFlag == "" {
-		log.Fatal("Error: repo, sources, and sinks flags are required")
+	if sinksFlag == "" && diffBase == "" && !detectorsWanted() {
+		fatal("Error: sinks or diff-base flag is required")
+	}
+	if sinksFlag != "" && diffBase != "" {
+		fatal("Error: -sinks and -diff-base can't be used together")
+	}
+	// Without sources, the detected entrypoints are used, which needs the
+	// program and not only the graph
+	if sourcesFlag == "" && (graphPath != "" || useDaemon) {
+		fatal("Error: sources flag is required with -graph and -daemon")
+	}
+	if detectorsWanted() && (graphPath != "" || useDaemon) {
+		fatal("Error: detectors need the packages loaded, not -graph or -daemon")
 	}
 
-	testMode = testModeFlag == "true"
+	if junitPolarity != "forbid" && junitPolarity != "require" {
+		fatalf("Error: junit-polarity must be forbid or require, got %q", junitPolarity)
+	}
+	if catalogs[lang] == nil {
+		fatalf("Error: unsupported language %q", lang)
+	}
+	if colorMode != "auto" && colorMode != "always" && colorMode != "never" {
+		fatalf("Error: color must be auto, always or never, got %q", colorMode)
+	}
+	if failOn != "path" && failOn != "policy" && failOn != "none" {
+		fatalf("Error: fail-on must be path, policy or none, got %q", failOn)
+	}
+	var avoid *regexp.Regexp
+	if avoidFlag != "" {
+		var err error
+		if avoid, err = regexp.Compile(avoidFlag); err != nil {
+			fatal("Error in -avoid:", err)
+		}
+	}
+	var via *regexp.Regexp
+	if viaFlag != "" {
+		var err error
+		if via, err = regexp.Compile(viaFlag); err != nil {
+			fatal("Error in -via:", err)
+		}
+	}
+	if skipGenFlag != "" {
+		var err error
+		if skipGenerated, err = parseSkipGenerated(skipGenFlag); err != nil {
+			fatal("Error in -skip-generated:", err)
+		}
+	}
+	if rankFlag != "" {
+		var err error
+		if rank, err = parseRank(rankFlag); err != nil {
+			fatal("Error in -rank:", err)
+		}
+	}
+	if useReachIndex {
+		log.Println("Warning: -reach-index is deprecated and will be removed, the index of the sinks reached is always built")
+	}
+	if kPaths < 0 {
+		fatalf("Error: -k-paths must not be negative, got %d", kPaths)
+	}
+	if modes := btoi(shortest) + btoi(allPaths) + btoi(kPaths > 0) + btoi(bySink); modes > 1 {
+		fatal("Error: only one of -shortest, -all-paths, -k-paths and -by-sink can be used")
+	}
+	if bySink && format != "text" && format != "json" && format != "markdown" {
+		fatalf("Error: -by-sink works with the text, json and markdown formats, got %q", format)
+	}
+	if summary && (format != "text" || bySink) {
+		fatal("Error: -summary works with the text format of paths only")
+	}
+	var services []Service
+	if format == "services" {
+		var err error
+		if services, err = loadServices(); err != nil {
+			fatal("Error loading services:", err)
+		}
+	}
 
-	// Set module and dir based on repo
-	module = "educabot.com/" + repo
-	if testMode {
-		dir = "../" + repo
-	} else {
-		dir = "./"
+	if watch && graphPath != "" {
+		fatal("Error: -watch needs the code, not a saved -graph")
+	}
+
+	requireTarget()
+	if watch {
+		watchAnalysis(args, flagGiven(fs, "incremental"))
+	}
+	if err := loadDetectors(); err != nil {
+		fatal("Error loading detectors:", err)
 	}
 
 	// Split comma-separated paths into slices
-	srcs = strings.Split(sourcesFlag, ",")
-	sinks = strings.Split(sinksFlag, ",")
+	if sourcesFlag != "" {
+		srcs = strings.Split(sourcesFlag, ",")
+	}
+	var hunks map[string][]lineRange
+	if diffBase != "" {
+		var err error
+		if hunks, err = diffHunks(diffBase); err != nil {
+			fatal("Error diffing against the base:", err)
+		}
+	} else if sinksFlag == "worktree" {
+		files, err := worktreeSinks()
+		if err != nil {
+			fatal("Error reading the working tree:", err)
+		}
+		if len(files) == 0 {
+			log.Println("No changed Go files in the working tree")
+		}
+		sinks = files
+	} else if sinksFlag != "" {
+		sinks = strings.Split(sinksFlag, ",")
+	}
 
 	// Trim whitespace from each path
 	for i := range srcs {
@@ -65,186 +393,519 @@ func main() {
 		sinks[i] = filepath.Join(dir, sink)
 	}
 
-	cfg := &packages.Config{
-		Mode: packages.LoadAllSyntax,
-		Dir:  dir,
+	if orgGraphs != "" {
+		var names []string
+		for _, pattern := range strings.Split(orgGraphs, ",") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				fatal("Error in -org-graphs:", err)
+			}
+			names = append(names, matches...)
+		}
+		var err error
+		if externalCallers, err = loadExternalCallers(names); err != nil {
+			fatal("Error loading org graphs:", err)
+		}
 	}
-	initial, err := packages.Load(cfg, dir)
-	if err != nil {
-		log.Fatal("Error loading packages:", err)
-	}
-	if packages.PrintErrors(initial) > 0 {
-		log.Fatal("Error loading packages:", packages.PrintErrors(initial))
-	}
-
-	// Create and build SSA-form program representation.
-	mode := ssa.InstantiateGenerics // instantiate generics by default for soundness
-	prog, _ := ssautil.AllPackages(initial, mode)
-	prog.Build()
-	// Generate the call graph
-	cg := cha.CallGraph(prog)
-	cg.DeleteSyntheticNodes()
-
-	toRemove := make([]*callgraph.Node, 0)
-	for _, node := range cg.Nodes {
-		if node.Func != nil {
-			pos := prog.Fset.Position(node.Func.Pos())
-			filename := pos.Filename
-			if strings.Contains(filename, "wire_gen") {
-				toRemove = append(toRemove, node)
+
+	graph := storedGraph()
+	var entrypoints, detected map[string]bool
+	var graphKey string
+	if graph == nil {
+		graphKey = graphCacheKey()
+		// The entrypoints and the detectors need the program, which the
+		// cached graph doesn't spare
+		if sourcesFlag != "" && !detectorsWanted() {
+			if graph = cachedGraph(graphKey); graph == nil && incremental {
+				graph = newGraph()
+				cacheGraph(graphKey, graph)
+			}
+		}
+	}
+	var prog *ssa.Program
+	if graph == nil {
+		prog = loadProgram()
+		graph = buildGraph(prog)
+		cacheGraph(graphKey, graph)
+		var err error
+		if detected, err = detectedSinks(prog); err != nil {
+			fatal("Error detecting sinks:", err)
+		}
+		if sourcesFlag == "" {
+			eps, err := detectEntrypoints(analyzer, prog)
+			if err != nil {
+				fatal("Error detecting entrypoints:", err)
 			}
-			if !strings.Contains(node.Func.String(), module) {
-				toRemove = append(toRemove, node)
+			entrypoints = make(map[string]bool)
+			for _, ep := range eps {
+				entrypoints[ep.Function] = true
 			}
+			log.Printf("No sources given, using the %d detected entrypoints", len(entrypoints))
 		}
 	}
-	for _, node := range toRemove {
-		cg.DeleteNode(node)
+	var changed map[declKey]bool
+	if diffBase != "" {
+		// The changed lines are those of the files as loaded when the
+		// program is, and of the files on disk otherwise
+		var decls []Declaration
+		var err error
+		if prog != nil {
+			decls, err = programDecls(prog, hunks)
+		} else {
+			decls, err = enclosingDecls(hunks)
+		}
+		if err != nil {
+			fatal("Error reading the changed files:", err)
+		}
+		changed = make(map[declKey]bool, len(decls))
+		for _, d := range decls {
+			changed[d.key()] = true
+		}
+		log.Printf("Changed since %s: %d functions in %d Go files", diffBase, len(decls), len(hunks))
 	}
 
-	// Create maps for source and sink functions
-	sourceFuncs := make(map[*ssa.Function]bool)
-	sinkFuncs := make(map[*ssa.Function]bool)
-	fset := prog.Fset
-	for _, node := range cg.Nodes {
-		if node.Func != nil {
-			pos := fset.Position(node.Func.Pos())
-			filename := pos.Filename
+	// Classify the functions as sources and sinks, looking the files of the
+	// sources and sinks up in an index of the functions by file
+	byFile := make(map[string][]*Func)
+	sourceFuncs := make(map[*Func]bool)
+	sinkFuncs := make(map[*Func]bool)
+	for _, fn := range graph.Funcs {
+		byFile[fn.File] = append(byFile[fn.File], fn)
+		if entrypoints[fn.Function] {
+			sourceFuncs[fn] = true
+		}
+		// Functions changed since -diff-base or found by a detector
+		if changed[funcKey(fn)] || detected[fn.Function] {
+			sinkFuncs[fn] = true
+		}
+	}
+	for _, src := range srcs {
+		s, _ := filepath.Abs(src)
+		for _, fn := range byFile[s] {
+			sourceFuncs[fn] = true
+		}
+	}
+	for _, sink := range sinks {
+		s, _ := filepath.Abs(sink)
+		for _, fn := range byFile[s] {
+			sinkFuncs[fn] = true
+		}
+	}
 
-			// Check if function is in a source file
-			for _, src := range srcs {
-				s, _ := filepath.Abs(src)
-				if s == filename {
-					sourceFuncs[node.Func] = true
-					break
-				}
-			}
+	// Build reachability graph (adjacency list)
+	reporter().report("Pruning the graph", "", 0, 0)
+	g := graph.Adjacency()
+	callSites = graph.CallSites()
+
+	// Leave out the functions of generated files, like mocks
+	if len(skipGenerated) > 0 {
+		g = dropGenerated(graph, g, sourceFuncs, sinkFuncs)
+	}
 
-			// Check if function is in a sink file
-			for _, sink := range sinks {
-				s, _ := filepath.Abs(sink)
-				if s == filename {
-					sinkFuncs[node.Func] = true
-					break
-				}
+	// Prune the graph to what reaches to or from the -scope package, which
+	// the paths are then required to go through
+	if scopePkg != "" {
+		keep := callgraphanalysis.NewScopeIndex(g).Scope(scopePattern(scopePkg))
+		g = callgraphanalysis.Restrict(g, keep)
+		for fn := range sourceFuncs {
+			if !keep[fn] {
+				delete(sourceFuncs, fn)
+			}
+		}
+		for fn := range sinkFuncs {
+			if !keep[fn] {
+				delete(sinkFuncs, fn)
 			}
 		}
 	}
 
-	// Build reachability graph (adjacency list)
-	g := make(map[*ssa.Function]map[*ssa.Function]bool)
-	err = callgraph.GraphVisitEdges(cg, func(edge *callgraph.Edge) error {
-		caller := edge.Caller.Func
-		callee := edge.Callee.Func
+	// Drop the functions paths must not go through
+	if avoid != nil {
+		g = callgraphanalysis.Restrict(g, callgraphanalysis.Avoiding(graph, avoid, sourceFuncs, sinkFuncs))
+	}
 
-		// check that both caller and callee are in module
-		if caller == nil || callee == nil {
-			return nil
+	// Search the paths going through -scope and -via on graphs tracking
+	// whether they did
+	searchSources, searchSinks := sourceFuncs, sinkFuncs
+	if scopePkg != "" {
+		g, searchSources, searchSinks = callgraphanalysis.ThroughScope(g, scopePattern(scopePkg), searchSources, searchSinks)
+	}
+	if via != nil {
+		g, searchSources, searchSinks = callgraphanalysis.ThroughVia(g, via, searchSources, searchSinks)
+	}
+
+	// Leave out the calls through fmt.Stringer and error, which CHA connects
+	// to every String and Error method, unless asked for. They are removed
+	// from g rather than from a copy of it, which would double the memory of
+	// the graph searched
+	var stringer map[[2]string]bool
+	var removed map[*Func]map[*Func]bool
+	if !stringerEdges {
+		if stringer = graph.StringerPairs(); len(stringer) > 0 {
+			removed = callgraphanalysis.RemovePairs(g, stringer)
 		}
-		if !strings.Contains(caller.String(), module) || !strings.Contains(callee.String(), module) {
-			return nil
+	}
+
+	// The reach index of the sinks is cached with the graph when only the
+	// Stringer edges are dropped from it
+	if graphKey != "" && len(skipGenerated) == 0 && scopePkg == "" && avoid == nil && via == nil {
+		searchKey = callgraphanalysis.CacheKey(graphKey, "stringer-edges="+strconv.FormatBool(stringerEdges))
+	}
+
+	var err error
+	out := io.Writer(os.Stdout)
+	var outFile *os.File
+	if outputPath != "" {
+		if outFile, err = createOutput(outputPath); err != nil {
+			fatal("Error creating output:", err)
 		}
-		if g[caller] == nil {
-			g[caller] = make(map[*ssa.Function]bool)
+		out = outFile
+		reportOut = outFile
+	}
+
+	// With -new-since, only the pairs the base didn't connect are reported
+	var base *baseReach
+	if newSince != "" {
+		log.Printf("Building the call graph of %s to report only the pairs it didn't connect", newSince)
+		base = newBaseReach(graphAt(newSince))
+	}
+
+	var blames *blamer
+	if blame {
+		if blames, err = newBlamer(); err != nil {
+			fatal("Error: -blame:", err)
 		}
-		g[caller][callee] = true
-		return nil
-	})
-	if err != nil {
-		log.Fatal("Error visiting edges:", err)
 	}
 
-	// Add edges between functions and their anonymous versions
-	for _, node := range cg.Nodes {
-		if node.Func != nil {
-			funcName := node.Func.String()
-			if !strings.Contains(funcName, module) {
-				continue
+	// With -format=ndjson, findings are written as soon as they are found
+	var found func(Frame, Finding)
+	var streamErr error
+	if format == "ndjson" {
+		enc := json.NewEncoder(out)
+		found = func(source Frame, f Finding) {
+			if base != nil && base.reaches(source.Function, f.Sink.Function) {
+				return
 			}
-			// Check if this is a named function that might have anonymous functions
-			if !strings.Contains(funcName, "$") {
-				// Look for anonymous functions derived from this one
-				baseFuncName := funcName
-				for _, otherNode := range cg.Nodes {
-					if otherNode.Func != nil {
-						otherFuncName := otherNode.Func.String()
-						if !strings.Contains(otherFuncName, module) {
-							continue
-						}
-						// Check if the other function is an anonymous function of this one
-						if strings.HasPrefix(otherFuncName, baseFuncName+"$") {
-							// Add edge from the named function to its anonymous function
-							if g[node.Func] == nil {
-								g[node.Func] = make(map[*ssa.Function]bool)
-							}
-							g[node.Func][otherNode.Func] = true
-
-							// For debugging
-							//fmt.Printf("Added edge: %s -> %s\n",
-							//	node.Func.String(), otherNode.Func.String())
-						}
-					}
-				}
+			if blames != nil {
+				blames.annotate(f.Path)
+			}
+			if err := enc.Encode(ndjsonFinding{Source: source, Finding: f}); err != nil && streamErr == nil {
+				streamErr = err
 			}
 		}
 	}
+	var results []SourceResult
+	var sinkResults []SinkResult
+	var searchErr error
+	if bySink {
+		sinkResults = reachingSources(searchSources, searchSinks, g)
+		if base != nil {
+			sinkResults = base.newSources(sinkResults)
+		}
+		results = bySource(sinkResults, searchSources)
+	} else {
+		results, searchErr = findPaths(searchSources, searchSinks, g, found)
+		if base != nil {
+			results = base.newFindings(results)
+		}
+		if blames != nil && format != "ndjson" {
+			blames.annotateResults(results)
+		}
+	}
 
-	// Find paths from sources to sinks
-	fmt.Println("Analyzing paths from sources to sinks:")
+	endPhases()
+	reporter().finish()
 
-	// For each source function
-	for sourceFunc := range sourceFuncs {
-		sourcePos := fset.Position(sourceFunc.Pos())
-		fmt.Printf("\nSource: %s (%s:%d)\n", sourceFunc.Name(), sourcePos.Filename, sourcePos.Line)
+	var sinkFrames []Frame
+	for _, fn := range sortFuncs(sinkFuncs) {
+		sinkFrames = append(sinkFrames, newFrame(fn))
+	}
 
-		// Find sink reachability
-		reachedSinks := make(map[*ssa.Function]bool)
-		visited := make(map[*ssa.Function]bool)
+	switch format {
+	case "text":
+		if bySink {
+			printBySink(out, sinkResults)
+		} else if color := useColor(); color || summary {
+			printTree(out, results, color)
+		} else {
+			printText(out, results)
+		}
+	case "json":
+		if bySink {
+			err = writeBySinkJSON(out, sinkResults)
+		} else {
+			err = writeJSON(out, results)
+		}
+	case "ndjson":
+		err = streamErr
+	case "sarif":
+		err = writeSARIF(out, results)
+	case "csv":
+		err = writeCSV(csvDir, graph.Edges, results)
+	case "html":
+		err = writeHTML(out, results, sinkFrames)
+	case "junit":
+		err = writeJUnit(out, results, sinkFrames, junitPolarity)
+	case "markdown":
+		if bySink {
+			err = writeBySinkMarkdown(out, sinkResults)
+		} else {
+			err = writeMarkdown(out, results)
+		}
+	case "github":
+		err = writeGitHub(out, results, config.Policy)
+	case "tickets":
+		err = writeTickets(out, results, config.Categories)
+	case "services":
+		err = writeServices(out, results, services)
+	case "mermaid":
+		err = writeMermaid(out, results)
+	case "graphml":
+		err = writeGraphML(out, graph, sourceFuncs, sinkFuncs)
+	default:
+		fatalf("Error: unknown format %q", format)
+	}
+	if err != nil {
+		fatal("Error writing results:", err)
+	}
 
-		// Use DFS to find one path to each reachable sink
-		for sinkFunc := range sinkFuncs {
-			if visited[sinkFunc] {
-				continue
-			}
+	if len(stringer) > 0 {
+		if n := callgraphanalysis.SuppressedByRemoval(g, removed, searchSources, searchSinks); n > 0 {
+			fmt.Fprintf(reportWriter(), msg("stringerDropped"), n)
+		}
+	}
 
-			path := findPath(fset, sourceFunc, sinkFunc, g, make(map[*ssa.Function]bool))
-			if path != nil {
-				reachedSinks[sinkFunc] = true
-
-				// Print the path
-				sinkPos := fset.Position(sinkFunc.Pos())
-				fmt.Printf("  Sink reached: %s (%s:%d)\n", sinkFunc.Name(), sinkPos.Filename, sinkPos.Line)
-				fmt.Println("  Path:")
-				for i, func_ := range path {
-					pos := fset.Position(func_.Pos())
-					fmt.Printf("    %d. %s (%s:%d)\n", i+1, func_.Name(), pos.Filename, pos.Line)
-				}
-			}
+	affected, findings := summarize(results)
+	if historyFile != "" {
+		if err := checkHistory(results); err != nil {
+			log.Println("Error using history:", err)
 		}
+	}
+	if pushgateway != "" {
+		err := pushMetrics(runMetrics{
+			AffectedEntrypoints: affected,
+			Findings:            findings,
+			Duration:            time.Since(start),
+		})
+		if err != nil {
+			log.Println("Error pushing metrics:", err)
+		}
+	}
+
+	violated, err := checkPolicy(reportWriter(), config.Policy, results)
+	if err != nil {
+		fatal("Error checking policy:", err)
+	}
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			fatal("Error writing results:", err)
+		}
+	}
+	if searchErr != nil {
+		failed("Error: the search was stopped, the pairs left are reported as truncated:", searchErr)
+	}
+	switch {
+	case failOn == "path" && affected > 0, failOn == "policy" && violated:
+		exit(exitFindings)
+	}
+	exit(exitClean)
+}
 
-		if len(reachedSinks) == 0 {
-			fmt.Println("  No sinks reached from this source.")
+// storedGraph returns the graph saved at -graph or kept by the daemon with
+// -daemon, or nil when it has to be built.
+func storedGraph() *Graph {
+	switch {
+	case graphPath != "":
+		graph, err := loadGraph(graphPath)
+		if err != nil {
+			fatal("Error loading graph:", err)
 		}
+		return graph
+	case useDaemon:
+		if socketPath == "" {
+			socketPath = filepath.Join(dir, socketName)
+		}
+		graph, err := fetchGraph()
+		if err != nil {
+			log.Println("Warning: daemon unavailable, building the graph:", err)
+		}
+		return graph
 	}
+	return nil
 }
 
-// findPath uses DFS to find a path from src to dest
-func findPath(fset *token.FileSet, src, dest *ssa.Function, graph map[*ssa.Function]map[*ssa.Function]bool, visited map[*ssa.Function]bool) []*ssa.Function {
-	posSRC := fset.Position(src.Pos())
-	posDEST := fset.Position(dest.Pos())
-	if posSRC.Filename == posDEST.Filename {
-		return []*ssa.Function{src}
+// targetFlags registers the flags selecting the directory to analyze.
+func targetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&dirFlag, "dir", "", "Directory of the repository to analyze (default: the current directory)")
+	fs.StringVar(&orgPrefixFlag, "org-prefix", "", "Import path prefix of the modules of the organization, completing a bare -repo name, e.g. github.com/acme/ (default: the first element of the module path of go.mod)")
+	fs.StringVar(&testModeFlag, "test", "false", "Deprecated, use -dir=../REPO: analyze the repository in the parent directory")
+	fs.StringVar(&buildTags, "tags", "", "Comma-separated build tags to load the packages with, as for go build")
+	fs.StringVar(&goos, "goos", "", "GOOS to load the packages for, selecting the files built for it (default: the one of the go command)")
+	fs.StringVar(&goarch, "goarch", "", "GOARCH to load the packages for (default: the one of the go command)")
+	fs.StringVar(&cgo, "cgo", "", "CGO_ENABLED to load the packages with, 0 or 1 (default: the one of the go command)")
+	fs.BoolVar(&withTests, "tests", false, "Load the _test.go files too, keeping their functions in the graph as sources, sinks and calls in between")
+}
+
+// parseTestMode reads the deprecated -test flag.
+func parseTestMode() {
+	testMode = testModeFlag == "true"
+	if testMode && dirFlag == "" {
+		log.Println("Warning: -test is deprecated and will be removed, use -dir=../REPO")
 	}
-	visited[src] = true
+}
 
-	neighbourhood := graph[src]
-	for neighbor := range neighbourhood {
-		if !visited[neighbor] {
-			if path := findPath(fset, neighbor, dest, graph, visited); path != nil {
-				return append([]*ssa.Function{src}, path...)
-			}
+// setDir sets the directory to analyze from -dir and testMode.
+func setDir() {
+	switch {
+	case dirFlag != "":
+		dir = dirFlag
+	case testMode:
+		dir = "../" + repo
+	default:
+		dir = "./"
+	}
+}
+
+// setTarget sets the directory to analyze and the module path, read from its
+// go.mod, and configures the analyzer for them. Without a go.mod, the
+// modules under the directory are analyzed together, and the module path is
+// the longest one their paths start with. A -repo overrides the module path:
+// a bare name is a module of the organization, under orgPrefix, anything
+// with a dot or slash the module path itself. Without -repo, the repository
+// is named after the module path, without orgPrefix.
+func setTarget() {
+	setDir()
+	var err error
+	if modules, err = callgraphanalysis.FindModules(dir); err != nil {
+		log.Println("Warning: looking for Go modules:", err)
+	}
+	found := callgraphanalysis.ModulePath(dir)
+	if len(modules) > 0 {
+		found = commonModulePath(modules)
+	}
+	orgPrefix = orgPrefixFlag
+	if orgPrefix == "" {
+		if first, _, ok := strings.Cut(found, "/"); ok {
+			orgPrefix = first
+		}
+	}
+	if orgPrefix != "" && !strings.HasSuffix(orgPrefix, "/") {
+		orgPrefix += "/"
+	}
+	switch {
+	case strings.ContainsAny(repo, "./"):
+		module = repo
+	case repo != "":
+		if orgPrefix == "" {
+			fatalf("Error: no go.mod in %s to complete the repo %q with the prefix of its module path, give the org-prefix flag or the full module path", dir, repo)
+		}
+		module = orgPrefix + repo
+	default:
+		module = found
+		repo = strings.TrimPrefix(module, orgPrefix)
+	}
+	analyzer = newAnalyzer()
+}
+
+// requireTarget calls setTarget and exits if the module path is still unknown.
+func requireTarget() {
+	setTarget()
+	if module == "" {
+		fatalf("Error: no go.mod in %s nor Go module under it, the repo flag is required", dir)
+	}
+}
+
+// commonModulePath returns the longest path, by elements, the paths of
+// modules start with, or the paths joined by commas when they share none.
+func commonModulePath(modules []callgraphanalysis.Module) string {
+	prefix := strings.Split(modules[0].Path, "/")
+	for _, m := range modules[1:] {
+		elems := strings.Split(m.Path, "/")
+		n := 0
+		for n < len(prefix) && n < len(elems) && prefix[n] == elems[n] {
+			n++
 		}
+		prefix = prefix[:n]
 	}
+	if len(prefix) == 0 {
+		var paths []string
+		for _, m := range modules {
+			paths = append(paths, m.Path)
+		}
+		return strings.Join(paths, ",")
+	}
+	return strings.Join(prefix, "/")
+}
 
-	return nil
+// btoi returns 1 for true and 0 for false.
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// createOutput creates the -output file and its parent directories.
+func createOutput(name string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(name)
+}
+
+// flagGiven reports whether the flag name was set on the command line.
+func flagGiven(fs *flag.FlagSet, name string) bool {
+	given := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// fatal logs v and exits with exitError, so errors are told apart from
+// findings by the exit status.
+func fatal(v ...any) {
+	log.Println(v...)
+	exit(exitError)
+}
+
+// fatalf is like fatal with a format.
+func fatalf(format string, v ...any) {
+	log.Printf(format, v...)
+	exit(exitError)
+}
+
+// reportWriter returns where notes about the results go: along with them in
+// the text format, and to stderr for the formats meant for other tools. With
+// -quiet they are dropped.
+func reportWriter() io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	if format == "text" {
+		return reportOut
+	}
+	return os.Stderr
+}
+
+// relPath returns filename relative to the analyzed directory, or filename
+// unchanged when it lies outside of it.
+func relPath(filename string) string {
+	return relPathTo(dir, filename)
+}
+
+// relPathTo returns filename relative to the directory d, or unchanged when
+// it lies outside of it.
+func relPathTo(d, filename string) string {
+	base, err := filepath.Abs(d)
+	if err != nil {
+		return filename
+	}
+	rel, err := filepath.Rel(base, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filename
+	}
+	return rel
 }
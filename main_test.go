@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// runMainEnv, when set, makes the test binary run main with its arguments
+// instead of the tests, so every test runs the tool in a process of its
+// own, with fresh flags and exit statuses to check.
+const runMainEnv = "CALLGRAPH_ANALYSIS_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(exitClean)
+	}
+	os.Exit(m.Run())
+}
+
+// fixture writes files, by path relative to a new temporary directory, and
+// returns the directory.
+func fixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// run runs the tool with args, caching the graphs in a temporary directory,
+// and returns its stdout, its stderr and its exit status.
+func run(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1", "XDG_CACHE_HOME="+t.TempDir())
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// analyzeJSON runs analyze -format=json with args, failing the test unless
+// it exits with want, and returns its results.
+func analyzeJSON(t *testing.T, want int, args ...string) []SourceResult {
+	t.Helper()
+	stdout, stderr, code := run(t, append([]string{"analyze", "-format=json"}, args...)...)
+	if code != want {
+		t.Fatalf("analyze exited with %d, want %d; stderr:\n%s", code, want, stderr)
+	}
+	var results []SourceResult
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("decoding the results: %v\n%s", err, stdout)
+	}
+	return results
+}
+
+// pathNames returns the names of the functions of path.
+func pathNames(path []Frame) []string {
+	var names []string
+	for _, f := range path {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// layered is a module whose handler reaches the store through a use case,
+// and whose report job reaches nothing.
+var layered = map[string]string{
+	"go.mod": "module example.com/layered\n\ngo 1.21\n",
+	"api/handler.go": `package api
+
+import "example.com/layered/usecases"
+
+func Handle() { usecases.Save() }
+`,
+	"jobs/report.go": `package jobs
+
+func Report() {}
+`,
+	"usecases/save.go": `package usecases
+
+import "example.com/layered/store"
+
+func Save() { store.Put() }
+`,
+	"store/store.go": `package store
+
+func Put() {}
+`,
+}
+
+func TestAnalyzeFindsPath(t *testing.T) {
+	dir := fixture(t, layered)
+	results := analyzeJSON(t, exitFindings, "-dir="+dir, "-sources=api/handler.go", "-sinks=store/store.go")
+	if len(results) != 1 || len(results[0].Findings) != 1 {
+		t.Fatalf("got %+v, want one finding", results)
+	}
+	got := pathNames(results[0].Findings[0].Path)
+	want := []string{"Handle", "Save", "Put"}
+	if !slices.Equal(got, want) {
+		t.Errorf("path %v, want %v", got, want)
+	}
+	if file, want := results[0].Source.File, filepath.Join(dir, "api/handler.go"); file != want {
+		t.Errorf("source file %q, want %q", file, want)
+	}
+}
+
+func TestAnalyzeNoPath(t *testing.T) {
+	dir := fixture(t, layered)
+	results := analyzeJSON(t, exitClean, "-dir="+dir, "-sources=jobs/report.go", "-sinks=store/store.go")
+	for _, res := range results {
+		if len(res.Findings) > 0 {
+			t.Errorf("%s reaches %s, want no path", res.Source.Name, res.Findings[0].Sink.Name)
+		}
+	}
+}
+
+func TestAnalyzeModulesUnderDir(t *testing.T) {
+	// No go.mod at the root, as in repositories mixing languages
+	dir := fixture(t, map[string]string{
+		"README.md":  "Services in Go and a frontend in TypeScript.\n",
+		"api/go.mod": "module example.com/mono/api\n\ngo 1.21\n",
+		"api/handler.go": `package api
+
+import "example.com/mono/api/store"
+
+func Handle() { store.Put() }
+`,
+		"api/store/store.go": `package store
+
+func Put() {}
+`,
+		"web/go.mod": "module example.com/mono/web\n\ngo 1.21\n",
+		"web/web.go": `package web
+
+func Serve() {}
+`,
+	})
+	results := analyzeJSON(t, exitFindings, "-dir="+dir, "-sources=api/handler.go,web/web.go", "-sinks=api/store/store.go")
+	var paths [][]string
+	for _, res := range results {
+		for _, f := range res.Findings {
+			paths = append(paths, pathNames(f.Path))
+		}
+	}
+	if want := [][]string{{"Handle", "Put"}}; !slices.EqualFunc(paths, want, slices.Equal) {
+		t.Errorf("paths %v, want %v", paths, want)
+	}
+}
+
+func TestAnalyzeOrgPrefix(t *testing.T) {
+	dir := fixture(t, map[string]string{
+		"go.mod": "module github.com/acme/svc\n\ngo 1.21\n",
+		"api/handler.go": `package api
+
+import "github.com/acme/svc/store"
+
+func Handle() { store.Put() }
+`,
+		"store/store.go": `package store
+
+func Put() {}
+`,
+	})
+	// A bare -repo name is a module under the prefix of the organization
+	results := analyzeJSON(t, exitFindings, "-dir="+dir, "-repo=svc", "-org-prefix=github.com/acme", "-sources=api/handler.go", "-sinks=store/store.go")
+	if len(results) != 1 || len(results[0].Findings) != 1 {
+		t.Fatalf("got %+v, want one finding", results)
+	}
+	if got, want := results[0].Source.Function, "github.com/acme/svc/api.Handle"; got != want {
+		t.Errorf("source %s, want %s", got, want)
+	}
+}
+
+func TestLegacyInvocationExitStatus(t *testing.T) {
+	dir := fixture(t, layered)
+	args := []string{"-dir=" + dir, "-sources=api/handler.go", "-sinks=store/store.go", "-format=json"}
+	// Paths don't fail the runs without a subcommand, as before -fail-on
+	if _, stderr, code := run(t, args...); code != exitClean {
+		t.Errorf("exited with %d, want %d; stderr:\n%s", code, exitClean, stderr)
+	}
+	if _, stderr, code := run(t, append(args, "-fail-on=path")...); code != exitFindings {
+		t.Errorf("with -fail-on=path, exited with %d, want %d; stderr:\n%s", code, exitFindings, stderr)
+	}
+}
+
+func TestAnalyzeDiffBase(t *testing.T) {
+	files := maps.Clone(layered)
+	// A variant left out by the build constraints, changed with Put
+	files["store/put_ignored.go"] = "//go:build ignore\n\npackage store\n\nfunc Put() {}\n"
+	dir := fixture(t, files)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	for _, name := range []string{"store/store.go", "store/put_ignored.go"} {
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = bytes.Replace(content, []byte("func Put() {}"), []byte("func Put() {\n\tprintln()\n}"), 1)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr, code := run(t, "analyze", "-format=json", "-dir="+dir, "-sources=api/handler.go", "-diff-base=HEAD")
+	if code != exitFindings {
+		t.Fatalf("analyze exited with %d, want %d; stderr:\n%s", code, exitFindings, stderr)
+	}
+	if want := "Changed since HEAD: 1 functions in 2 Go files"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr doesn't log %q:\n%s", want, stderr)
+	}
+	var results []SourceResult
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("decoding the results: %v\n%s", err, stdout)
+	}
+	if len(results) != 1 || len(results[0].Findings) != 1 {
+		t.Fatalf("got %+v, want one finding", results)
+	}
+	if got, want := pathNames(results[0].Findings[0].Path), []string{"Handle", "Save", "Put"}; !slices.Equal(got, want) {
+		t.Errorf("path %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzeNegativeKPaths(t *testing.T) {
+	dir := fixture(t, layered)
+	if _, stderr, code := run(t, "analyze", "-dir="+dir, "-sources=api/handler.go", "-sinks=store/store.go", "-k-paths=-1"); code != exitError || !strings.Contains(stderr, "-k-paths must not be negative") {
+		t.Errorf("exited with %d, want %d for a negative -k-paths; stderr:\n%s", code, exitError, stderr)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// writeMarkdown writes a compact report meant to be posted as a pull request
+// comment: a summary table of the sources reaching sinks, followed by the
+// paths in collapsible sections.
+func writeMarkdown(w io.Writer, results []SourceResult) error {
+	bw := bufio.NewWriter(w)
+	affected, findings := summarize(results)
+	fmt.Fprintf(bw, "### %s\n\n", msg("mdTitle"))
+	fmt.Fprintf(bw, msg("mdSummary")+"\n", affected, len(results), findings)
+	if affected == 0 {
+		return bw.Flush()
+	}
+
+	fmt.Fprintf(bw, "\n| %s | %s |\n|---|---|\n", msg("mdSource"), msg("mdSinks"))
+	for _, res := range results {
+		if len(res.Findings) == 0 {
+			continue
+		}
+		var names []string
+		for _, finding := range res.Findings {
+			names = append(names, "`"+mdEscape(finding.Sink.Name)+"`")
+		}
+		fmt.Fprintf(bw, "| %s | %s |\n", mdFrame(res.Source), strings.Join(names, ", "))
+	}
+
+	fmt.Fprintln(bw)
+	for _, res := range results {
+		for _, finding := range res.Findings {
+			stats := fmt.Sprintf(msg("hops"), len(finding.Path)-1)
+			if finding.PathCount > 1 {
+				stats += ", " + fmt.Sprintf(msg("paths"), formatPathCount(finding.PathCount))
+			}
+			fmt.Fprintf(bw, "<details><summary><code>%s</code> → <code>%s</code> (%s)</summary>\n\n",
+				htmlEscape(res.Source.Name), htmlEscape(finding.Sink.Name), stats)
+			for i, frame := range finding.Path {
+				fmt.Fprintf(bw, "%d. %s", i+1, mdFrame(frame))
+				if frame.Call != nil {
+					fmt.Fprintf(bw, msg("calledAt"), filepath.ToSlash(relPath(frame.Call.File)), frame.Call.Line)
+				}
+				fmt.Fprint(bw, blameSuffix(frame))
+				fmt.Fprintln(bw)
+			}
+			fmt.Fprint(bw, "\n</details>\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// mdFrame renders a frame as its name followed by its position, linked when
+// -source-url is set.
+func mdFrame(f Frame) string {
+	file, line := f.edited()
+	pos := fmt.Sprintf("%s:%d", filepath.ToSlash(relPath(file)), line)
+	if sourceURL != "" {
+		pos = "[" + pos + "](" + sourceLink(f) + ")"
+	}
+	return "`" + mdEscape(f.Name) + "` " + pos
+}
+
+// mdEscape keeps table cells intact.
+func mdEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// htmlEscape escapes text placed inside inline HTML.
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// writeMermaid writes one Mermaid flowchart per source that reaches a sink,
+// wrapped in a fenced block ready to paste into Markdown.
+func writeMermaid(w io.Writer, results []SourceResult) error {
+	bw := bufio.NewWriter(w)
+	first := true
+	for _, res := range results {
+		if len(res.Findings) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(bw)
+		}
+		first = false
+
+		ids := make(map[string]string)
+		sinks := make(map[string]bool)
+		var nodes []Frame
+		node := func(f Frame) string {
+			if id, ok := ids[f.Function]; ok {
+				return id
+			}
+			id := fmt.Sprintf("n%d", len(ids))
+			ids[f.Function] = id
+			nodes = append(nodes, f)
+			return id
+		}
+		node(res.Source)
+
+		var links []string
+		seen := make(map[string]bool)
+		for _, finding := range res.Findings {
+			for i := 1; i < len(finding.Path); i++ {
+				link := node(finding.Path[i-1]) + " --> " + node(finding.Path[i])
+				if !seen[link] {
+					seen[link] = true
+					links = append(links, link)
+				}
+			}
+			sinks[finding.Path[len(finding.Path)-1].Function] = true
+		}
+
+		fmt.Fprintf(bw, "**%s** (%s:%d)\n\n", res.Source.Name, filepath.ToSlash(relPath(res.Source.File)), res.Source.Line)
+		fmt.Fprintln(bw, "```mermaid")
+		fmt.Fprintln(bw, "flowchart LR")
+		for _, f := range nodes {
+			fmt.Fprintf(bw, "  %s[\"%s<br/>%s:%d\"]\n", ids[f.Function], mermaidEscape(f.Name),
+				mermaidEscape(filepath.ToSlash(relPath(f.File))), f.Line)
+		}
+		for _, link := range links {
+			fmt.Fprintln(bw, "  "+link)
+		}
+		fmt.Fprintln(bw, "  classDef source fill:#d0e8ff,stroke:#1f6feb")
+		fmt.Fprintln(bw, "  classDef sink fill:#ffd8d3,stroke:#cf222e")
+		fmt.Fprintf(bw, "  class %s source\n", ids[res.Source.Function])
+		for _, f := range nodes {
+			if sinks[f.Function] && f.Function != res.Source.Function {
+				fmt.Fprintf(bw, "  class %s sink\n", ids[f.Function])
+			}
+		}
+		fmt.Fprintln(bw, "```")
+	}
+	return bw.Flush()
+}
+
+// mermaidEscape makes s safe inside a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer("#", "#35;", `"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
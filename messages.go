@@ -0,0 +1,159 @@
+package main
+
+// catalogs holds the result text of every supported -lang, as format strings
+// keyed by message.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"analyzing":             "Analyzing paths from sources to sinks:",
+		"source":                "\nSource: %s (%s:%d)\n",
+		"sinkReached":           "  Sink reached: %s (%s:%d)\n",
+		"path":                  "  Path:",
+		"generatedFrom":         " generated from %s:%d",
+		"calledAt":              ", called at %s:%d",
+		"lastChanged":           ", last changed by %s on %s",
+		"pathCount":             "  Reachable through %s distinct paths\n",
+		"noSinks":               "  No sinks reached from this source.",
+		"analyzingBySink":       "Analyzing the sources reaching each sink:",
+		"sink":                  "\nSink: %s (%s:%d)\n",
+		"reachedFrom":           "  Reached from %d sources (closest: %d hops):\n",
+		"noSources":             "  Unreachable: no source reaches this sink.",
+		"queryCallers":          "\nCallers of %s (%s:%d):\n",
+		"queryCallees":          "\nFunctions called by %s (%s:%d):\n",
+		"queryNone":             "  None.",
+		"queryNoPath":           "No path from %s to %s.\n",
+		"blastNoHistory":        "\nBlast radius: %d findings (%d past runs recorded, %d needed for comparison)\n",
+		"blastRank":             "\nBlast radius: %d findings, larger than %.0f%% of the last %d runs (p%g = %d)\n",
+		"blastOutlier":          "  OUTLIER: above the p%g threshold, review the impact carefully\n",
+		"stringerDropped":       "\n%d source/sink pairs are only connected through String or Error calls on interfaces and were not reported, see -stringer-edges\n",
+		"policyViolations":      "\nPolicy violations:",
+		"policyMaxAffected":     "%d entrypoints reach changed code, the policy allows %d",
+		"policyForbidden":       "%s must not reach %s",
+		"htmlTitle":             "Reachability report",
+		"htmlSummary":           "Sources × sinks",
+		"htmlPaths":             "Paths",
+		"hops":                  "%d hops",
+		"paths":                 "%s paths",
+		"htmlTimeline":          "Timeline of the changed functions",
+		"htmlNoCommits":         "No commits touching it",
+		"htmlReachedFrom":       "Reached from %d entrypoints:",
+		"ghTitle":               "Reachable from %s",
+		"ghMessage":             "%s is reachable from the entrypoint %s in %s: %s",
+		"ghTruncated":           "The search from %s ran out of budget before reaching %s",
+		"mdTitle":               "Callgraph analysis",
+		"mdSummary":             "**%d** of %d entrypoints reach changed code (%d paths).",
+		"mdSource":              "Entrypoint",
+		"mdSinks":               "Changed functions reached",
+		"mdImpact":              "**%d** of %d changed functions are reached by an entrypoint.",
+		"mdSink":                "Changed function",
+		"mdSources":             "Entrypoints reaching it",
+		"mdMinHops":             "Closest",
+		"mdUnreachable":         "unreachable",
+		"diffNone":              "No differences between the findings.",
+		"diffAdded":             "Added findings (%d):\n",
+		"diffRemoved":           "Removed findings (%d):\n",
+		"diffChanged":           "Changed paths (%d):\n",
+		"diffBefore":            "      before: %s\n",
+		"diffAfter":             "      after:  %s\n",
+		"graphDiffNone":         "No differences between the call graphs.",
+		"graphDiffAddedFuncs":   "Added functions (%d):\n",
+		"graphDiffRemovedFuncs": "Removed functions (%d):\n",
+		"graphDiffAddedCalls":   "Added calls (%d):\n",
+		"graphDiffRemovedCalls": "Removed calls (%d):\n",
+		"graphDiffReached":      "Entrypoints reaching new code (%d):\n",
+		"graphDiffReaches":      "  %s now reaches %d more functions:\n",
+		"truncated":             "  Search truncated before reaching: %s (%s:%d)\n",
+		"ticketTitle":           "Verify %s: it reaches %d changed functions",
+		"ticketIntro":           "%s reaches code changed in this pull request:",
+		"crossRepo":             "  Cross-repo impact likely, called from %d functions of other repositories:\n",
+		"externalCaller":        "    - %s in %s (%s:%d)\n",
+		"crossRepoSink":         "  %s: cross-repo impact likely, called from %d functions of other repositories\n",
+		"elided":                "… %d calls … ",
+		"simReplayed":           "Replayed %d runs from %s\n",
+		"simNoPairs":            "  %d of them were recorded without their findings, only max_affected_entrypoints is checked on them\n",
+		"simBlocked":            "Blocked with -fail-on=policy: %d by the current policy, %d by the proposed policy\n",
+		"simNewly":              "\nNewly blocked runs (%d):\n",
+		"simCleared":            "\nRuns no longer blocked (%d):\n",
+		"simRun":                "  %s %s: %d entrypoints, %d findings\n",
+	},
+	"es": {
+		"analyzing":             "Analizando caminos desde los orígenes hasta los destinos:",
+		"source":                "\nOrigen: %s (%s:%d)\n",
+		"sinkReached":           "  Destino alcanzado: %s (%s:%d)\n",
+		"path":                  "  Camino:",
+		"generatedFrom":         " generado desde %s:%d",
+		"calledAt":              ", llamada en %s:%d",
+		"lastChanged":           ", modificada por última vez por %s el %s",
+		"pathCount":             "  Alcanzable por %s caminos distintos\n",
+		"noSinks":               "  Ningún destino alcanzado desde este origen.",
+		"analyzingBySink":       "Analizando los orígenes que alcanzan cada destino:",
+		"sink":                  "\nDestino: %s (%s:%d)\n",
+		"reachedFrom":           "  Alcanzado desde %d orígenes (el más cercano: %d saltos):\n",
+		"noSources":             "  Inalcanzable: ningún origen alcanza este destino.",
+		"queryCallers":          "\nLlamadores de %s (%s:%d):\n",
+		"queryCallees":          "\nFunciones llamadas por %s (%s:%d):\n",
+		"queryNone":             "  Ninguna.",
+		"queryNoPath":           "Ningún camino de %s a %s.\n",
+		"blastNoHistory":        "\nRadio de impacto: %d hallazgos (%d ejecuciones anteriores registradas, se necesitan %d para comparar)\n",
+		"blastRank":             "\nRadio de impacto: %d hallazgos, mayor que el %.0f%% de las últimas %d ejecuciones (p%g = %d)\n",
+		"blastOutlier":          "  ATÍPICO: supera el umbral p%g, revisar el impacto con cuidado\n",
+		"stringerDropped":       "\n%d pares origen/destino solo se conectan mediante llamadas a String o Error sobre interfaces y no se reportaron, ver -stringer-edges\n",
+		"policyViolations":      "\nViolaciones de la política:",
+		"policyMaxAffected":     "%d puntos de entrada alcanzan código modificado, la política permite %d",
+		"policyForbidden":       "%s no debe alcanzar %s",
+		"htmlTitle":             "Reporte de alcanzabilidad",
+		"htmlSummary":           "Orígenes × destinos",
+		"htmlPaths":             "Caminos",
+		"hops":                  "%d saltos",
+		"paths":                 "%s caminos",
+		"htmlTimeline":          "Historia de las funciones modificadas",
+		"htmlNoCommits":         "Ningún commit la modifica",
+		"htmlReachedFrom":       "Alcanzada desde %d puntos de entrada:",
+		"ghTitle":               "Alcanzable desde %s",
+		"ghMessage":             "%s es alcanzable desde el punto de entrada %s en %s: %s",
+		"ghTruncated":           "La búsqueda desde %s agotó su presupuesto antes de alcanzar %s",
+		"mdTitle":               "Análisis de grafo de llamadas",
+		"mdSummary":             "**%d** de %d puntos de entrada alcanzan código modificado (%d caminos).",
+		"mdSource":              "Punto de entrada",
+		"mdSinks":               "Funciones modificadas alcanzadas",
+		"mdImpact":              "**%d** de %d funciones modificadas son alcanzadas por un punto de entrada.",
+		"mdSink":                "Función modificada",
+		"mdSources":             "Puntos de entrada que la alcanzan",
+		"mdMinHops":             "Más cercano",
+		"mdUnreachable":         "inalcanzable",
+		"diffNone":              "No hay diferencias entre los hallazgos.",
+		"diffAdded":             "Hallazgos nuevos (%d):\n",
+		"diffRemoved":           "Hallazgos eliminados (%d):\n",
+		"diffChanged":           "Caminos modificados (%d):\n",
+		"diffBefore":            "      antes:   %s\n",
+		"diffAfter":             "      después: %s\n",
+		"graphDiffNone":         "No hay diferencias entre los grafos de llamadas.",
+		"graphDiffAddedFuncs":   "Funciones nuevas (%d):\n",
+		"graphDiffRemovedFuncs": "Funciones eliminadas (%d):\n",
+		"graphDiffAddedCalls":   "Llamadas nuevas (%d):\n",
+		"graphDiffRemovedCalls": "Llamadas eliminadas (%d):\n",
+		"graphDiffReached":      "Puntos de entrada que alcanzan código nuevo (%d):\n",
+		"graphDiffReaches":      "  %s ahora alcanza %d funciones más:\n",
+		"truncated":             "  Búsqueda interrumpida antes de alcanzar: %s (%s:%d)\n",
+		"ticketTitle":           "Verificar %s: alcanza %d funciones modificadas",
+		"ticketIntro":           "%s alcanza código modificado en este pull request:",
+		"crossRepo":             "  Probable impacto en otros repositorios, llamada desde %d funciones de otros repositorios:\n",
+		"externalCaller":        "    - %s en %s (%s:%d)\n",
+		"crossRepoSink":         "  %s: probable impacto en otros repositorios, llamada desde %d funciones de otros repositorios\n",
+		"elided":                "… %d llamadas … ",
+		"simReplayed":           "%d ejecuciones reproducidas de %s\n",
+		"simNoPairs":            "  %d de ellas se registraron sin sus hallazgos, solo se verifica max_affected_entrypoints en ellas\n",
+		"simBlocked":            "Bloqueadas con -fail-on=policy: %d por la política actual, %d por la política propuesta\n",
+		"simNewly":              "\nEjecuciones bloqueadas ahora (%d):\n",
+		"simCleared":            "\nEjecuciones que ya no se bloquean (%d):\n",
+		"simRun":                "  %s %s: %d puntos de entrada, %d hallazgos\n",
+	},
+}
+
+// msg returns the message for key in the selected language, falling back to
+// English.
+func msg(key string) string {
+	if s, ok := catalogs[lang][key]; ok {
+		return s
+	}
+	return catalogs["en"][key]
+}
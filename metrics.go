@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pushJob is the Pushgateway job name the gauges are grouped under.
+const pushJob = "callgraph_analysis"
+
+// runMetrics are the per-run gauges pushed to the Pushgateway.
+type runMetrics struct {
+	AffectedEntrypoints int
+	Findings            int
+	Duration            time.Duration
+}
+
+// pushMetrics replaces the gauges of this repo and branch group in the
+// Pushgateway with the ones of the current run.
+func pushMetrics(m runMetrics) error {
+	url := strings.TrimSuffix(pushgateway, "/") + "/metrics/job/" + pushJob +
+		"/repo@base64/" + groupingValue(repo) + "/branch@base64/" + groupingValue(branchName())
+
+	var body bytes.Buffer
+	writeGauge(&body, "affected_entrypoints", "Entrypoints reaching at least one sink.", float64(m.AffectedEntrypoints))
+	writeGauge(&body, "findings", "Source to sink paths found.", float64(m.Findings))
+	writeGauge(&body, "analysis_seconds", "Wall time of the analysis in seconds.", m.Duration.Seconds())
+
+	req, err := http.NewRequest(http.MethodPut, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway responded %s", resp.Status)
+	}
+	return nil
+}
+
+// writeGauge writes a gauge in the Prometheus text exposition format.
+func writeGauge(buf *bytes.Buffer, name, help string, value float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+}
+
+// groupingValue encodes a grouping key label value for the Pushgateway URL,
+// following a label name suffixed with @base64. Base64 is used
+// unconditionally, since branch names usually contain slashes; the empty
+// value is a single padding character, as a URL path segment can't be empty.
+func groupingValue(v string) string {
+	if v == "" {
+		return "="
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(v))
+}
+
+// branchName returns the -branch flag, or the branch checked out in dir, or ""
+// if it can't be determined.
+func branchName() string {
+	if branch != "" {
+		return branch
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushMetrics(t *testing.T) {
+	defer func(p, r, b, d string) { pushgateway, repo, branch, dir = p, r, b, d }(pushgateway, repo, branch, dir)
+	tests := []struct {
+		repo, branch string
+		wantPath     string
+	}{
+		{"ted", "feature/login", "/metrics/job/callgraph_analysis/repo@base64/dGVk/branch@base64/ZmVhdHVyZS9sb2dpbg"},
+		{"ted", "", "/metrics/job/callgraph_analysis/repo@base64/dGVk/branch@base64/="},
+	}
+	for _, tt := range tests {
+		var method, path, body string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			method, path, body = r.Method, r.URL.EscapedPath(), string(b)
+		}))
+		pushgateway, repo, branch = srv.URL+"/", tt.repo, tt.branch
+		if tt.branch == "" {
+			// Not a repository, so that no branch is checked out
+			dir = t.TempDir()
+		}
+		err := pushMetrics(runMetrics{AffectedEntrypoints: 2, Findings: 3})
+		srv.Close()
+		if err != nil {
+			t.Fatalf("pushMetrics: %v", err)
+		}
+		if method != http.MethodPut || path != tt.wantPath {
+			t.Errorf("%s %s, want PUT %s", method, path, tt.wantPath)
+		}
+		if !strings.Contains(body, "# TYPE findings gauge\nfindings 3\n") {
+			t.Errorf("body doesn't hold the findings gauge:\n%s", body)
+		}
+	}
+}
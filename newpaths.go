@@ -0,0 +1,73 @@
+package main
+
+import "educabot.com/callgraph-analysis/callgraphanalysis"
+
+// baseReach tells whether a source already reached a sink in the graph of
+// the base ref of -new-since, by full function names, through the same
+// calls the search follows.
+type baseReach struct {
+	funcs   map[string]*Func
+	g       map[*Func]map[*Func]bool
+	reached map[string]map[*Func]*Func
+}
+
+// newBaseReach indexes base, leaving out the calls through fmt.Stringer and
+// error unless -stringer-edges is set.
+func newBaseReach(base *Graph) *baseReach {
+	g := base.Adjacency()
+	if !stringerEdges {
+		if stringer := base.StringerPairs(); len(stringer) > 0 {
+			callgraphanalysis.RemovePairs(g, stringer)
+		}
+	}
+	return &baseReach{funcs: funcsByName(base), g: g, reached: make(map[string]map[*Func]*Func)}
+}
+
+// reaches reports whether source reached sink in the base graph, under
+// -max-depth calls if set. Functions missing from it reached nothing.
+func (b *baseReach) reaches(source, sink string) bool {
+	src, dest := b.funcs[source], b.funcs[sink]
+	if src == nil || dest == nil {
+		return false
+	}
+	if maxDepth > 0 {
+		return analyzer.FindShortestPath(src, dest, b.g, nil, callgraphanalysis.NewBudget(runCtx, 0, 0)) != nil
+	}
+	reached, ok := b.reached[source]
+	if !ok {
+		reached = reachable(src, b.g)
+		b.reached[source] = reached
+	}
+	_, ok = reached[dest]
+	return ok
+}
+
+// newFindings keeps the findings of results whose source didn't reach their
+// sink in the base graph: the pairs the change connects.
+func (b *baseReach) newFindings(results []SourceResult) []SourceResult {
+	for i, res := range results {
+		var kept []Finding
+		for _, f := range res.Findings {
+			if !b.reaches(res.Source.Function, f.Sink.Function) {
+				kept = append(kept, f)
+			}
+		}
+		results[i].Findings = kept
+	}
+	return results
+}
+
+// newSources keeps the sources of each sink of results that didn't reach it
+// in the base graph.
+func (b *baseReach) newSources(results []SinkResult) []SinkResult {
+	for i, res := range results {
+		kept := make([]ReachingSource, 0, len(res.Sources))
+		for _, source := range res.Sources {
+			if !b.reaches(source.Function, res.Sink.Function) {
+				kept = append(kept, source)
+			}
+		}
+		results[i].setSources(kept)
+	}
+	return results
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// pkgPatterns is a repeatable flag of comma-separated import path patterns,
+// written as for go list: "..." matches any string, so that
+// educabot.com/ted/internal/... is the package and every one below it, and
+// "*" any string without a slash.
+type pkgPatterns struct {
+	patterns []string
+	res      []*regexp.Regexp
+}
+
+// includePkgs and excludePkgs are the -include-pkg and -exclude-pkg flags.
+var includePkgs, excludePkgs pkgPatterns
+
+func (p *pkgPatterns) String() string { return strings.Join(p.patterns, ",") }
+
+func (p *pkgPatterns) Set(v string) error {
+	for _, pattern := range strings.Split(v, ",") {
+		pattern = strings.TrimSpace(pattern)
+		re := regexp.QuoteMeta(pattern)
+		re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+		re = strings.ReplaceAll(re, `\*`, `[^/]*`)
+		if strings.HasSuffix(re, `/.*`) {
+			re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+		}
+		p.patterns = append(p.patterns, pattern)
+		p.res = append(p.res, regexp.MustCompile("^"+re+"$"))
+	}
+	return nil
+}
+
+// matches reports whether the package path matches one of the patterns.
+func (p *pkgPatterns) matches(path string) bool {
+	for _, re := range p.res {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
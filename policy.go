@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// simulatedRun is a history record with the violations of each policy.
+type simulatedRun struct {
+	record            historyRecord
+	current, proposed []string
+}
+
+// runPolicy implements the policy subcommand. Its only action is simulate,
+// which replays the runs recorded with -history through a proposed policy to
+// tell how many would have been blocked by it, compared to the current one.
+func runPolicy(args []string) {
+	const policyUsage = "Usage: callgraph-analysis policy simulate -history=FILE -policy=FILE [flags]\n"
+	if len(args) == 0 || args[0] != "simulate" {
+		fmt.Fprint(os.Stderr, policyUsage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("policy simulate", flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Only replay the runs of this repository (default: the repo of the configuration, or every run)")
+	targetFlags(fs)
+	fs.StringVar(&configPath, "config", "", "Configuration file with the current policy (default: analysis.yaml in the analyzed directory, if present)")
+	proposedPath := fs.String("policy", "", "Configuration file with the proposed policy")
+	fs.StringVar(&historyFile, "history", "", "JSON lines file written by analyze -history")
+	fs.StringVar(&branch, "branch", "", "Only replay the runs of this branch, e.g. the one pull requests merge into")
+	fs.StringVar(&lang, "lang", "en", "Language of the result text: en or es")
+	fs.Parse(args[1:])
+
+	parseTestMode()
+	if err := applyConfig(); err != nil {
+		fatal("Error loading config:", err)
+	}
+	if historyFile == "" || *proposedPath == "" {
+		fatal("Error: history and policy flags are required")
+	}
+	if catalogs[lang] == nil {
+		fatalf("Error: unsupported language %q", lang)
+	}
+	proposed, err := loadConfig(*proposedPath)
+	if err != nil {
+		fatal("Error loading proposed policy:", err)
+	}
+	records, err := readHistory(historyFile)
+	if err != nil {
+		fatal("Error reading history:", err)
+	}
+
+	var runs []simulatedRun
+	for _, r := range records {
+		if repo != "" && r.Repo != repo || branch != "" && r.Branch != branch {
+			continue
+		}
+		run := simulatedRun{record: r}
+		results := r.results()
+		if run.current, err = policyViolations(config.Policy, r.AffectedEntrypoints, results); err != nil {
+			fatal("Error checking current policy:", err)
+		}
+		if run.proposed, err = policyViolations(proposed.Policy, r.AffectedEntrypoints, results); err != nil {
+			fatal("Error checking proposed policy:", err)
+		}
+		runs = append(runs, run)
+	}
+	printSimulation(os.Stdout, runs)
+}
+
+// results rebuilds the findings of r from its pairs, with full function names
+// for the frame names. It has no findings if r was recorded without pairs.
+func (r historyRecord) results() []SourceResult {
+	var results []SourceResult
+	index := make(map[string]int)
+	for _, pair := range r.Pairs {
+		i, ok := index[pair.Source]
+		if !ok {
+			i = len(results)
+			index[pair.Source] = i
+			results = append(results, SourceResult{Source: Frame{Name: pair.Source, Function: pair.Source}})
+		}
+		sink := Frame{Name: pair.Sink, Function: pair.Sink}
+		results[i].Findings = append(results[i].Findings, Finding{Sink: sink})
+	}
+	return results
+}
+
+// printSimulation writes how many runs each policy blocks, then the runs only
+// one of them blocks with the violations of the proposed policy.
+func printSimulation(w io.Writer, runs []simulatedRun) {
+	var current, proposed, noPairs int
+	var newly, cleared []simulatedRun
+	for _, run := range runs {
+		if run.record.Findings > 0 && len(run.record.Pairs) == 0 {
+			noPairs++
+		}
+		if len(run.current) > 0 {
+			current++
+		}
+		if len(run.proposed) > 0 {
+			proposed++
+		}
+		switch {
+		case len(run.proposed) > 0 && len(run.current) == 0:
+			newly = append(newly, run)
+		case len(run.proposed) == 0 && len(run.current) > 0:
+			cleared = append(cleared, run)
+		}
+	}
+
+	fmt.Fprintf(w, msg("simReplayed"), len(runs), historyFile)
+	if noPairs > 0 {
+		fmt.Fprintf(w, msg("simNoPairs"), noPairs)
+	}
+	fmt.Fprintf(w, msg("simBlocked"), current, proposed)
+	printSimulatedRuns(w, msg("simNewly"), newly, func(run simulatedRun) []string { return run.proposed })
+	printSimulatedRuns(w, msg("simCleared"), cleared, func(run simulatedRun) []string { return run.current })
+}
+
+// printSimulatedRuns writes runs under heading, each followed by the
+// violations returned by violations.
+func printSimulatedRuns(w io.Writer, heading string, runs []simulatedRun, violations func(simulatedRun) []string) {
+	if len(runs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, heading, len(runs))
+	for _, run := range runs {
+		r := run.record
+		fmt.Fprintf(w, msg("simRun"), r.Time.Format(time.RFC3339), r.Branch, r.AffectedEntrypoints, r.Findings)
+		for _, v := range violations(run) {
+			fmt.Fprintln(w, "    "+v)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var (
+	cpuProfile string // -cpuprofile
+	memProfile string // -memprofile
+	traceFile  string // -trace
+
+	// profiles are the files being written by startProfiling
+	profiles []*os.File
+)
+
+// profileFlags declares the flags profiling the run, for the commands
+// building the graph.
+func profileFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	fs.StringVar(&memProfile, "memprofile", "", "Write a heap profile at the end of the run to this file, for go tool pprof")
+	fs.StringVar(&traceFile, "trace", "", "Write an execution trace of the run to this file, for go tool trace")
+}
+
+// startProfiling starts the CPU profile and the trace asked for, stopped by
+// exit or stopProfiling.
+func startProfiling() {
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			fatal("Error creating CPU profile:", err)
+		}
+		profiles = append(profiles, f)
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fatal("Error starting CPU profile:", err)
+		}
+	}
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			fatal("Error creating trace:", err)
+		}
+		profiles = append(profiles, f)
+		if err := trace.Start(f); err != nil {
+			fatal("Error starting trace:", err)
+		}
+	}
+}
+
+// stopProfiling stops the CPU profile and the trace, and writes the heap
+// profile. Only its first call does.
+func stopProfiling() {
+	if profiles == nil && memProfile == "" {
+		return
+	}
+	pprof.StopCPUProfile()
+	trace.Stop()
+	for _, f := range profiles {
+		if err := f.Close(); err != nil {
+			log.Println("Warning: writing profile:", err)
+		}
+	}
+	profiles = nil
+	if memProfile != "" {
+		name := memProfile
+		memProfile = ""
+		f, err := os.Create(name)
+		if err != nil {
+			log.Println("Warning: creating heap profile:", err)
+			return
+		}
+		defer f.Close()
+		// Record the heap as of the last collection, like go test -memprofile
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			log.Println("Warning: writing heap profile:", err)
+		}
+	}
+}
+
+// exit ends the progress bar, stops profiling and exits with code.
+func exit(code int) {
+	runProgress.finish()
+	stopProfiling()
+	os.Exit(code)
+}
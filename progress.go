@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// progressMode is -progress: auto, lines or off.
+var progressMode string
+
+const (
+	// progressInterval is how often the lines report a phase going on
+	progressInterval = 10 * time.Second
+	// barInterval is how often the bar is redrawn
+	barInterval = 100 * time.Millisecond
+	barWidth    = 30
+)
+
+// phaseNames are the steps of the library as reported, with the units they
+// count.
+var phaseNames = map[callgraphanalysis.Phase][2]string{
+	callgraphanalysis.PhaseLoad:   {"Loading packages", "packages"},
+	callgraphanalysis.PhaseBuild:  {"Building the SSA form", "packages"},
+	callgraphanalysis.PhaseGraph:  {"Building the call graph", "functions"},
+	callgraphanalysis.PhaseSearch: {"Searching paths", "sources"},
+}
+
+// progressFlags declares -progress.
+func progressFlags(fs *flag.FlagSet) {
+	fs.StringVar(&progressMode, "progress", "auto", "Report the phases of the analysis to stderr: auto (a progress bar when stderr is a terminal, lines otherwise), lines or off")
+}
+
+// progress reports the phases of the run to stderr, as a line per phase and
+// every progressInterval, or as a bar redrawn in place. A nil progress
+// reports nothing.
+type progress struct {
+	mu          sync.Mutex
+	w           io.Writer
+	bar         bool
+	name, unit  string
+	done, total int
+	start, last time.Time
+	drawn       bool // the bar of the phase is on screen
+}
+
+var (
+	progressOnce sync.Once
+	runProgress  *progress
+)
+
+// reporter returns the progress of the run, nil when -progress=off, -quiet
+// or for the commands without -progress. With the bar, the log is written
+// above it.
+func reporter() *progress {
+	progressOnce.Do(func() {
+		switch {
+		case progressMode == "off" || progressMode == "" || quiet:
+			return
+		case progressMode != "auto" && progressMode != "lines":
+			fatalf("Error: progress must be auto, lines or off, got %q", progressMode)
+		}
+		runProgress = &progress{w: os.Stderr, bar: progressMode == "auto" && terminal(os.Stderr)}
+		if runProgress.bar {
+			log.SetOutput(runProgress)
+		}
+		go runProgress.tick()
+	})
+	return runProgress
+}
+
+// libraryProgress returns the Progress func of the library reporting to the
+// progress of the run and starting the time limits of the phases, nil if
+// there are neither.
+func libraryProgress() func(callgraphanalysis.Progress) {
+	p := reporter()
+	if p == nil && phases.cancel == nil {
+		return nil
+	}
+	return func(lp callgraphanalysis.Progress) {
+		enterPhase(lp.Phase)
+		names := phaseNames[lp.Phase]
+		p.report(names[0], names[1], lp.Done, lp.Total)
+	}
+}
+
+// report reports that the phase name is done with done of its total units,
+// starting it if it is not the current one.
+func (p *progress) report(name, unit string, done, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	started := name != p.name
+	if started {
+		p.end()
+		p.name, p.unit, p.start, p.last = name, unit, now, time.Time{}
+	}
+	p.done, p.total = done, total
+	finished := total > 0 && done == total
+	p.show(now, started || finished)
+	if finished {
+		p.end()
+		p.name = ""
+	}
+}
+
+// tick keeps showing the phase going on while it reports nothing, so a long
+// phase is not taken for a hang.
+func (p *progress) tick() {
+	for now := range time.Tick(time.Second) {
+		p.mu.Lock()
+		if p.name != "" {
+			p.show(now, false)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// show draws the bar or logs the line of the current phase, unless shown
+// less than an interval ago and not forced.
+func (p *progress) show(now time.Time, force bool) {
+	if p.bar {
+		if force || now.Sub(p.last) >= barInterval {
+			p.draw(now)
+			p.last = now
+		}
+		return
+	}
+	if force || now.Sub(p.last) >= progressInterval {
+		log.Print(p.line(now))
+		p.last = now
+	}
+}
+
+// end ends the bar of the current phase, leaving it on screen.
+func (p *progress) end() {
+	if p.drawn {
+		fmt.Fprintln(p.w)
+		p.drawn = false
+	}
+}
+
+// finish ends the bar of the run before the results are written.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.end()
+	p.name = ""
+}
+
+// line describes the current phase.
+func (p *progress) line(now time.Time) string {
+	elapsed := now.Sub(p.start).Round(100 * time.Millisecond)
+	switch {
+	case p.total == 0 && elapsed < time.Second:
+		return p.name + "..."
+	case p.total == 0:
+		return fmt.Sprintf("%s... %v", p.name, elapsed.Round(time.Second))
+	case p.done == p.total:
+		return fmt.Sprintf("%s: %d %s in %v", p.name, p.total, p.unit, elapsed)
+	case elapsed < time.Second:
+		return fmt.Sprintf("%s: %d/%d %s", p.name, p.done, p.total, p.unit)
+	}
+	return fmt.Sprintf("%s: %d/%d %s, %v", p.name, p.done, p.total, p.unit, elapsed.Round(time.Second))
+}
+
+// draw redraws the bar of the current phase in place.
+func (p *progress) draw(now time.Time) {
+	bar := ""
+	if p.total > 0 && p.done < p.total {
+		filled := barWidth * p.done / p.total
+		bar = "[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "] "
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s%s", bar, p.line(now))
+	p.drawn = true
+}
+
+// Write writes the log above the bar, which is drawn again below it.
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	n, err := p.w.Write(b)
+	if p.drawn {
+		p.draw(time.Now())
+	}
+	return n, err
+}
+
+// terminal reports whether f is a terminal.
+func terminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
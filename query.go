@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// queryUsage is printed when query is run without a known question.
+const queryUsage = `Usage: callgraph-analysis query <question> [flags] <functions>...
+
+Questions:
+  callers FUNC   the functions calling FUNC
+  callees FUNC   the functions FUNC calls
+  path FROM TO   the path with the fewest calls from FROM to TO
+
+FUNC, FROM and TO are regular expressions over full function names, e.g.
+usecases\.SaveV2$.
+`
+
+// queryAnswer is the answer about one function matched by a callers or
+// callees query: the functions calling it or called by it, with the position
+// of the call.
+type queryAnswer struct {
+	Function  Frame   `json:"function"`
+	Functions []Frame `json:"functions"`
+}
+
+// runQuery implements the query subcommand: it answers ad-hoc questions
+// about the call graph, saved or built, without sources or sinks.
+func runQuery(args []string) {
+	operands := map[string]int{"callers": 1, "callees": 1, "path": 2}
+	if len(args) == 0 || operands[args[0]] == 0 {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Unknown query %q\n\n", args[0])
+		}
+		fmt.Fprint(os.Stderr, queryUsage)
+		os.Exit(exitError)
+	}
+	question := args[0]
+	fs := flag.NewFlagSet("query "+question, flag.ExitOnError)
+	fs.StringVar(&repo, "repo", "", "Repository name or module path overriding the one of go.mod")
+	targetFlags(fs)
+	fs.StringVar(&configPath, "config", "", "Configuration file (default: analysis.yaml in the analyzed directory, if present)")
+	graphFlags(fs)
+	fs.StringVar(&graphPath, "graph", "", "Query a graph saved by the graph subcommand instead of loading the packages")
+	fs.BoolVar(&useDaemon, "daemon", false, "Get the graph from the daemon started with \"serve\", building it if none is running")
+	fs.StringVar(&socketPath, "socket", "", "Unix socket of the daemon (default: "+socketName+" in the analyzed directory)")
+	fs.BoolVar(&stringerEdges, "stringer-edges", false, "Follow the calls to String and Error methods through interfaces when searching a path")
+	fs.StringVar(&format, "format", "text", "Output format: text or json")
+	fs.StringVar(&lang, "lang", "en", "Language of the result text: en or es")
+	fs.Parse(args[1:])
+	startProfiling()
+
+	if fs.NArg() != operands[question] {
+		fatalf("Error: query %s takes %d functions, got %d", question, operands[question], fs.NArg())
+	}
+	var patterns []*regexp.Regexp
+	for _, arg := range fs.Args() {
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			fatal("Error in query:", err)
+		}
+		patterns = append(patterns, re)
+	}
+	if format != "text" && format != "json" {
+		fatalf("Error: query works with the text and json formats, got %q", format)
+	}
+	if catalogs[lang] == nil {
+		fatalf("Error: unsupported language %q", lang)
+	}
+
+	parseTestMode()
+	if err := applyConfig(); err != nil {
+		fatal("Error loading config:", err)
+	}
+	requireTarget()
+
+	graph := storedGraph()
+	if graph == nil {
+		graph = targetGraph()
+	}
+	callSites = graph.CallSites()
+	var matches [][]*Func
+	for i, re := range patterns {
+		fns := matchFuncs(graph, re)
+		if len(fns) == 0 {
+			fatalf("Error: no function matches %q", fs.Arg(i))
+		}
+		matches = append(matches, fns)
+	}
+
+	var err error
+	switch question {
+	case "callers", "callees":
+		answers := neighbors(graph, matches[0], question == "callers")
+		if format == "json" {
+			err = writeQueryJSON(os.Stdout, answers)
+		} else {
+			printNeighbors(os.Stdout, answers, question == "callers")
+		}
+	case "path":
+		g := graph.Adjacency()
+		if !stringerEdges {
+			callgraphanalysis.RemovePairs(g, graph.StringerPairs())
+		}
+		path := shortestPath(runCtx, matches[0], matches[1], g)
+		if format == "json" {
+			frames := pathFrames(path)
+			if frames == nil {
+				frames = []Frame{}
+			}
+			err = writeQueryJSON(os.Stdout, frames)
+		} else {
+			printQueryPath(os.Stdout, path, fs.Arg(0), fs.Arg(1))
+		}
+	}
+	if err != nil {
+		fatal("Error writing results:", err)
+	}
+}
+
+// matchFuncs returns the functions of graph whose full name matches re, in
+// declaration order.
+func matchFuncs(graph *Graph, re *regexp.Regexp) []*Func {
+	var fns []*Func
+	for _, fn := range graph.Funcs {
+		if re.MatchString(fn.Function) {
+			fns = append(fns, fn)
+		}
+	}
+	return fns
+}
+
+// neighbors returns the callers, or else the callees, of every function of
+// fns, each with the position of the call.
+func neighbors(graph *Graph, fns []*Func, callers bool) []queryAnswer {
+	rel := graph.Callees()
+	if callers {
+		rel = graph.Callers()
+	}
+	var answers []queryAnswer
+	for _, fn := range fns {
+		answer := queryAnswer{Function: newFrame(fn), Functions: []Frame{}}
+		for _, other := range sortFuncs(rel[fn]) {
+			caller, callee := fn, other
+			if callers {
+				caller, callee = other, fn
+			}
+			frame := newFrame(other)
+			if site, ok := callSites[[2]string{caller.Function, callee.Function}]; ok {
+				frame.Call = &site
+			}
+			answer.Functions = append(answer.Functions, frame)
+		}
+		answers = append(answers, answer)
+	}
+	return answers
+}
+
+// shortestPath returns the path with the fewest calls from a function of from
+// to one of to, the first found in declaration order among those of the same
+// length, or nil if there is none or ctx is done first.
+func shortestPath(ctx context.Context, from, to []*Func, g map[*Func]map[*Func]bool) []*Func {
+	var best []*Func
+	for _, src := range from {
+		paths := analyzer.FindShortestPaths(src, to, g, callgraphanalysis.NewBudget(ctx, 0, 0))
+		for _, dest := range to {
+			if path := paths[dest]; path != nil && (best == nil || len(path) < len(best)) {
+				best = path
+			}
+		}
+	}
+	return best
+}
+
+// printNeighbors writes the answers to a callers or callees query.
+func printNeighbors(w io.Writer, answers []queryAnswer, callers bool) {
+	heading := msg("queryCallees")
+	if callers {
+		heading = msg("queryCallers")
+	}
+	for _, a := range answers {
+		fmt.Fprintf(w, heading, a.Function.Function, a.Function.File, a.Function.Line)
+		if len(a.Functions) == 0 {
+			fmt.Fprintln(w, msg("queryNone"))
+		}
+		for _, frame := range a.Functions {
+			fmt.Fprintf(w, "  %s (%s:%d)", frame.Function, frame.File, frame.Line)
+			if frame.Call != nil {
+				fmt.Fprintf(w, msg("calledAt"), frame.Call.File, frame.Call.Line)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// printQueryPath writes the answer to a path query.
+func printQueryPath(w io.Writer, path []*Func, from, to string) {
+	if path == nil {
+		fmt.Fprintf(w, msg("queryNoPath"), from, to)
+		return
+	}
+	for i, frame := range pathFrames(path) {
+		fmt.Fprintf(w, "%d. %s (%s:%d)", i+1, frame.Function, frame.File, frame.Line)
+		if frame.Origin != nil {
+			fmt.Fprintf(w, msg("generatedFrom"), frame.Origin.File, frame.Origin.Line)
+		}
+		if frame.Call != nil {
+			fmt.Fprintf(w, msg("calledAt"), frame.Call.File, frame.Call.Line)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeQueryJSON writes the answer to a query as indented JSON.
+func writeQueryJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
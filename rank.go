@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// rankCriteria score a path for -rank: the lower the score, the more useful
+// the path is to a reviewer.
+var rankCriteria = map[string]func(path []*Func) int{
+	// hops is the number of calls.
+	"hops": func(path []*Func) int { return len(path) - 1 },
+	// generated is the number of functions declared in generated files.
+	"generated": func(path []*Func) int {
+		n := 0
+		for _, fn := range path {
+			if generatedFunc(fn) {
+				n++
+			}
+		}
+		return n
+	},
+	// bridges is the number of anonymous functions and wrappers, which only
+	// forward the call.
+	"bridges": func(path []*Func) int {
+		n := 0
+		for _, fn := range path {
+			if strings.Contains(fn.Name, "$") {
+				n++
+			}
+		}
+		return n
+	},
+	// packages is the number of calls crossing into another package.
+	"packages": func(path []*Func) int {
+		n := 0
+		for i := 1; i < len(path); i++ {
+			if path[i].Pkg != path[i-1].Pkg {
+				n++
+			}
+		}
+		return n
+	},
+}
+
+// parseRank splits the -rank criteria, checking they are known.
+func parseRank(flag string) ([]string, error) {
+	criteria := strings.Split(flag, ",")
+	for i, c := range criteria {
+		c = strings.TrimSpace(c)
+		if rankCriteria[c] == nil {
+			return nil, fmt.Errorf("unknown criterion %q, expected hops, generated, bridges or packages", c)
+		}
+		criteria[i] = c
+	}
+	return criteria, nil
+}
+
+// rankPaths orders the paths of a pair by the -rank criteria, the first one
+// deciding and the next ones breaking ties. Paths tied on every criterion
+// keep the order they were found in.
+func rankPaths(paths [][]*Func) {
+	if len(rank) == 0 || len(paths) < 2 {
+		return
+	}
+	scores := make([][]int, len(paths))
+	for i, path := range paths {
+		for _, c := range rank {
+			scores[i] = append(scores[i], rankCriteria[c](path))
+		}
+	}
+	order := make([]int, len(paths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := scores[order[a]], scores[order[b]]
+		for k := range sa {
+			if sa[k] != sb[k] {
+				return sa[k] < sb[k]
+			}
+		}
+		return false
+	})
+	sorted := make([][]*Func, len(paths))
+	for i, j := range order {
+		sorted[i] = paths[j]
+	}
+	copy(paths, sorted)
+}
+
+// generatedFiles caches whether the files of the analyzed functions are
+// generated. Paths are ranked in parallel, so it is guarded by
+// generatedFilesMu.
+var (
+	generatedFiles   = make(map[string]bool)
+	generatedFilesMu sync.Mutex
+)
+
+// generatedFunc reports whether fn is declared in a generated file: one with
+// //line directives or the generated code header.
+func generatedFunc(fn *Func) bool {
+	if fn.Origin != nil {
+		return true
+	}
+	if fn.File == "" {
+		return false
+	}
+	generatedFilesMu.Lock()
+	defer generatedFilesMu.Unlock()
+	generated, ok := generatedFiles[fn.File]
+	if !ok {
+		_, generated, _ = generatedBy(fn.File)
+		generatedFiles[fn.File] = generated
+	}
+	return generated
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// Frame is a function in a reported path. Origin is set for functions of
+// generated files with //line directives: the position in the file they were
+// generated from, which is the one to edit. Call is set on every hop after the
+// source: the position of the call from the previous function of the path.
+type Frame struct {
+	Name     string    `json:"name"`
+	Function string    `json:"function"`
+	File     string    `json:"file"`
+	Line     int       `json:"line"`
+	Origin   *Position `json:"origin,omitempty"`
+	Call     *Position `json:"call,omitempty"`
+	Blame    *Blame    `json:"blame,omitempty"`
+}
+
+// edited returns the position of f in the file to edit: its origin if it was
+// generated, otherwise its declaration.
+func (f Frame) edited() (file string, line int) {
+	if f.Origin != nil {
+		return f.Origin.File, f.Origin.Line
+	}
+	return f.File, f.Line
+}
+
+// Finding is a path from a source function to a sink function. PathCount is
+// the number of paths between them, up to PathCountCap. Truncated is only set
+// on the findings streamed for sinks whose search ran out of budget, which
+// have no path. ExternalCallers are the callers of the sink in the
+// -org-graphs when it is part of the public API of the module, and CrossRepo
+// marks that there are some.
+type Finding struct {
+	Sink            Frame            `json:"sink"`
+	Path            []Frame          `json:"path"`
+	PathCount       int              `json:"path_count,omitempty"`
+	Truncated       bool             `json:"truncated,omitempty"`
+	ExternalCallers []ExternalCaller `json:"external_callers,omitempty"`
+	CrossRepo       bool             `json:"cross_repo_impact,omitempty"`
+}
+
+// ndjsonFinding is a line of -format=ndjson.
+type ndjsonFinding struct {
+	Source Frame `json:"source"`
+	Finding
+}
+
+// SourceResult holds the findings of a source function, one per reached sink.
+// Truncated lists the sinks whose search ran out of the per-source budget, so
+// they may be reached without a finding.
+type SourceResult struct {
+	Source    Frame     `json:"source"`
+	Findings  []Finding `json:"findings"`
+	Truncated []Frame   `json:"truncated,omitempty"`
+}
+
+// callSites holds the position of the call between every caller and callee
+// of the graph analyzed, by full names.
+var callSites map[[2]string]Position
+
+// newFrame describes fn at its declaration position.
+func newFrame(fn *Func) Frame {
+	return Frame{Name: fn.Name, Function: fn.Function, File: fn.File, Line: fn.Line, Origin: fn.Origin}
+}
+
+// findPaths looks for one path from every source to every sink, the shortest
+// one with -shortest, the -k-paths shortest ones, or every simple path up to
+// -max-paths with -all-paths, ranked by -rank, within the budget of
+// -per-source-timeout and -max-visits, and reports them by source. When the
+// analysis is canceled, the pairs left are reported as truncated along with
+// the error. When found is not nil, it is called with every finding,
+// truncated ones included, as soon as it is found.
+func findPaths(sourceFuncs, sinkFuncs map[*Func]bool, g map[*Func]map[*Func]bool, found func(Frame, Finding)) ([]SourceResult, error) {
+	q := callgraphanalysis.Query{
+		Graph:     g,
+		Sources:   sourceFuncs,
+		Sinks:     sinkFuncs,
+		CallSites: callSites,
+		Timeout:   perSourceTimeout,
+		Visits:    maxVisits,
+		Rank:      rankPaths,
+		Cache:     graphCache(),
+		Key:       searchKey,
+	}
+	if found != nil {
+		q.Found = func(pair callgraphanalysis.Pair) {
+			source := newFrame(pair.Source)
+			for _, f := range pairFindings(pair) {
+				found(source, f)
+			}
+			if pair.Truncated {
+				found(source, Finding{Sink: newFrame(pair.Sink), Truncated: true})
+			}
+		}
+	}
+	r, err := analyzer.Analyze(runCtx, q)
+	return sourceResults(r), err
+}
+
+// sourceResults renders r as the findings of every source.
+func sourceResults(r *callgraphanalysis.Result) []SourceResult {
+	results := make([]SourceResult, 0, len(r.Sources))
+	bySource := make(map[*Func]*SourceResult, len(r.Sources))
+	for _, fn := range r.Sources {
+		results = append(results, SourceResult{Source: newFrame(fn)})
+		bySource[fn] = &results[len(results)-1]
+	}
+	for _, pair := range r.Pairs {
+		res := bySource[pair.Source]
+		res.Findings = append(res.Findings, pairFindings(pair)...)
+		if pair.Truncated {
+			res.Truncated = append(res.Truncated, newFrame(pair.Sink))
+		}
+	}
+	return results
+}
+
+// pairFindings renders the paths of pair as findings, with the callers of
+// the sink in the -org-graphs.
+func pairFindings(pair callgraphanalysis.Pair) []Finding {
+	var findings []Finding
+	for _, path := range pair.Paths {
+		finding := Finding{Sink: newFrame(pair.Sink), PathCount: pair.PathCount, Path: hopFrames(path)}
+		if publicAPI(pair.Sink) {
+			finding.ExternalCallers = externalCallers[pair.Sink.Function]
+			finding.CrossRepo = len(finding.ExternalCallers) > 0
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// pathFrames describes the functions of path, with the position of the call
+// leading to every hop after the first.
+func pathFrames(path []*Func) []Frame {
+	return hopFrames(callgraphanalysis.Hops(path, callSites))
+}
+
+// hopFrames describes the functions of a path.
+func hopFrames(hops []callgraphanalysis.Hop) []Frame {
+	var frames []Frame
+	for _, hop := range hops {
+		frame := newFrame(hop.Func)
+		frame.Call = hop.Call
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// summarize returns the number of sources reaching at least one sink and the
+// total number of findings.
+func summarize(results []SourceResult) (affected, findings int) {
+	for _, res := range results {
+		if len(res.Findings) > 0 {
+			affected++
+		}
+		findings += len(res.Findings)
+	}
+	return affected, findings
+}
+
+// formatPathCount writes a path count, marking the capped ones.
+func formatPathCount(n int) string {
+	if n >= callgraphanalysis.PathCountCap {
+		return strconv.Itoa(n) + "+"
+	}
+	return strconv.Itoa(n)
+}
+
+// printText writes the results in the human-readable text format, with no
+// heading under -quiet.
+func printText(w io.Writer, results []SourceResult) {
+	if !quiet {
+		fmt.Fprintln(w, msg("analyzing"))
+	}
+	for _, res := range results {
+		fmt.Fprintf(w, msg("source"), res.Source.Name, res.Source.File, res.Source.Line)
+		for _, finding := range res.Findings {
+			fmt.Fprintf(w, msg("sinkReached"), finding.Sink.Name, finding.Sink.File, finding.Sink.Line)
+			if finding.PathCount > 1 {
+				fmt.Fprintf(w, msg("pathCount"), formatPathCount(finding.PathCount))
+			}
+			printExternalCallers(w, finding)
+			fmt.Fprintln(w, msg("path"))
+			for i, frame := range finding.Path {
+				fmt.Fprintf(w, "    %d. %s (%s:%d)", i+1, frame.Name, frame.File, frame.Line)
+				if frame.Origin != nil {
+					fmt.Fprintf(w, msg("generatedFrom"), frame.Origin.File, frame.Origin.Line)
+				}
+				if frame.Call != nil {
+					fmt.Fprintf(w, msg("calledAt"), frame.Call.File, frame.Call.Line)
+				}
+				fmt.Fprint(w, blameSuffix(frame))
+				fmt.Fprintln(w)
+			}
+		}
+		if len(res.Findings) == 0 && len(res.Truncated) == 0 {
+			fmt.Fprintln(w, msg("noSinks"))
+		}
+		for _, sink := range res.Truncated {
+			fmt.Fprintf(w, msg("truncated"), sink.Name, sink.File, sink.Line)
+		}
+	}
+}
+
+// printExternalCallers writes the callers of the sink of finding in other
+// repositories, if any.
+func printExternalCallers(w io.Writer, finding Finding) {
+	if !finding.CrossRepo {
+		return
+	}
+	fmt.Fprintf(w, msg("crossRepo"), len(finding.ExternalCallers))
+	for _, c := range finding.ExternalCallers {
+		fmt.Fprintf(w, msg("externalCaller"), c.Name, c.Module, c.File, c.Line)
+	}
+}
+
+// writeJSON writes the results as an indented JSON array, the artifact read
+// back by diff results.
+func writeJSON(w io.Writer, results []SourceResult) error {
+	if results == nil {
+		results = []SourceResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// readJSON reads results written by writeJSON.
+func readJSON(name string) ([]SourceResult, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var results []SourceResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// findingKey identifies a finding across two results by its source and sink.
+type findingKey struct {
+	source, sink string
+}
+
+// resultsDiff holds the findings added, removed and reached by another path
+// between two results, in the order of the results they come from.
+type resultsDiff struct {
+	added, removed []sourceFinding
+	changed        []pathChange
+}
+
+// sourceFinding is a finding with the source it was found from.
+type sourceFinding struct {
+	source Frame
+	Finding
+}
+
+// pathChange is a finding whose path differs between the two results.
+type pathChange struct {
+	source        Frame
+	before, after Finding
+}
+
+// runResultsDiff implements the diff results subcommand: it compares the
+// findings of two -format=json results. Like diff, it exits with status 1
+// when they differ.
+func runResultsDiff(args []string) {
+	fs := flag.NewFlagSet("diff results", flag.ExitOnError)
+	fs.StringVar(&lang, "lang", "en", "Language of the result text: en or es")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: callgraph-analysis diff results [flags] before.json after.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if catalogs[lang] == nil {
+		fatalf("Error: unsupported language %q", lang)
+	}
+	var results [2][]SourceResult
+	for i := range results {
+		var err error
+		if results[i], err = readJSON(fs.Arg(i)); err != nil {
+			fatal("Error reading results:", err)
+		}
+	}
+
+	d := diffResults(results[0], results[1])
+	d.print(os.Stdout)
+	if len(d.added)+len(d.removed)+len(d.changed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// diffResults compares the findings of before and after by source and sink
+// function.
+func diffResults(before, after []SourceResult) resultsDiff {
+	index := func(results []SourceResult) map[findingKey]Finding {
+		m := make(map[findingKey]Finding)
+		for _, res := range results {
+			for _, finding := range res.Findings {
+				m[findingKey{res.Source.Function, finding.Sink.Function}] = finding
+			}
+		}
+		return m
+	}
+	old, cur := index(before), index(after)
+
+	var d resultsDiff
+	for _, res := range after {
+		for _, finding := range res.Findings {
+			prev, ok := old[findingKey{res.Source.Function, finding.Sink.Function}]
+			switch {
+			case !ok:
+				d.added = append(d.added, sourceFinding{res.Source, finding})
+			case !samePath(prev.Path, finding.Path):
+				d.changed = append(d.changed, pathChange{source: res.Source, before: prev, after: finding})
+			}
+		}
+	}
+	for _, res := range before {
+		for _, finding := range res.Findings {
+			if _, ok := cur[findingKey{res.Source.Function, finding.Sink.Function}]; !ok {
+				d.removed = append(d.removed, sourceFinding{res.Source, finding})
+			}
+		}
+	}
+	return d
+}
+
+// samePath reports whether two paths go through the same functions; positions
+// are ignored since unrelated edits move them.
+func samePath(a, b []Frame) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Function != b[i].Function {
+			return false
+		}
+	}
+	return true
+}
+
+// print writes the differences in the human-readable text format.
+func (d resultsDiff) print(w io.Writer) {
+	if len(d.added)+len(d.removed)+len(d.changed) == 0 {
+		fmt.Fprintln(w, msg("diffNone"))
+		return
+	}
+	if len(d.added) > 0 {
+		fmt.Fprintf(w, msg("diffAdded"), len(d.added))
+		for _, f := range d.added {
+			fmt.Fprintf(w, "  + %s -> %s\n      %s\n", f.source.Name, f.Sink.Name, pathString(f.Path))
+		}
+	}
+	if len(d.removed) > 0 {
+		fmt.Fprintf(w, msg("diffRemoved"), len(d.removed))
+		for _, f := range d.removed {
+			fmt.Fprintf(w, "  - %s -> %s\n      %s\n", f.source.Name, f.Sink.Name, pathString(f.Path))
+		}
+	}
+	if len(d.changed) > 0 {
+		fmt.Fprintf(w, msg("diffChanged"), len(d.changed))
+		for _, c := range d.changed {
+			fmt.Fprintf(w, "  ~ %s -> %s\n", c.source.Name, c.after.Sink.Name)
+			fmt.Fprintf(w, msg("diffBefore"), pathString(c.before.Path))
+			fmt.Fprintf(w, msg("diffAfter"), pathString(c.after.Path))
+		}
+	}
+}
+
+// pathString renders a path as its function names joined by arrows.
+func pathString(path []Frame) string {
+	names := make([]string, len(path))
+	for i, frame := range path {
+		names[i] = frame.Name
+	}
+	return strings.Join(names, " -> ")
+}
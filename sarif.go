@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// sarifRuleID identifies source to sink reachability results.
+const sarifRuleID = "source-reaches-sink"
+
+// The SARIF 2.1.0 subset needed to report findings with their paths.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+		FullDescription  sarifMessage `json:"fullDescription"`
+	}
+	sarifResult struct {
+		RuleID              string            `json:"ruleId"`
+		Level               string            `json:"level"`
+		Message             sarifMessage      `json:"message"`
+		Locations           []sarifLocation   `json:"locations"`
+		RelatedLocations    []sarifLocation   `json:"relatedLocations,omitempty"`
+		CodeFlows           []sarifCodeFlow   `json:"codeFlows,omitempty"`
+		PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		ID               int                   `json:"id,omitempty"`
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+		Message          *sarifMessage         `json:"message,omitempty"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           *sarifRegion          `json:"region,omitempty"`
+	}
+	sarifArtifactLocation struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId,omitempty"`
+	}
+	sarifRegion struct {
+		StartLine int `json:"startLine"`
+	}
+	sarifCodeFlow struct {
+		ThreadFlows []sarifThreadFlow `json:"threadFlows"`
+	}
+	sarifThreadFlow struct {
+		Locations []sarifThreadFlowLocation `json:"locations"`
+	}
+	sarifThreadFlowLocation struct {
+		Location sarifLocation `json:"location"`
+	}
+)
+
+// writeSARIF writes one SARIF result per finding, located at the sink and
+// carrying the path from the source as a code flow.
+func writeSARIF(w io.Writer, results []SourceResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name: "callgraph-analysis",
+			Rules: []sarifRule{{
+				ID:               sarifRuleID,
+				ShortDescription: sarifMessage{Text: "Changed code is reachable from an entrypoint"},
+				FullDescription:  sarifMessage{Text: "A call path exists from a source function (entrypoint) to a sink function (changed code)."},
+			}},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, res := range results {
+		for _, finding := range res.Findings {
+			flow := sarifThreadFlow{}
+			for _, frame := range finding.Path {
+				// Hops are located at the call leading to them
+				loc := sarifFrameLocation(frame)
+				if frame.Call != nil {
+					loc = sarifFrameLocation(Frame{File: frame.Call.File, Line: frame.Call.Line})
+				}
+				loc.Message = &sarifMessage{Text: frame.Name}
+				flow.Locations = append(flow.Locations, sarifThreadFlowLocation{Location: loc})
+			}
+			source := sarifFrameLocation(res.Source)
+			source.ID = 1
+			source.Message = &sarifMessage{Text: "entrypoint " + res.Source.Name}
+
+			sum := sha256.Sum256([]byte(res.Source.Function + "\x00" + finding.Sink.Function))
+			run.Results = append(run.Results, sarifResult{
+				RuleID: sarifRuleID,
+				Level:  "warning",
+				Message: sarifMessage{Text: fmt.Sprintf("%s is reachable from [entrypoint %s](1) in %d hops",
+					finding.Sink.Name, res.Source.Name, len(finding.Path)-1)},
+				Locations:           []sarifLocation{sarifFrameLocation(finding.Sink)},
+				RelatedLocations:    []sarifLocation{source},
+				CodeFlows:           []sarifCodeFlow{{ThreadFlows: []sarifThreadFlow{flow}}},
+				PartialFingerprints: map[string]string{"sourceSink/v1": hex.EncodeToString(sum[:])},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifFrameLocation locates a frame in the file to edit, relative to the
+// source root.
+func sarifFrameLocation(frame Frame) sarifLocation {
+	file, line := frame.edited()
+	loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(relPath(file)), URIBaseID: "%SRCROOT%"},
+	}}
+	if line > 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+	}
+	return loc
+}
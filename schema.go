@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configValidator checks the YAML document of a configuration file against
+// the fields of Config, collecting every problem at its position.
+type configValidator struct {
+	name string
+	errs []error
+}
+
+// validateConfig reports the unknown keys of the configuration file name,
+// with the closest known key as a suggestion, the values of the wrong kind and
+// the regular expressions that don't compile, instead of letting them be
+// ignored or fail the decoding with an error without position.
+func validateConfig(name string, data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	v := &configValidator{name: name}
+	if len(doc.Content) > 0 {
+		v.check(doc.Content[0], reflect.TypeOf(Config{}), "", false)
+	}
+	return errors.Join(v.errs...)
+}
+
+// errorf records a problem at the position of node.
+func (v *configValidator) errorf(node *yaml.Node, format string, args ...any) {
+	pos := fmt.Sprintf("%s:%d:%d: ", v.name, node.Line, node.Column)
+	v.errs = append(v.errs, errors.New(pos+fmt.Sprintf(format, args...)))
+}
+
+// check validates node as a value of type t at path, the dotted keys leading
+// to it. Strings with isRegexp set must be regular expressions, as marked by
+// the schema:"regexp" tag of their field.
+func (v *configValidator) check(node *yaml.Node, t reflect.Type, path string, isRegexp bool) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.ShortTag() == "!!null" {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s must be a mapping, got %s", describe(path), kindName(node))
+			return
+		}
+		fields := make(map[string]reflect.StructField)
+		var keys []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			fields[key] = f
+			keys = append(keys, key)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			f, ok := fields[key.Value]
+			if !ok {
+				if s := suggest(key.Value, keys); s != "" {
+					v.errorf(key, "unknown key %q in %s, did you mean %q?", key.Value, describe(path), s)
+				} else {
+					v.errorf(key, "unknown key %q in %s, expected one of %s", key.Value, describe(path), strings.Join(keys, ", "))
+				}
+				continue
+			}
+			v.check(value, f.Type, join(path, key.Value), f.Tag.Get("schema") == "regexp")
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			v.errorf(node, "%s must be a list, got %s", describe(path), kindName(node))
+			return
+		}
+		for i, elem := range node.Content {
+			v.check(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i), isRegexp)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			v.errorf(node, "%s must be a string, got %s", describe(path), kindName(node))
+			return
+		}
+		if isRegexp {
+			if _, err := regexp.Compile(node.Value); err != nil {
+				v.errorf(node, "%s: %v", describe(path), err)
+			}
+		}
+	case reflect.Int:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			v.errorf(node, "%s must be an integer, got %s", describe(path), kindName(node))
+		}
+	}
+}
+
+// join appends key to the dotted path.
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// describe names the value at path in errors.
+func describe(path string) string {
+	if path == "" {
+		return "the configuration"
+	}
+	return path
+}
+
+// kindName names the kind of value of node in errors.
+func kindName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return fmt.Sprintf("%q", node.Value)
+}
+
+// suggest returns the key of keys closest to the unknown key, if it is close
+// enough to be a typo of it.
+func suggest(key string, keys []string) string {
+	best, bestDist := "", len(key)/3+2
+	for _, k := range keys {
+		if d := editDistance(strings.ToLower(key), k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
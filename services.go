@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Service is a deployable unit of a monorepo: the entrypoints declared in
+// Dirs, paths relative to the analyzed directory that include their
+// subdirectories and may have * elements. Without a name, a service is named
+// after the last directory its pattern matched, so that cmd/* is a service
+// per command.
+type Service struct {
+	Name string   `yaml:"name"`
+	Dirs []string `yaml:"dirs"`
+}
+
+// defaultServices is the layout assumed when no service is configured: a
+// service per directory of cmd.
+var defaultServices = []Service{{Dirs: []string{"cmd/*"}}}
+
+// servicesPath is the -services flag.
+var servicesPath string
+
+// loadServices returns the services of -services, a YAML list of services,
+// or else of the configuration, or else defaultServices.
+func loadServices() ([]Service, error) {
+	if servicesPath == "" {
+		if len(config.Services) > 0 {
+			return config.Services, nil
+		}
+		return defaultServices, nil
+	}
+	data, err := os.ReadFile(servicesPath)
+	if err != nil {
+		return nil, err
+	}
+	var services []Service
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&services); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %v", servicesPath, err)
+	}
+	return services, nil
+}
+
+// names returns the names of s matching the file of an entrypoint, relative
+// to the analyzed directory.
+func (s Service) names(file string) []string {
+	elems := strings.Split(path.Dir(filepath.ToSlash(file)), "/")
+	var names []string
+	for _, d := range s.Dirs {
+		d = path.Clean(d)
+		if d == "." {
+			names = append(names, s.Name)
+			continue
+		}
+		pattern := strings.Split(d, "/")
+		if len(pattern) > len(elems) {
+			continue
+		}
+		matched := true
+		for i, p := range pattern {
+			if ok, _ := path.Match(p, elems[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if s.Name != "" {
+			names = append(names, s.Name)
+		} else {
+			names = append(names, elems[len(pattern)-1])
+		}
+	}
+	return names
+}
+
+// affectedServices returns the sorted names of the services declaring a
+// source that reaches a sink.
+func affectedServices(results []SourceResult, services []Service) []string {
+	affected := make(map[string]bool)
+	for _, res := range results {
+		if len(res.Findings) == 0 {
+			continue
+		}
+		file, _ := res.Source.edited()
+		for _, s := range services {
+			for _, name := range s.names(relPath(file)) {
+				if name != "" {
+					affected[name] = true
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(affected))
+	for name := range affected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeServices writes the names of the affected services, one per line, for
+// deployment scripts.
+func writeServices(w io.Writer, results []SourceResult, services []Service) error {
+	for _, name := range affectedServices(results, services) {
+		if _, err := fmt.Fprintln(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"log"
+	"os"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// buildShard builds the shard of the packages given to graph -shard and saves
+// it to name.
+func buildShard(name string) {
+	s, err := analyzer.BuildShard(runCtx)
+	if err != nil {
+		failed("Error building shard:", err)
+	}
+	s.Key = shardKey()
+	if err := saveShard(name, s); err != nil {
+		fatal("Error saving shard:", err)
+	}
+	log.Printf("Saved the shard of %d packages to %s", len(s.Packages()), name)
+}
+
+// mergeShards merges the shards saved at names into the graph of the target
+// and saves it to output.
+func mergeShards(names []string, output string) {
+	if len(names) == 0 {
+		fatal("Error: -merge takes the shard files to merge")
+	}
+	key := shardKey()
+	var shards []*callgraphanalysis.Shard
+	for _, name := range names {
+		s, err := loadShard(name)
+		if err != nil {
+			fatal("Error loading shard:", err)
+		}
+		if s.Module != module {
+			fatalf("Error: %s is a shard of %s, not %s", name, s.Module, module)
+		}
+		if s.Key != key {
+			fatalf("Error: %s was built with other flags or another build of the tool", name)
+		}
+		shards = append(shards, s)
+	}
+	g, err := analyzer.MergeShards(shards)
+	if err != nil {
+		fatal("Error merging shards:", err)
+	}
+	if err := saveGraph(output, g); err != nil {
+		fatal("Error saving graph:", err)
+	}
+	log.Printf("Merged %d shards: saved %d functions and %d edges to %s", len(shards), len(g.Funcs), len(g.Edges), output)
+}
+
+// shardKey identifies the flags the shards are built with, but for the
+// packages, which the shards split.
+func shardKey() string {
+	patterns := loadPatterns
+	loadPatterns = nil
+	defer func() { loadPatterns = patterns }()
+	env, err := goEnv()
+	if err != nil {
+		fatal("Error:", err)
+	}
+	return callgraphanalysis.CacheKey("shard", append([]string{toolVersion(), env}, graphConfig()...)...)
+}
+
+// saveShard writes s to name as gzipped gob.
+func saveShard(name string, s *callgraphanalysis.Shard) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	if err := s.Encode(zw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadShard reads the shard saved by saveShard at name.
+func loadShard(name string) (*callgraphanalysis.Shard, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	s, err := callgraphanalysis.DecodeShard(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ticketDraft is one affected entrypoint in -format=tickets, shaped for
+// ticket automation.
+type ticketDraft struct {
+	Entrypoint  Frame    `json:"entrypoint"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels"`
+	Assignee    string   `json:"assignee,omitempty"`
+	Owners      []string `json:"owners,omitempty"`
+}
+
+// writeTickets writes a JSON array with one ticket draft per source reaching
+// a sink. Labels come from the categories of the configuration, or from the
+// sink packages without them; the assignee is the first CODEOWNERS owner of
+// the source file.
+func writeTickets(w io.Writer, results []SourceResult, categories []Category) error {
+	type category struct {
+		label string
+		re    *regexp.Regexp
+	}
+	var cats []category
+	for _, c := range categories {
+		re, err := regexp.Compile(c.Functions)
+		if err != nil {
+			return fmt.Errorf("category %s: %v", c.Label, err)
+		}
+		cats = append(cats, category{c.Label, re})
+	}
+	owners, err := loadCodeOwners()
+	if err != nil {
+		return fmt.Errorf("reading CODEOWNERS: %v", err)
+	}
+
+	drafts := []ticketDraft{}
+	for _, res := range results {
+		if len(res.Findings) == 0 {
+			continue
+		}
+		sinks := make(map[string]bool)
+		labels := make(map[string]bool)
+		var desc strings.Builder
+		fmt.Fprintf(&desc, msg("ticketIntro")+"\n\n", mdFrame(res.Source))
+		for _, finding := range res.Findings {
+			sinks[finding.Sink.Function] = true
+			if len(cats) == 0 {
+				labels[path.Base(pkgOf(finding.Sink.Function))] = true
+			}
+			for _, c := range cats {
+				if c.re.MatchString(finding.Sink.Function) {
+					labels[c.label] = true
+				}
+			}
+			names := make([]string, len(finding.Path))
+			for i, frame := range finding.Path {
+				names[i] = "`" + frame.Name + "`"
+			}
+			fmt.Fprintf(&desc, "- %s: %s\n", mdFrame(finding.Sink), strings.Join(names, " → "))
+		}
+
+		draft := ticketDraft{
+			Entrypoint:  res.Source,
+			Title:       fmt.Sprintf(msg("ticketTitle"), res.Source.Name, len(sinks)),
+			Description: desc.String(),
+			Labels:      []string{},
+			Owners:      owners.owners(relPath(res.Source.File)),
+		}
+		for label := range labels {
+			draft.Labels = append(draft.Labels, label)
+		}
+		sort.Strings(draft.Labels)
+		if len(draft.Owners) > 0 {
+			draft.Assignee = draft.Owners[0]
+		}
+		drafts = append(drafts, draft)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(drafts)
+}
+
+// pkgOf returns the package path of a full function name such as
+// educabot.com/ted/internal/usecases.SaveV2 or (*example.com/p.T).M.
+func pkgOf(function string) string {
+	function = strings.TrimLeft(function, "(*")
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// timelineCommits is the number of recent commits shown per sink in the
+// HTML timeline.
+const timelineCommits = 5
+
+// commitInfo is a commit in the timeline of a sink.
+type commitInfo struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Subject string
+}
+
+// sinkTimeline is the panel of a reached sink in the HTML report: the recent
+// commits touching it and the entrypoints reaching it.
+type sinkTimeline struct {
+	Sink        Frame
+	Commits     []commitInfo
+	Entrypoints []Frame
+}
+
+// sinkTimelines builds the timeline of every sink of results reached by a
+// source, in sinks order. The timelines whose git history can't be read have
+// no commits; the first such error is returned along with them.
+func sinkTimelines(results []SourceResult, sinks []Frame) ([]sinkTimeline, error) {
+	var timelines []sinkTimeline
+	var firstErr error
+	for _, sink := range sinks {
+		t := sinkTimeline{Sink: sink}
+		for _, res := range results {
+			for _, finding := range res.Findings {
+				if finding.Sink.Function == sink.Function {
+					t.Entrypoints = append(t.Entrypoints, res.Source)
+					break
+				}
+			}
+		}
+		if len(t.Entrypoints) == 0 {
+			continue
+		}
+		commits, err := functionCommits(sink, timelineCommits)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		t.Commits = commits
+		timelines = append(timelines, t)
+	}
+	return timelines, firstErr
+}
+
+// functionCommits returns the last n commits changing the body of the
+// function of f, with git log -L. Anonymous functions are tracked through the
+// function declaring them.
+func functionCommits(f Frame, n int) ([]commitInfo, error) {
+	name, _, _ := strings.Cut(f.Name, "$")
+	name, _, _ = strings.Cut(name, "[")
+	rel := filepath.ToSlash(relPath(f.File))
+	cmd := exec.Command("git", "-C", dir, "log", "-n", fmt.Sprint(n), "-s",
+		"--format=%H%x00%an%x00%aI%x00%s", "-L", ":^func.* "+name+"(:"+rel)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	var commits []commitInfo
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, commitInfo{Hash: fields[0], Author: fields[1], Date: date, Subject: fields[3]})
+	}
+	return commits, nil
+}
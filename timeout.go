@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"educabot.com/callgraph-analysis/callgraphanalysis"
+)
+
+// The time limits of the phases of analyze, 0 for none.
+var (
+	loadTimeout   time.Duration
+	buildTimeout  time.Duration
+	searchTimeout time.Duration
+)
+
+// timeoutError is the cause of runCtx once a time limit is reached.
+type timeoutError struct {
+	flag  string
+	limit time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s of %v reached", e.flag, e.limit)
+}
+
+// phaseTimeoutFlags declares the time limits of the phases.
+func phaseTimeoutFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&loadTimeout, "load-timeout", 0, "Time loading the packages may take, listing, parsing and type-checking them (0: no limit)")
+	fs.DurationVar(&buildTimeout, "build-timeout", 0, "Time building the SSA form of the packages and the call graph may take (0: no limit)")
+	fs.DurationVar(&searchTimeout, "search-timeout", 0, "Time the search of the paths may take before the pairs left are reported as truncated (0: no limit)")
+}
+
+// phases cancels runCtx once the phase of the library going on outlasts its
+// time limit, restarting the limit as a phase starts.
+var phases struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc // cancels runCtx, nil without limits
+	flag   string                  // of the phase going on
+	timer  *time.Timer
+}
+
+// limitPhases derives runCtx from ctx so that the phases of the library are
+// stopped once they outlast their time limit, if any is set.
+func limitPhases(ctx context.Context) context.Context {
+	if loadTimeout <= 0 && buildTimeout <= 0 && searchTimeout <= 0 {
+		return ctx
+	}
+	ctx, phases.cancel = context.WithCancelCause(ctx)
+	return ctx
+}
+
+// enterPhase starts the time limit of phase, unless the phase going on has
+// the same one, as the SSA form and the graph are built under -build-timeout.
+func enterPhase(phase callgraphanalysis.Phase) {
+	if phases.cancel == nil {
+		return
+	}
+	var t timeoutError
+	switch phase {
+	case callgraphanalysis.PhaseLoad:
+		t = timeoutError{"-load-timeout", loadTimeout}
+	case callgraphanalysis.PhaseBuild, callgraphanalysis.PhaseGraph:
+		t = timeoutError{"-build-timeout", buildTimeout}
+	case callgraphanalysis.PhaseSearch:
+		t = timeoutError{"-search-timeout", searchTimeout}
+	}
+	phases.mu.Lock()
+	defer phases.mu.Unlock()
+	if t.flag == phases.flag {
+		return
+	}
+	if phases.timer != nil {
+		phases.timer.Stop()
+		phases.timer = nil
+	}
+	phases.flag = t.flag
+	if t.limit > 0 {
+		phases.timer = time.AfterFunc(t.limit, func() { phases.cancel(&t) })
+	}
+}
+
+// endPhases stops the time limit of the phase going on, once the search is
+// done.
+func endPhases() {
+	phases.mu.Lock()
+	defer phases.mu.Unlock()
+	if phases.timer != nil {
+		phases.timer.Stop()
+		phases.timer = nil
+	}
+	phases.flag = ""
+}
+
+// timedOut returns the time limit that stopped runCtx, nil if none did.
+func timedOut() *timeoutError {
+	if runCtx.Err() == nil {
+		return nil
+	}
+	var t *timeoutError
+	if errors.As(context.Cause(runCtx), &t) {
+		return t
+	}
+	return nil
+}
+
+// failed logs msg and err, a failure of the analysis, and exits with
+// exitTimeout if a time limit stopped it, naming the limit, or with
+// exitError otherwise.
+func failed(msg string, err error) {
+	if t := timedOut(); t != nil {
+		log.Println(msg, t)
+		exit(exitTimeout)
+	}
+	fatal(msg, err)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escapes used by the tree rendering.
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiRed   = "\x1b[31m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// treeNode is a function in the prefix tree of the paths of a source.
+type treeNode struct {
+	frame    Frame
+	children []*treeNode
+	sink     bool // whether a path ends here
+	elided   int  // calls left out before it by -summary
+}
+
+// label returns the text of n in the tree.
+func (n *treeNode) label() string {
+	if n.elided > 0 {
+		return fmt.Sprintf(msg("elided"), n.elided) + n.frame.Name
+	}
+	return n.frame.Name
+}
+
+// child returns the child of n for frame, adding it if needed.
+func (n *treeNode) child(frame Frame) *treeNode {
+	for _, c := range n.children {
+		if c.frame.Function == frame.Function {
+			return c
+		}
+	}
+	c := &treeNode{frame: frame}
+	n.children = append(n.children, c)
+	return c
+}
+
+// useColor reports whether the text format is rendered as a colored tree,
+// following -color: always, never, or auto for when the results go to a
+// terminal and NO_COLOR is not set.
+func useColor() bool {
+	switch colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if outputPath != "" || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return terminal(os.Stdout)
+}
+
+// printTree writes the results as one tree per source merging its paths, with
+// the positions aligned in a column and, if color is set, the source in cyan
+// and the sinks in red. Under -summary, the calls between the points where
+// paths diverge are left out.
+func printTree(w io.Writer, results []SourceResult, color bool) {
+	esc := func(code string) string {
+		if color {
+			return code
+		}
+		return ""
+	}
+	if !quiet {
+		fmt.Fprintln(w, msg("analyzing"))
+	}
+	roots := make([]*treeNode, len(results))
+	width := 0
+	for i, res := range results {
+		root := &treeNode{frame: res.Source}
+		for _, finding := range res.Findings {
+			n := root
+			for _, frame := range finding.Path[1:] {
+				n = n.child(frame)
+			}
+			n.sink = true
+		}
+		if summary {
+			elide(root)
+		}
+		roots[i] = root
+		width = max(width, treeWidth(root, 0))
+	}
+
+	for i, res := range results {
+		root := roots[i]
+		fmt.Fprintln(w)
+		printTreeNode(w, root, "", "", width, true, esc)
+		if len(res.Findings) == 0 && len(res.Truncated) == 0 {
+			fmt.Fprintln(w, esc(ansiDim)+msg("noSinks")+esc(ansiReset))
+		}
+		for _, sink := range res.Truncated {
+			fmt.Fprintf(w, esc(ansiDim)+strings.TrimSuffix(msg("truncated"), "\n")+esc(ansiReset)+"\n", sink.Name, sink.File, sink.Line)
+		}
+		printed := make(map[string]bool)
+		for _, finding := range res.Findings {
+			if finding.CrossRepo && !printed[finding.Sink.Function] {
+				printed[finding.Sink.Function] = true
+				fmt.Fprintf(w, esc(ansiBold)+strings.TrimSuffix(msg("crossRepoSink"), "\n")+esc(ansiReset)+"\n", finding.Sink.Name, len(finding.ExternalCallers))
+			}
+		}
+	}
+}
+
+// printTreeNode writes n after prefix, then its children indented under
+// childPrefix. esc returns the ANSI escapes used, or none without color.
+func printTreeNode(w io.Writer, n *treeNode, prefix, childPrefix string, width int, source bool, esc func(string) string) {
+	label := n.label()
+	color := ""
+	switch {
+	case source:
+		color = esc(ansiBold + ansiCyan)
+	case n.sink:
+		color = esc(ansiBold + ansiRed)
+	}
+	pad := width - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(label)
+	file, line := n.frame.edited()
+	pos := fmt.Sprintf("%s:%d", filepath.ToSlash(relPath(file)), line)
+	if call := n.frame.Call; call != nil {
+		pos += fmt.Sprintf(msg("calledAt"), filepath.ToSlash(relPath(call.File)), call.Line)
+	}
+	pos += blameSuffix(n.frame)
+	fmt.Fprintf(w, "%s%s%s%s%s  %s%s%s\n", esc(ansiDim)+prefix+esc(ansiReset), color, label, esc(ansiReset),
+		strings.Repeat(" ", pad), esc(ansiDim), pos, esc(ansiReset))
+	for i, c := range n.children {
+		if i == len(n.children)-1 {
+			printTreeNode(w, c, childPrefix+"└─ ", childPrefix+"   ", width, false, esc)
+		} else {
+			printTreeNode(w, c, childPrefix+"├─ ", childPrefix+"│  ", width, false, esc)
+		}
+	}
+}
+
+// elide leaves out of the tree under n the runs of two or more functions
+// where paths don't diverge: those with a single callee in the tree that are
+// not sinks. The first function kept after a run counts how many were left
+// out.
+func elide(n *treeNode) {
+	for i, c := range n.children {
+		last, elided := c, 0
+		for len(last.children) == 1 && !last.sink {
+			last = last.children[0]
+			elided++
+		}
+		if elided >= 2 {
+			last.elided = elided
+			n.children[i] = last
+		}
+		elide(n.children[i])
+	}
+}
+
+// treeWidth returns the widest prefix and label under n, indented by depth.
+func treeWidth(n *treeNode, depth int) int {
+	width := 3*depth + utf8.RuneCountInString(n.label())
+	for _, c := range n.children {
+		width = max(width, treeWidth(c, depth+1))
+	}
+	return width
+}
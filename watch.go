@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// watch is -watch of analyze.
+var watch bool
+
+// watchInterval is how often -watch looks for changed files.
+const watchInterval = 500 * time.Millisecond
+
+// watchFlag matches -watch among the arguments of analyze, which the runs it
+// starts are given without.
+var watchFlag = regexp.MustCompile(`^--?watch(=.*)?$`)
+
+// watchAnalysis runs analyze with args but -watch, and again every time a Go
+// file, go.mod or go.sum under the analyzed directory changes, until
+// interrupted. Every run is a process of its own, so its results are those
+// of analyze. With -cache, the runs are -incremental unless it was given,
+// rebuilding only the packages changed since the last one.
+func watchAnalysis(args []string, incrementalGiven bool) {
+	exe, err := os.Executable()
+	if err != nil {
+		fatal("Error:", err)
+	}
+	runArgs := []string{"analyze"}
+	for _, arg := range args {
+		if !watchFlag.MatchString(arg) {
+			runArgs = append(runArgs, arg)
+		}
+	}
+	if caching() && !incrementalGiven && !useDaemon {
+		// Before the patterns, which end the flags
+		runArgs = append(runArgs[:1], append([]string{"-incremental"}, runArgs[1:]...)...)
+		log.Println("Watching incrementally, rebuilding only the packages changed since the last run; the graph may have a few more calls through types declared inside functions than a full build, which -incremental=false runs")
+	}
+	clearScreen := terminal(os.Stdout) && outputPath == ""
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for first := true; ; first = false {
+		start := time.Now()
+		tree, err := scanTree()
+		if err != nil {
+			log.Println("Warning: looking for changes:", err)
+		}
+		if !first {
+			if clearScreen {
+				os.Stdout.WriteString("\033[H\033[2J")
+			}
+			log.Println("Files changed, analyzing again")
+		}
+		cmd := exec.CommandContext(ctx, exe, runArgs...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		var exitErr *exec.ExitError
+		if err := cmd.Run(); err != nil && !errors.As(err, &exitErr) {
+			fatal("Error running the analysis:", err)
+		}
+		if ctx.Err() != nil {
+			exit(exitClean)
+		}
+		log.Printf("Analyzed in %v, watching %s for changes (Ctrl-C to stop)", time.Since(start).Round(time.Millisecond), dir)
+
+		for changed := false; !changed; {
+			select {
+			case <-ctx.Done():
+				exit(exitClean)
+			case <-time.After(watchInterval):
+			}
+			now, err := scanTree()
+			if err != nil {
+				log.Println("Warning: looking for changes:", err)
+				continue
+			}
+			changed = now.changedFrom(tree)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// worktreeSinks returns the Go files of the analyzed directory with staged,
+// unstaged or untracked changes in git, relative to it. Deleted files are
+// left out since they declare no function anymore.
+func worktreeSinks() ([]string, error) {
+	top, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	out, err := exec.Command("git", "-C", dir, "status", "--porcelain=v1", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	entries := bytes.Split(out, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		entry := string(entries[i])
+		if len(entry) < 4 {
+			continue
+		}
+		status, name := entry[:2], entry[3:]
+		if status[0] == 'R' || status[0] == 'C' {
+			// Renames and copies are followed by the original path
+			i++
+		}
+		if strings.Contains(status, "D") || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		rel, err := filepath.Rel(base, filepath.Join(strings.TrimSpace(string(top)), name))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		files = append(files, rel)
+	}
+	return files, nil
+}
+
+// gitError adds the stderr of a failed git command to its error.
+func gitError(err error) error {
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		return fmt.Errorf("git: %v: %s", err, bytes.TrimSpace(ee.Stderr))
+	}
+	return err
+}